@@ -4,11 +4,16 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"mime"
+	"os"
+	"path/filepath"
 	"time"
 
 	"claude-schedule/internal/db"
 	"claude-schedule/internal/executor"
 	"claude-schedule/internal/scheduler"
+	"claude-schedule/internal/transcript"
+	"claude-schedule/internal/webhook"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
 	"github.com/wailsapp/wails/v3/pkg/services/notifications"
@@ -18,12 +23,14 @@ import (
 type App struct {
 	store    *db.Store
 	sched    *scheduler.Scheduler
+	webhooks *webhook.Dispatcher
 	notifier *notifications.NotificationService
+	dataDir  string // holds the database and the files runs leave behind
 }
 
 // NewApp creates a new App application struct
-func NewApp(store *db.Store, notifier *notifications.NotificationService) *App {
-	return &App{store: store, notifier: notifier}
+func NewApp(store *db.Store, notifier *notifications.NotificationService, dataDir string) *App {
+	return &App{store: store, notifier: notifier, dataDir: dataDir}
 }
 
 // ServiceStartup is called when the app starts via the Wails v3 service lifecycle.
@@ -32,8 +39,24 @@ func (a *App) ServiceStartup(ctx context.Context, options application.ServiceOpt
 		app := application.Get()
 		app.Event.Emit(eventName, data...)
 	}
-	a.sched = scheduler.New(a.store, emit, executor.ClaudeExecute, 60*time.Second)
+	settings, err := a.store.GetSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	executor.SetSettings(settings)
+
+	// Bring run output rendered by an older renderer up to date.
+	if n, err := a.store.RerenderRuns(transcript.Version, transcript.Render); err != nil {
+		log.Printf("transcript: failed to re-render runs: %v", err)
+	} else if n > 0 {
+		log.Printf("transcript: re-rendered %d run(s)", n)
+	}
+
+	a.sched = scheduler.New(a.store, emit, executor.Execute, 60*time.Second)
 	a.sched.SetNotifyFunc(a.sendNotification)
+	a.webhooks = webhook.NewDispatcher(a.store)
+	a.sched.SetWebhookFunc(a.webhooks.Dispatch)
+	a.sched.SetWorktreeRoot(filepath.Join(a.dataDir, "worktrees"))
 	a.sched.Start(ctx)
 	return nil
 }
@@ -41,7 +64,7 @@ func (a *App) ServiceStartup(ctx context.Context, options application.ServiceOpt
 // sendNotification fires a native OS notification for job status changes.
 // It recovers from panics because the notification backend (e.g. dbus on Linux)
 // may have a nil connection on environments like WSL2.
-func (a *App) sendNotification(jobName string, status string) {
+func (a *App) sendNotification(jobName string, status string, detail string) {
 	if a.notifier == nil {
 		return
 	}
@@ -63,12 +86,18 @@ func (a *App) sendNotification(jobName string, status string) {
 	case "failed":
 		title = "Job Failed"
 		body = jobName + " failed"
+	case "assertion_failed":
+		title = "Job Failed Its Checks"
+		body = jobName + " finished but failed its success criteria"
 	case "waiting":
 		title = "Job Needs Input"
 		body = jobName + " is waiting for your answer"
 	default:
 		return
 	}
+	if detail != "" {
+		body += "\n" + detail
+	}
 	_ = a.notifier.SendNotification(notifications.NotificationOptions{
 		ID:    "job-" + jobName + "-" + status,
 		Title: title,
@@ -81,6 +110,9 @@ func (a *App) ServiceShutdown() error {
 	if a.sched != nil {
 		a.sched.Stop()
 	}
+	if a.webhooks != nil {
+		a.webhooks.Close()
+	}
 	if a.store != nil {
 		a.store.Close()
 	}
@@ -104,11 +136,29 @@ func (a *App) GetJob(id string) (db.Job, error) {
 
 // CreateJob inserts a new job and returns it with the generated ID.
 func (a *App) CreateJob(job db.Job) (db.Job, error) {
+	if err := db.ValidateJobDirs(job); err != nil {
+		return job, err
+	}
+	if err := db.ValidateJobPrompt(job); err != nil {
+		return job, err
+	}
+	if _, err := executor.Lookup(job.Executor); err != nil {
+		return job, err
+	}
 	return a.store.CreateJob(job)
 }
 
 // UpdateJob updates an existing job.
 func (a *App) UpdateJob(job db.Job) (db.Job, error) {
+	if err := db.ValidateJobDirs(job); err != nil {
+		return job, err
+	}
+	if err := db.ValidateJobPrompt(job); err != nil {
+		return job, err
+	}
+	if _, err := executor.Lookup(job.Executor); err != nil {
+		return job, err
+	}
 	return a.store.UpdateJob(job)
 }
 
@@ -117,11 +167,116 @@ func (a *App) DeleteJob(id string) error {
 	return a.store.DeleteJob(id)
 }
 
+// ResetJobSession discards a job's conversation so its next run starts fresh.
+func (a *App) ResetJobSession(jobID string) error {
+	return a.store.ResetJobSession(jobID)
+}
+
 // GetRunsForJob returns the recent run history for a job.
 func (a *App) GetRunsForJob(jobID string) ([]db.JobRun, error) {
 	return a.store.GetRunsForJob(jobID)
 }
 
+// GetRunEvents returns the structured transcript of a run.
+func (a *App) GetRunEvents(runID string) ([]transcript.Event, error) {
+	return a.store.GetRunEvents(runID)
+}
+
+// GetRunDiff returns how a run's final result differs from the previous
+// successful run's, as a unified diff.
+func (a *App) GetRunDiff(runID string) (string, error) {
+	return a.store.GetRunDiff(runID)
+}
+
+// GetRunRawEvents returns the stream-json output stored with a run, one JSON
+// object per line.
+func (a *App) GetRunRawEvents(runID string) ([]string, error) {
+	return a.store.GetRawEvents(runID)
+}
+
+// RerenderRun rebuilds a finished run's transcript from its stored stream
+// with the current renderer.
+func (a *App) RerenderRun(runID string) (db.JobRun, error) {
+	return a.sched.RerenderRun(runID)
+}
+
+// GetRunArtifacts returns the files a run produced in its artifacts directory.
+func (a *App) GetRunArtifacts(runID string) ([]db.RunArtifact, error) {
+	return a.store.GetRunArtifacts(runID)
+}
+
+// OpenRunArtifact opens one of a run's artifacts. Documents and images open
+// in their default application; anything else, which could be a program the
+// run wrote, is only shown in the file manager.
+func (a *App) OpenRunArtifact(runID, name string) error {
+	artifacts, err := a.store.GetRunArtifacts(runID)
+	if err != nil {
+		return err
+	}
+	for _, artifact := range artifacts {
+		if artifact.Name != name {
+			continue
+		}
+		path := filepath.Join(a.store.ArtifactsDir(runID), filepath.FromSlash(name))
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("artifact is no longer a regular file: %s", name)
+		}
+		app := application.Get()
+		if openableArtifact(artifact.MimeType) {
+			return app.Browser.OpenFile(path)
+		}
+		return app.Env.OpenFileManager(path, true)
+	}
+	return fmt.Errorf("artifact not found: %s", name)
+}
+
+// openableArtifacts lists the MIME types of artifacts that are safe to hand
+// to their default application. Anything else, such as a script the job
+// wrote, is shown in the file manager instead of being run.
+var openableArtifacts = map[string]bool{
+	"text/plain":      true,
+	"text/markdown":   true,
+	"text/csv":        true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// openableArtifact reports whether an artifact of the given MIME type is safe
+// to hand to its default application.
+func openableArtifact(mimeType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	return openableArtifacts[mediaType]
+}
+
+// KeepRunWorktree removes a run's worktree but keeps its branch.
+func (a *App) KeepRunWorktree(runID string) error {
+	return a.sched.KeepWorktree(runID)
+}
+
+// DiscardRunWorktree removes a run's worktree and deletes its branch.
+func (a *App) DiscardRunWorktree(runID string) error {
+	return a.sched.DiscardWorktree(runID)
+}
+
+// MergeRunWorktree merges a run's branch into the job's repository and
+// removes the worktree.
+func (a *App) MergeRunWorktree(runID string) error {
+	return a.sched.MergeWorktree(runID)
+}
+
+// GetUsageStats returns per-job usage and cost for runs started within
+// [from, to). Both bounds are RFC3339 timestamps; empty means unbounded.
+func (a *App) GetUsageStats(from, to string) ([]db.JobUsage, error) {
+	return a.store.GetUsageStats(from, to)
+}
+
 // GetMCPServers returns all configured MCP servers.
 func (a *App) GetMCPServers() ([]db.MCPServer, error) {
 	return a.store.GetMCPServers()
@@ -152,12 +307,100 @@ func (a *App) SetJobMCPServers(jobID string, serverIDs []string) error {
 	return a.store.SetJobMCPServers(jobID, serverIDs)
 }
 
+// GetWebhooks returns all configured webhooks.
+func (a *App) GetWebhooks() ([]db.Webhook, error) {
+	return a.store.GetWebhooks()
+}
+
+// CreateWebhook adds a new webhook.
+func (a *App) CreateWebhook(w db.Webhook) (db.Webhook, error) {
+	return a.store.CreateWebhook(w)
+}
+
+// UpdateWebhook updates an existing webhook.
+func (a *App) UpdateWebhook(w db.Webhook) (db.Webhook, error) {
+	return a.store.UpdateWebhook(w)
+}
+
+// DeleteWebhook removes a webhook by ID, along with its delivery log.
+func (a *App) DeleteWebhook(id string) error {
+	return a.store.DeleteWebhook(id)
+}
+
+// TestWebhook sends a sample event to a webhook and returns the delivery.
+func (a *App) TestWebhook(id string) (db.WebhookDelivery, error) {
+	w, err := a.store.GetWebhook(id)
+	if err != nil {
+		return db.WebhookDelivery{}, err
+	}
+	return a.webhooks.Test(w), nil
+}
+
+// GetWebhookDeliveries returns the most recent deliveries to a webhook.
+func (a *App) GetWebhookDeliveries(webhookID string) ([]db.WebhookDelivery, error) {
+	return a.store.GetWebhookDeliveries(webhookID)
+}
+
+// GetJobWebhookIDs returns the IDs of the webhooks explicitly routed to a job.
+func (a *App) GetJobWebhookIDs(jobID string) ([]string, error) {
+	return a.store.GetJobWebhookIDs(jobID)
+}
+
+// SetJobWebhooks replaces the webhooks routed to a job.
+func (a *App) SetJobWebhooks(jobID string, webhookIDs []string) error {
+	return a.store.SetJobWebhooks(jobID, webhookIDs)
+}
+
+// GetExecutors returns the names of the backends a job can run with.
+func (a *App) GetExecutors() []string {
+	return executor.Names()
+}
+
+// DetectClaudePath looks for the claude CLI on PATH and in common install
+// locations, for the settings screen to offer as the binary path.
+func (a *App) DetectClaudePath() (string, error) {
+	return executor.DetectClaudePath()
+}
+
+// GetSettings returns the global application settings.
+func (a *App) GetSettings() (db.Settings, error) {
+	return a.store.GetSettings()
+}
+
+// UpdateSettings saves the global application settings and applies them to
+// subsequent runs.
+func (a *App) UpdateSettings(settings db.Settings) (db.Settings, error) {
+	saved, err := a.store.UpdateSettings(settings)
+	if err != nil {
+		return saved, err
+	}
+	executor.SetSettings(saved)
+	return saved, nil
+}
+
 // RunJobNow triggers immediate execution of a job.
 func (a *App) RunJobNow(jobID string) error {
 	return a.sched.RunNow(jobID)
 }
 
+// RunJobWithPayload triggers immediate execution of a job, making payload
+// available to its prompt template as {{.Payload}}.
+func (a *App) RunJobWithPayload(jobID string, payload string) error {
+	return a.sched.RunNowWithPayload(jobID, payload)
+}
+
+// PreviewPrompt renders a job's prompt template as a run started now would
+// see it. The job need not be saved yet.
+func (a *App) PreviewPrompt(job db.Job) (string, error) {
+	return a.sched.PreviewPrompt(job)
+}
+
 // AnswerQuestion sends the user's answer to a waiting job and resumes execution.
 func (a *App) AnswerQuestion(jobID string, answer string) error {
 	return a.sched.AnswerQuestion(jobID, answer)
 }
+
+// SendFollowUp sends a follow-up message to a finished run's conversation.
+func (a *App) SendFollowUp(runID string, message string) error {
+	return a.sched.SendFollowUp(runID, message)
+}
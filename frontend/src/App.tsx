@@ -1,14 +1,17 @@
 import { useEffect, useState, useCallback, useRef } from "react";
-import { GetJobs, CreateJob, UpdateJob, SetJobMCPServers, RunJobNow, OnEvent } from "./wailsbridge";
+import { GetJobs, CreateJob, UpdateJob, SetJobMCPServers, SetJobWebhooks, RunJobNow, ResetJobSession, OnEvent } from "./wailsbridge";
 import { ScheduledJob } from "./types";
 import { useToasts } from "./hooks/useToasts";
 import JobList from "./components/JobList";
 import JobDetail from "./components/JobDetail";
 import JobForm from "./components/JobForm";
 import MCPSettings from "./components/MCPSettings";
+import WebhookSettings from "./components/WebhookSettings";
+import Settings from "./components/Settings";
+import UsageStats from "./components/UsageStats";
 import ToastContainer from "./components/Toast";
 
-type ViewMode = "detail" | "new" | "edit" | "settings";
+type ViewMode = "detail" | "new" | "edit" | "settings" | "webhooks" | "general" | "usage";
 
 function App() {
   const [jobs, setJobs] = useState<ScheduledJob[]>([]);
@@ -35,6 +38,8 @@ function App() {
         addToast(`${job.name} completed`, "success");
       } else if (job.status === "failed" && prevStatus === "running") {
         addToast(`${job.name} failed`, "error");
+      } else if (job.status === "assertion_failed" && prevStatus === "running") {
+        addToast(`${job.name} failed its success criteria`, "error");
       }
     }
 
@@ -89,7 +94,17 @@ function App() {
     }
   };
 
-  const handleSaveJob = async (job: ScheduledJob, mcpServerIds: string[]) => {
+  const handleResetSession = async (jobId: string) => {
+    try {
+      await ResetJobSession(jobId);
+      await refreshJobs();
+    } catch (err) {
+      const message = err instanceof Error ? err.message : String(err);
+      addToast(message, "error");
+    }
+  };
+
+  const handleSaveJob = async (job: ScheduledJob, mcpServerIds: string[], webhookIds: string[]) => {
     setSaveError(null);
     try {
       let savedId: string;
@@ -102,6 +117,7 @@ function App() {
       }
       // Save MCP server associations.
       await SetJobMCPServers(savedId, mcpServerIds);
+      await SetJobWebhooks(savedId, webhookIds);
       await refreshJobs(savedId);
       setViewMode("detail");
     } catch (err) {
@@ -136,12 +152,51 @@ function App() {
           >
             MCP Servers
           </button>
+          <button
+            onClick={() => setViewMode("webhooks")}
+            className={`w-full text-left text-xs font-medium px-2 py-1.5 rounded transition-colors ${
+              viewMode === "webhooks"
+                ? "text-blue-400 bg-gray-800"
+                : "text-gray-500 hover:text-gray-300"
+            }`}
+          >
+            Webhooks
+          </button>
+          <button
+            onClick={() => setViewMode("usage")}
+            className={`w-full text-left text-xs font-medium px-2 py-1.5 rounded transition-colors ${
+              viewMode === "usage"
+                ? "text-blue-400 bg-gray-800"
+                : "text-gray-500 hover:text-gray-300"
+            }`}
+          >
+            Usage
+          </button>
+          <button
+            onClick={() => setViewMode("general")}
+            className={`w-full text-left text-xs font-medium px-2 py-1.5 rounded transition-colors ${
+              viewMode === "general"
+                ? "text-blue-400 bg-gray-800"
+                : "text-gray-500 hover:text-gray-300"
+            }`}
+          >
+            Settings
+          </button>
         </div>
       </div>
       <div className="flex-1">
         {viewMode === "settings" && (
           <MCPSettings onClose={() => setViewMode("detail")} />
         )}
+        {viewMode === "webhooks" && (
+          <WebhookSettings onClose={() => setViewMode("detail")} />
+        )}
+        {viewMode === "usage" && (
+          <UsageStats onClose={() => setViewMode("detail")} />
+        )}
+        {viewMode === "general" && (
+          <Settings onClose={() => setViewMode("detail")} />
+        )}
         {viewMode === "new" && (
           <JobForm job={null} onSave={handleSaveJob} onCancel={handleCancelForm} saveError={saveError} />
         )}
@@ -149,7 +204,12 @@ function App() {
           <JobForm job={selectedJob} onSave={handleSaveJob} onCancel={handleCancelForm} saveError={saveError} />
         )}
         {viewMode === "detail" && (
-          <JobDetail job={selectedJob} onEdit={handleEditJob} onRunNow={handleRunNow} />
+          <JobDetail
+            job={selectedJob}
+            onEdit={handleEditJob}
+            onRunNow={handleRunNow}
+            onResetSession={handleResetSession}
+          />
         )}
       </div>
       <ToastContainer toasts={toasts} onDismiss={removeToast} />
@@ -0,0 +1,237 @@
+import { useState } from "react";
+import type { Assertion, AssertionKind } from "../types";
+
+export function KeyValueEditor({
+  value,
+  onChange,
+}: {
+  value: string;
+  onChange: (json: string) => void;
+}) {
+  const parse = (v: string): { key: string; value: string }[] => {
+    try {
+      const obj = JSON.parse(v);
+      if (obj && typeof obj === "object" && !Array.isArray(obj)) {
+        return Object.entries(obj).map(([k, val]) => ({ key: k, value: String(val) }));
+      }
+    } catch { /* ignore */ }
+    return [];
+  };
+
+  const [entries, setEntries] = useState(parse(value));
+
+  const sync = (updated: { key: string; value: string }[]) => {
+    setEntries(updated);
+    const obj: Record<string, string> = {};
+    for (const e of updated) {
+      if (e.key.trim()) obj[e.key.trim()] = e.value;
+    }
+    onChange(JSON.stringify(obj));
+  };
+
+  const update = (idx: number, field: "key" | "value", val: string) => {
+    const next = entries.map((e, i) => (i === idx ? { ...e, [field]: val } : e));
+    sync(next);
+  };
+
+  const remove = (idx: number) => {
+    sync(entries.filter((_, i) => i !== idx));
+  };
+
+  const add = () => {
+    sync([...entries, { key: "", value: "" }]);
+  };
+
+  const rowInputClass =
+    "bg-gray-800 border border-gray-600 rounded px-2 py-1.5 text-sm text-gray-100 focus:border-blue-500 focus:outline-none";
+
+  return (
+    <div className="space-y-2">
+      {entries.map((entry, idx) => (
+        <div key={idx} className="flex gap-2 items-center">
+          <input
+            type="text"
+            value={entry.key}
+            onChange={(e) => update(idx, "key", e.target.value)}
+            placeholder="Key"
+            className={rowInputClass + " flex-1"}
+          />
+          <input
+            type="text"
+            value={entry.value}
+            onChange={(e) => update(idx, "value", e.target.value)}
+            placeholder="Value"
+            className={rowInputClass + " flex-[2]"}
+          />
+          <button
+            onClick={() => remove(idx)}
+            className="text-red-400 hover:text-red-300 text-sm px-1.5 py-1 shrink-0"
+          >
+            x
+          </button>
+        </div>
+      ))}
+      <button
+        onClick={add}
+        className="text-xs text-blue-400 hover:text-blue-300"
+      >
+        + Add
+      </button>
+    </div>
+  );
+}
+
+export function ListEditor({
+  value,
+  onChange,
+  placeholder,
+}: {
+  value: string;
+  onChange: (json: string) => void;
+  placeholder?: string;
+}) {
+  const parse = (v: string): string[] => {
+    try {
+      const arr = JSON.parse(v);
+      if (Array.isArray(arr)) return arr.map(String);
+    } catch { /* ignore */ }
+    return [];
+  };
+
+  const [items, setItems] = useState(parse(value));
+
+  const sync = (updated: string[]) => {
+    setItems(updated);
+    onChange(JSON.stringify(updated));
+  };
+
+  const update = (idx: number, val: string) => {
+    sync(items.map((item, i) => (i === idx ? val : item)));
+  };
+
+  const remove = (idx: number) => {
+    sync(items.filter((_, i) => i !== idx));
+  };
+
+  const add = () => {
+    sync([...items, ""]);
+  };
+
+  const rowInputClass =
+    "bg-gray-800 border border-gray-600 rounded px-2 py-1.5 text-sm text-gray-100 focus:border-blue-500 focus:outline-none";
+
+  return (
+    <div className="space-y-2">
+      {items.map((item, idx) => (
+        <div key={idx} className="flex gap-2 items-center">
+          <input
+            type="text"
+            value={item}
+            onChange={(e) => update(idx, e.target.value)}
+            placeholder={placeholder ?? "Value"}
+            className={rowInputClass + " flex-1"}
+          />
+          <button
+            onClick={() => remove(idx)}
+            className="text-red-400 hover:text-red-300 text-sm px-1.5 py-1 shrink-0"
+          >
+            x
+          </button>
+        </div>
+      ))}
+      <button
+        onClick={add}
+        className="text-xs text-blue-400 hover:text-blue-300"
+      >
+        + Add
+      </button>
+    </div>
+  );
+}
+
+const assertionKinds: { kind: AssertionKind; label: string; placeholder: string }[] = [
+  { kind: "matches", label: "Result matches", placeholder: "regular expression, e.g. PR #\\d+" },
+  { kind: "not_matches", label: "Result does not match", placeholder: "regular expression, e.g. (?i)error" },
+  { kind: "tool_called", label: "Tool was called", placeholder: "tool name, e.g. mcp__github__*" },
+  { kind: "max_cost", label: "Cost at most ($)", placeholder: "0.50" },
+  { kind: "judge", label: "Judge agrees", placeholder: "e.g. The summary names every failing test" },
+];
+
+export function AssertionEditor({
+  value,
+  onChange,
+}: {
+  value: string;
+  onChange: (json: string) => void;
+}) {
+  const parse = (v: string): Assertion[] => {
+    try {
+      const arr = JSON.parse(v);
+      if (Array.isArray(arr)) {
+        return arr.map((a) => ({ kind: a.kind, value: String(a.value ?? "") }));
+      }
+    } catch { /* ignore */ }
+    return [];
+  };
+
+  const [items, setItems] = useState(parse(value));
+
+  const sync = (updated: Assertion[]) => {
+    setItems(updated);
+    onChange(JSON.stringify(updated));
+  };
+
+  const update = (idx: number, patch: Partial<Assertion>) => {
+    sync(items.map((item, i) => (i === idx ? { ...item, ...patch } : item)));
+  };
+
+  const remove = (idx: number) => {
+    sync(items.filter((_, i) => i !== idx));
+  };
+
+  const add = () => {
+    sync([...items, { kind: "matches", value: "" }]);
+  };
+
+  const rowInputClass =
+    "bg-gray-800 border border-gray-600 rounded px-2 py-1.5 text-sm text-gray-100 focus:border-blue-500 focus:outline-none";
+
+  return (
+    <div className="space-y-2">
+      {items.map((item, idx) => (
+        <div key={idx} className="flex gap-2 items-center">
+          <select
+            value={item.kind}
+            onChange={(e) => update(idx, { kind: e.target.value as AssertionKind })}
+            className={rowInputClass + " shrink-0"}
+          >
+            {assertionKinds.map((k) => (
+              <option key={k.kind} value={k.kind}>
+                {k.label}
+              </option>
+            ))}
+          </select>
+          <input
+            type="text"
+            value={item.value}
+            onChange={(e) => update(idx, { value: e.target.value })}
+            placeholder={assertionKinds.find((k) => k.kind === item.kind)?.placeholder ?? "Value"}
+            className={rowInputClass + " flex-1"}
+          />
+          <button
+            onClick={() => remove(idx)}
+            className="text-red-400 hover:text-red-300 text-sm px-1.5 py-1 shrink-0"
+          >
+            x
+          </button>
+        </div>
+      ))}
+      <button
+        onClick={add}
+        className="text-xs text-blue-400 hover:text-blue-300"
+      >
+        + Add
+      </button>
+    </div>
+  );
+}
@@ -0,0 +1,74 @@
+import { useEffect, useState } from "react";
+import { JobRun } from "../types";
+import { OnEvent, SendFollowUp } from "../wailsbridge";
+
+interface Props {
+  run: JobRun;
+}
+
+// FollowUp lets the user continue a finished run's conversation. Replies are
+// appended to the run's output without touching the job's own session.
+export default function FollowUp({ run }: Props) {
+  const [message, setMessage] = useState("");
+  const [sending, setSending] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    const off = OnEvent("run:followup", (evt) => {
+      if ((evt as { data?: string }).data === run.id) {
+        setSending(false);
+      }
+    });
+    return off;
+  }, [run.id]);
+
+  if (!run.sessionId || run.status === "running" || run.status === "waiting") {
+    return null;
+  }
+
+  const handleSend = async () => {
+    if (!message.trim()) return;
+    setError(null);
+    setSending(true);
+    try {
+      await SendFollowUp(run.id, message);
+      setMessage("");
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+      setSending(false);
+    }
+  };
+
+  return (
+    <div className="border-t border-gray-700 p-3">
+      <div className="flex gap-2">
+        <textarea
+          value={message}
+          onChange={(e) => setMessage(e.target.value)}
+          onKeyDown={(e) => {
+            if (e.key === "Enter" && (e.metaKey || e.ctrlKey)) handleSend();
+          }}
+          disabled={sending}
+          rows={2}
+          placeholder="Ask a follow-up about this run..."
+          className="flex-1 bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none resize-y disabled:opacity-50"
+        />
+        <button
+          onClick={handleSend}
+          disabled={sending || !message.trim()}
+          className={`self-end px-4 py-2 rounded text-sm font-medium transition-colors ${
+            sending || !message.trim()
+              ? "bg-gray-700 text-gray-500 cursor-not-allowed"
+              : "bg-blue-600 text-white hover:bg-blue-700"
+          }`}
+        >
+          Send
+        </button>
+      </div>
+      {sending && (
+        <p className="text-xs text-blue-400 mt-2 animate-pulse">Waiting for reply...</p>
+      )}
+      {error && <p className="text-xs text-red-400 mt-2">{error}</p>}
+    </div>
+  );
+}
@@ -1,9 +1,10 @@
 import { useEffect, useMemo, useState } from "react";
 import { marked } from "marked";
-import { ScheduledJob, JobRun } from "../types";
-import { formatInterval, formatTime } from "../utils";
+import { ScheduledJob, JobRun, RunProgress } from "../types";
+import { formatInterval, formatTime, formatTokens } from "../utils";
 import { GetRunsForJob, OnEvent, AnswerQuestion } from "../wailsbridge";
 import RunHistory from "./RunHistory";
+import FollowUp from "./FollowUp";
 
 type Tab = "current" | "history";
 
@@ -11,6 +12,7 @@ interface Props {
   job: ScheduledJob | null;
   onEdit: () => void;
   onRunNow: (jobId: string) => void;
+  onResetSession: (jobId: string) => void;
 }
 
 const statusLabels: Record<string, { text: string; color: string }> = {
@@ -19,6 +21,7 @@ const statusLabels: Record<string, { text: string; color: string }> = {
   running: { text: "Running", color: "text-yellow-400" },
   pending: { text: "Pending", color: "text-gray-400" },
   waiting: { text: "Waiting for Input", color: "text-amber-400" },
+  assertion_failed: { text: "Assertions Failed", color: "text-orange-400" },
 };
 
 const statusDot: Record<string, string> = {
@@ -26,6 +29,7 @@ const statusDot: Record<string, string> = {
   failed: "bg-red-400",
   running: "bg-yellow-400 animate-pulse",
   waiting: "bg-amber-400 animate-pulse",
+  assertion_failed: "bg-orange-400",
 };
 
 interface QuestionOption {
@@ -104,13 +108,37 @@ function PendingQuestionUI({ jobId, questionJson }: { jobId: string; questionJso
 }
 
 function CurrentRunOutput({ run, jobId }: { run: JobRun; jobId: string }) {
+  // Fragments streamed since the run record was last fetched. Finished runs
+  // still stream while a follow-up is answered.
+  const [streamed, setStreamed] = useState("");
+
+  useEffect(() => {
+    setStreamed("");
+    const off = OnEvent("run:progress", (evt) => {
+      const progress = (evt as { data?: RunProgress }).data;
+      if (progress?.runId === run.id) {
+        setStreamed((prev) => prev + progress.fragment);
+      }
+    });
+    return off;
+  }, [run.id, run.output]);
+
+  const output = streamed ? `${run.output}${run.output ? "\n\n" : ""}${streamed}` : run.output;
+
   const html = useMemo(() => {
-    if (!run.output) return "";
-    return marked.parse(run.output, { async: false }) as string;
-  }, [run.output]);
+    if (!output) return "";
+    return marked.parse(output, { async: false }) as string;
+  }, [output]);
 
   const dot = statusDot[run.status] ?? "bg-gray-400";
-  const statusText = run.status === "running" ? "running…" : run.status === "waiting" ? "waiting for input" : run.status;
+  const statusText =
+    run.status === "running"
+      ? "running…"
+      : run.status === "waiting"
+        ? "waiting for input"
+        : run.outcome
+          ? `${run.status} · ${run.outcome === "no_op" ? "no-op" : run.outcome}`
+          : run.status;
 
   return (
     <div className="flex flex-col h-full">
@@ -119,7 +147,7 @@ function CurrentRunOutput({ run, jobId }: { run: JobRun; jobId: string }) {
         <span className="text-sm text-gray-300">{formatTime(run.startedAt)}</span>
         <span className="text-xs text-gray-500">{statusText}</span>
       </div>
-      {run.output ? (
+      {output ? (
         <div className="flex-1 overflow-y-auto">
           <div
             className="p-4 text-sm text-gray-300"
@@ -128,6 +156,7 @@ function CurrentRunOutput({ run, jobId }: { run: JobRun; jobId: string }) {
           {run.status === "waiting" && run.pendingQuestion && (
             <PendingQuestionUI jobId={jobId} questionJson={run.pendingQuestion} />
           )}
+          <FollowUp run={run} />
         </div>
       ) : (
         <div className="flex-1 flex items-center justify-center">
@@ -140,7 +169,7 @@ function CurrentRunOutput({ run, jobId }: { run: JobRun; jobId: string }) {
   );
 }
 
-export default function JobDetail({ job, onEdit, onRunNow }: Props) {
+export default function JobDetail({ job, onEdit, onRunNow, onResetSession }: Props) {
   const [activeTab, setActiveTab] = useState<Tab>("current");
   const [latestRun, setLatestRun] = useState<JobRun | null>(null);
 
@@ -171,6 +200,7 @@ export default function JobDetail({ job, onEdit, onRunNow }: Props) {
   }
 
   const status = statusLabels[job.status];
+  const busy = job.status === "running" || job.status === "waiting";
 
   const tabs: { key: Tab; label: string }[] = [
     { key: "current", label: "Current Run" },
@@ -189,15 +219,29 @@ export default function JobDetail({ job, onEdit, onRunNow }: Props) {
             </span>
             <button
               onClick={() => onRunNow(job.id)}
-              disabled={job.status === "running" || job.status === "waiting"}
+              disabled={busy}
               className={`text-sm px-2 py-1 rounded border transition-colors ${
-                job.status === "running" || job.status === "waiting"
+                busy
                   ? "text-gray-500 border-gray-700 cursor-not-allowed"
                   : "text-green-400 hover:text-green-300 border-gray-600 hover:border-gray-500"
               }`}
             >
               Run Now
             </button>
+            {job.sessionId && (
+              <button
+                onClick={() => onResetSession(job.id)}
+                disabled={busy}
+                title="Start the next run in a new conversation"
+                className={`text-sm px-2 py-1 rounded border transition-colors ${
+                  busy
+                    ? "text-gray-500 border-gray-700 cursor-not-allowed"
+                    : "text-amber-400 hover:text-amber-300 border-gray-600 hover:border-gray-500"
+                }`}
+              >
+                Reset Session
+              </button>
+            )}
             <button
               onClick={onEdit}
               className="text-sm text-blue-400 hover:text-blue-300 px-2 py-1 rounded border border-gray-600 hover:border-gray-500 transition-colors"
@@ -224,6 +268,15 @@ export default function JobDetail({ job, onEdit, onRunNow }: Props) {
           </span>
           <span>Next run: {formatTime(job.nextRun)}</span>
           <span>Last run: {formatTime(job.lastRun)}</span>
+          {job.sessionId && (
+            <span>
+              Session:{" "}
+              <span className="text-gray-300">
+                {job.sessionRuns} {job.sessionRuns === 1 ? "run" : "runs"}
+                {job.sessionTokens > 0 && `, ${formatTokens(job.sessionTokens)} tokens`}
+              </span>
+            </span>
+          )}
         </div>
 
         {/* Prompt (collapsible) */}
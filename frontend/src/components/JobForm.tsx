@@ -1,10 +1,17 @@
 import { useEffect, useState } from "react";
-import { ScheduledJob, IntervalUnit, MCPServer } from "../types";
-import { GetMCPServers, GetMCPServersForJob } from "../wailsbridge";
+import { Assertion, ChangeMode, ScheduledJob, IntervalUnit, MCPServer, SandboxNetwork, SessionPolicy, SystemPromptMode, Webhook } from "../types";
+import { GetExecutors, GetJobWebhookIDs, GetMCPServers, GetMCPServersForJob, GetWebhooks, PreviewPrompt } from "../wailsbridge";
+import { AssertionEditor, KeyValueEditor, ListEditor } from "./FieldEditors";
+
+const executorLabels: Record<string, string> = {
+  claude: "Claude Code CLI",
+  api: "Anthropic API (no tools)",
+  shell: "Shell command",
+};
 
 interface Props {
   job: ScheduledJob | null;
-  onSave: (job: ScheduledJob, mcpServerIds: string[]) => void;
+  onSave: (job: ScheduledJob, mcpServerIds: string[], webhookIds: string[]) => void;
   onCancel: () => void;
   saveError?: string | null;
 }
@@ -18,12 +25,63 @@ export default function JobForm({ job, onSave, onCancel, saveError }: Props) {
   );
   const [prompt, setPrompt] = useState(job?.prompt ?? "");
   const [active, setActive] = useState(job?.active ?? true);
+  const [workingDir, setWorkingDir] = useState(job?.workingDir ?? "");
+  const [worktree, setWorktree] = useState(job?.worktree ?? false);
+  const [sandbox, setSandbox] = useState(job?.sandbox ?? false);
+  const [sandboxNetwork, setSandboxNetwork] = useState<SandboxNetwork>(
+    job?.sandboxNetwork ?? "full"
+  );
+  const [sandboxHosts, setSandboxHosts] = useState(job?.sandboxHosts ?? "[]");
+  const [cpuLimit, setCpuLimit] = useState(job?.cpuLimit ?? 0);
+  const [memoryLimit, setMemoryLimit] = useState(job?.memoryLimit ?? 0);
+  const [processLimit, setProcessLimit] = useState(job?.processLimit ?? 0);
+  const [outputSchema, setOutputSchema] = useState(job?.outputSchema ?? "");
+  const [schemaRetry, setSchemaRetry] = useState(job?.schemaRetry ?? false);
+  const [assertions, setAssertions] = useState(job?.assertions ?? "[]");
+  const [reportOutcome, setReportOutcome] = useState(job?.reportOutcome ?? false);
+  const [notifyOnChange, setNotifyOnChange] = useState<ChangeMode>(job?.notifyOnChange ?? "");
+  const [changeThreshold, setChangeThreshold] = useState(job?.changeThreshold || 0.9);
+  const [addDirs, setAddDirs] = useState(job?.addDirs ?? "[]");
+  const [model, setModel] = useState(job?.model ?? "");
+  const [fallbackModel, setFallbackModel] = useState(job?.fallbackModel ?? "");
+  const [maxTurns, setMaxTurns] = useState(job?.maxTurns ?? 0);
+  const [systemPrompt, setSystemPrompt] = useState(job?.systemPrompt ?? "");
+  const [systemPromptMode, setSystemPromptMode] = useState<SystemPromptMode>(
+    job?.systemPromptMode ?? "append"
+  );
+  const [skipDefaultSystemPrompt, setSkipDefaultSystemPrompt] = useState(
+    job?.skipDefaultSystemPrompt ?? false
+  );
+  const [sessionPolicy, setSessionPolicy] = useState<SessionPolicy>(
+    job?.sessionPolicy ?? "resume"
+  );
+  const [sessionRotateRuns, setSessionRotateRuns] = useState(job?.sessionRotateRuns ?? 0);
+  const [sessionMaxTokens, setSessionMaxTokens] = useState(job?.sessionMaxTokens ?? 0);
+  const [timezone, setTimezone] = useState(job?.timezone ?? "");
+  const [variables, setVariables] = useState(job?.variables ?? "{}");
+  const [env, setEnv] = useState(job?.env ?? "{}");
+  const [executor, setExecutor] = useState(job?.executor ?? "claude");
+  const [executors, setExecutors] = useState<string[]>(["claude"]);
+  const [preview, setPreview] = useState<{ text: string; error: boolean } | null>(null);
   const [errors, setErrors] = useState<Record<string, string>>({});
 
   // MCP server selection state.
   const [allServers, setAllServers] = useState<MCPServer[]>([]);
   const [selectedServerIds, setSelectedServerIds] = useState<Set<string>>(new Set());
 
+  // Webhook routing state.
+  const [allWebhooks, setAllWebhooks] = useState<Webhook[]>([]);
+  const [selectedWebhookIds, setSelectedWebhookIds] = useState<Set<string>>(new Set());
+
+  useEffect(() => {
+    GetExecutors().then((data) => {
+      if (data && data.length > 0) setExecutors(data);
+    });
+  }, []);
+
+  const isClaude = executor === "claude";
+  const usesModel = executor !== "shell";
+
   useEffect(() => {
     GetMCPServers().then((data) => setAllServers(data ?? []));
     if (job?.id) {
@@ -34,6 +92,25 @@ export default function JobForm({ job, onSave, onCancel, saveError }: Props) {
     }
   }, [job?.id]);
 
+  useEffect(() => {
+    GetWebhooks().then((data) => setAllWebhooks(data ?? []));
+    if (job?.id) {
+      GetJobWebhookIDs(job.id).then((ids) => setSelectedWebhookIds(new Set(ids ?? [])));
+    }
+  }, [job?.id]);
+
+  const toggleWebhook = (id: string) => {
+    setSelectedWebhookIds((prev) => {
+      const next = new Set(prev);
+      if (next.has(id)) {
+        next.delete(id);
+      } else {
+        next.add(id);
+      }
+      return next;
+    });
+  };
+
   const toggleServer = (id: string) => {
     setSelectedServerIds((prev) => {
       const next = new Set(prev);
@@ -46,33 +123,106 @@ export default function JobForm({ job, onSave, onCancel, saveError }: Props) {
     });
   };
 
+  const buildJob = (): ScheduledJob => ({
+    id: job?.id ?? "",
+    name: name.trim(),
+    startDate,
+    intervalValue,
+    intervalUnit,
+    prompt,
+    active,
+    nextRun: job?.nextRun ?? "",
+    lastRun: job?.lastRun ?? "",
+    status: job?.status ?? "pending",
+    output: job?.output ?? "",
+    pendingQuestion: job?.pendingQuestion ?? "",
+    workingDir: workingDir.trim(),
+    addDirs,
+    model: model.trim(),
+    fallbackModel: fallbackModel.trim(),
+    maxTurns,
+    systemPrompt,
+    systemPromptMode,
+    skipDefaultSystemPrompt,
+    sessionPolicy,
+    sessionRotateRuns: sessionPolicy === "rotate" ? sessionRotateRuns : 0,
+    sessionMaxTokens: sessionPolicy === "rotate" ? sessionMaxTokens : 0,
+    sessionId: job?.sessionId ?? "",
+    sessionRuns: job?.sessionRuns ?? 0,
+    sessionTokens: job?.sessionTokens ?? 0,
+    timezone: timezone.trim(),
+    variables,
+    executor,
+    env,
+    worktree: executor !== "api" && worktree,
+    sandbox: isClaude && sandbox,
+    sandboxNetwork,
+    sandboxHosts,
+    cpuLimit: isClaude ? cpuLimit : 0,
+    memoryLimit: isClaude ? memoryLimit : 0,
+    processLimit: isClaude ? processLimit : 0,
+    outputSchema: isClaude ? outputSchema.trim() : "",
+    schemaRetry,
+    assertions,
+    reportOutcome,
+    notifyOnChange,
+    changeThreshold: notifyOnChange === "similarity" ? changeThreshold : 0,
+  });
+
+  const handlePreview = async () => {
+    try {
+      const text = await PreviewPrompt(buildJob());
+      setPreview({ text, error: false });
+    } catch (err) {
+      setPreview({ text: err instanceof Error ? err.message : String(err), error: true });
+    }
+  };
+
   const handleSave = () => {
     const errs: Record<string, string> = {};
     if (!name.trim()) errs.name = "Name is required";
     if (!startDate) errs.startDate = "Start date is required";
     if (intervalValue <= 0) errs.intervalValue = "Interval must be greater than 0";
+    if (fallbackModel.trim() && fallbackModel.trim() === model.trim()) {
+      errs.fallbackModel = "Fallback model must differ from the model";
+    }
+    if (systemPromptMode === "replace" && !systemPrompt.trim()) {
+      errs.systemPrompt = "A system prompt is required when replacing the default";
+    }
+    if (isClaude && sessionPolicy === "rotate" && sessionRotateRuns <= 0 && sessionMaxTokens <= 0) {
+      errs.session = "Set a run count or context size to rotate at";
+    }
+    if (executor !== "api" && worktree && !workingDir.trim()) {
+      errs.workingDir = "A working directory is required to run in a worktree";
+    }
+    if (isClaude && sandbox && !workingDir.trim()) {
+      errs.workingDir = "A working directory is required to run in a sandbox";
+    }
+    if (isClaude && outputSchema.trim()) {
+      try {
+        JSON.parse(outputSchema);
+      } catch {
+        errs.outputSchema = "The output schema must be valid JSON";
+      }
+      if (reportOutcome) {
+        errs.reportOutcome = "A job with an output schema cannot report an outcome";
+      }
+    }
+    if (notifyOnChange === "similarity" && !(changeThreshold > 0 && changeThreshold < 1)) {
+      errs.changeThreshold = "The similarity threshold must be between 0 and 1";
+    }
+    const checks: Assertion[] = JSON.parse(assertions);
+    if (checks.some((a) => !a.value.trim())) {
+      errs.assertions = "Every success criterion needs a value";
+    } else if (checks.some((a) => a.kind === "max_cost" && !(parseFloat(a.value) > 0))) {
+      errs.assertions = "A cost limit must be a positive number of dollars";
+    }
     if (Object.keys(errs).length > 0) {
       setErrors(errs);
       return;
     }
 
-    onSave(
-      {
-        id: job?.id ?? "",
-        name: name.trim(),
-        startDate,
-        intervalValue,
-        intervalUnit,
-        prompt,
-        active,
-        nextRun: job?.nextRun ?? "",
-        lastRun: job?.lastRun ?? "",
-        status: job?.status ?? "pending",
-        output: job?.output ?? "",
-        pendingQuestion: job?.pendingQuestion ?? "",
-      },
-      Array.from(selectedServerIds)
-    );
+    onSave(buildJob(), Array.from(selectedServerIds), Array.from(selectedWebhookIds));
   };
 
   return (
@@ -103,6 +253,24 @@ export default function JobForm({ job, onSave, onCancel, saveError }: Props) {
           )}
         </div>
 
+        <div>
+          <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+            Executor
+          </label>
+          <select
+            value={executor}
+            onChange={(e) => setExecutor(e.target.value)}
+            className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+            style={{ colorScheme: "dark" }}
+          >
+            {executors.map((name) => (
+              <option key={name} value={name}>
+                {executorLabels[name] ?? name}
+              </option>
+            ))}
+          </select>
+        </div>
+
         <div>
           <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
             Start Date
@@ -154,18 +322,507 @@ export default function JobForm({ job, onSave, onCancel, saveError }: Props) {
 
         <div>
           <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
-            Prompt
+            {executor === "shell" ? "Command" : "Prompt"}
           </label>
           <textarea
             value={prompt}
-            onChange={(e) => setPrompt(e.target.value)}
+            onChange={(e) => {
+              setPrompt(e.target.value);
+              setPreview(null);
+            }}
             rows={6}
-            placeholder="Enter the Claude instruction for this job..."
+            placeholder={
+              executor === "shell"
+                ? "Enter the shell command to run..."
+                : "Enter the Claude instruction for this job..."
+            }
             className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none resize-y"
           />
+          <div className="mt-1.5 flex items-start justify-between gap-3">
+            <p className="text-xs text-gray-600">
+              Supports template variables such as {"{{.Now}}"}, {"{{.LastRun}}"}, {"{{.LastStatus}}"},{" "}
+              {"{{.LastSummary}}"}, {"{{.Trigger}}"}, {"{{.Payload}}"}, {"{{.ArtifactsDir}}"}, {"{{.JobName}}"} and{" "}
+              {"{{.Vars.name}}"}.
+            </p>
+            <button
+              type="button"
+              onClick={handlePreview}
+              className="shrink-0 text-xs text-blue-400 hover:text-blue-300"
+            >
+              Preview
+            </button>
+          </div>
+          {preview && (
+            <pre
+              className={`mt-2 whitespace-pre-wrap rounded border px-3 py-2 text-xs ${
+                preview.error
+                  ? "text-red-400 bg-red-900/20 border-red-800"
+                  : "text-gray-300 bg-gray-800 border-gray-700"
+              }`}
+            >
+              {preview.text}
+            </pre>
+          )}
+        </div>
+
+        <div className="flex gap-2">
+          <div className="flex-1">
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Prompt Variables
+            </label>
+            <KeyValueEditor
+              value={variables}
+              onChange={(v) => {
+                setVariables(v);
+                setPreview(null);
+              }}
+            />
+          </div>
+          <div className="w-56">
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Time Zone
+            </label>
+            <input
+              type="text"
+              value={timezone}
+              onChange={(e) => {
+                setTimezone(e.target.value);
+                setPreview(null);
+              }}
+              placeholder="System default"
+              className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+            />
+          </div>
+        </div>
+
+        {usesModel && (
+          <>
+            <div>
+              <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+                System Prompt
+              </label>
+              <select
+                value={systemPromptMode}
+                onChange={(e) => {
+                  setSystemPromptMode(e.target.value as SystemPromptMode);
+                  setErrors((prev) => ({ ...prev, systemPrompt: "" }));
+                }}
+                className="w-full mb-2 bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                style={{ colorScheme: "dark" }}
+              >
+                <option value="append">Append to Claude's system prompt</option>
+                <option value="replace">Replace Claude's system prompt</option>
+              </select>
+              <textarea
+                value={systemPrompt}
+                onChange={(e) => {
+                  setSystemPrompt(e.target.value);
+                  setErrors((prev) => ({ ...prev, systemPrompt: "" }));
+                }}
+                rows={3}
+                placeholder="Optional instructions for this job..."
+                className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none resize-y"
+              />
+              {errors.systemPrompt && (
+                <p className="mt-1 text-xs text-red-400">{errors.systemPrompt}</p>
+              )}
+              {isClaude && (
+                <label className="mt-2 flex items-center gap-2 text-xs text-gray-400 cursor-pointer">
+                  <input
+                    type="checkbox"
+                    checked={skipDefaultSystemPrompt}
+                    onChange={(e) => setSkipDefaultSystemPrompt(e.target.checked)}
+                    className="rounded border-gray-600 bg-gray-800 text-blue-500 focus:ring-blue-500 focus:ring-offset-0"
+                  />
+                  Don't include the default system prompt from Settings
+                </label>
+              )}
+            </div>
+
+            <div className="flex gap-2">
+              <div className="flex-1">
+                <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+                  Model
+                </label>
+                <input
+                  type="text"
+                  value={model}
+                  onChange={(e) => setModel(e.target.value)}
+                  placeholder="CLI default"
+                  className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                />
+              </div>
+              <div className="flex-1">
+                <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+                  Fallback Model
+                </label>
+                <input
+                  type="text"
+                  value={fallbackModel}
+                  onChange={(e) => {
+                    setFallbackModel(e.target.value);
+                    setErrors((prev) => ({ ...prev, fallbackModel: "" }));
+                  }}
+                  placeholder="None"
+                  className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                />
+              </div>
+              <div className="w-28">
+                <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+                  Max Turns
+                </label>
+                <input
+                  type="number"
+                  min={0}
+                  value={maxTurns}
+                  onChange={(e) => setMaxTurns(Math.max(0, parseInt(e.target.value, 10) || 0))}
+                  className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                />
+              </div>
+            </div>
+            {errors.fallbackModel && (
+              <p className="-mt-3 text-xs text-red-400">{errors.fallbackModel}</p>
+            )}
+          </>
+        )}
+
+        {isClaude && (
+          <div>
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Session
+            </label>
+            <select
+              value={sessionPolicy}
+              onChange={(e) => {
+                setSessionPolicy(e.target.value as SessionPolicy);
+                setErrors((prev) => ({ ...prev, session: "" }));
+              }}
+              className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+              style={{ colorScheme: "dark" }}
+            >
+              <option value="resume">Continue the same conversation every run</option>
+              <option value="fresh">Start a new conversation every run</option>
+              <option value="rotate">Start a new conversation periodically</option>
+            </select>
+            {sessionPolicy === "rotate" && (
+              <div className="mt-2 flex gap-2">
+                <div className="flex-1">
+                  <label className="block text-xs text-gray-500 mb-1">After runs</label>
+                  <input
+                    type="number"
+                    min={0}
+                    value={sessionRotateRuns}
+                    onChange={(e) => {
+                      setSessionRotateRuns(Math.max(0, parseInt(e.target.value, 10) || 0));
+                      setErrors((prev) => ({ ...prev, session: "" }));
+                    }}
+                    className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                  />
+                </div>
+                <div className="flex-1">
+                  <label className="block text-xs text-gray-500 mb-1">At context tokens</label>
+                  <input
+                    type="number"
+                    min={0}
+                    step={1000}
+                    value={sessionMaxTokens}
+                    onChange={(e) => {
+                      setSessionMaxTokens(Math.max(0, parseInt(e.target.value, 10) || 0));
+                      setErrors((prev) => ({ ...prev, session: "" }));
+                    }}
+                    className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                  />
+                </div>
+              </div>
+            )}
+            {errors.session && (
+              <p className="mt-1 text-xs text-red-400">{errors.session}</p>
+            )}
+            {sessionPolicy === "rotate" && (
+              <p className="mt-1.5 text-xs text-gray-600">
+                Leave a threshold at 0 to ignore it.
+              </p>
+            )}
+          </div>
+        )}
+
+        {executor !== "api" && (
+          <div>
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Working Directory
+            </label>
+            <input
+              type="text"
+              value={workingDir}
+              onChange={(e) => {
+                setWorkingDir(e.target.value);
+                setErrors((prev) => ({ ...prev, workingDir: "" }));
+              }}
+              placeholder="/path/to/project"
+              className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+            />
+            {errors.workingDir && (
+              <p className="mt-1 text-xs text-red-400">{errors.workingDir}</p>
+            )}
+            <p className="mt-1.5 text-xs text-gray-600">
+              {isClaude
+                ? "Claude runs here and picks up the project's CLAUDE.md and .mcp.json. Leave empty to use the app's directory."
+                : "The command runs here. Leave empty to use the app's directory."}
+            </p>
+            <label className="mt-2 flex items-center gap-2 text-xs text-gray-400 cursor-pointer">
+              <input
+                type="checkbox"
+                checked={worktree}
+                onChange={(e) => {
+                  setWorktree(e.target.checked);
+                  setErrors((prev) => ({ ...prev, workingDir: "" }));
+                }}
+                className="rounded border-gray-600 bg-gray-800 text-blue-500 focus:ring-blue-500 focus:ring-offset-0"
+              />
+              Run in a fresh git worktree on a branch of its own
+            </label>
+            {worktree && (
+              <p className="mt-1.5 text-xs text-gray-600">
+                The directory must be a git repository. Each run starts from its current commit and
+                leaves its changes on a branch you can merge, keep or discard afterwards. Runs never
+                resume an earlier conversation.
+              </p>
+            )}
+          </div>
+        )}
+
+        {executor !== "api" && (
+          <div>
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Environment Variables
+            </label>
+            <KeyValueEditor value={env} onChange={setEnv} />
+          </div>
+        )}
+
+        {isClaude && (
+          <div>
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Additional Directories
+            </label>
+            <ListEditor value={addDirs} onChange={setAddDirs} placeholder="/path/to/other/dir" />
+          </div>
+        )}
+
+        {isClaude && (
+          <div>
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Sandbox
+            </label>
+            <label className="flex items-center gap-2 text-xs text-gray-400 cursor-pointer">
+              <input
+                type="checkbox"
+                checked={sandbox}
+                onChange={(e) => {
+                  setSandbox(e.target.checked);
+                  setErrors((prev) => ({ ...prev, workingDir: "" }));
+                }}
+                className="rounded border-gray-600 bg-gray-800 text-blue-500 focus:ring-blue-500 focus:ring-offset-0"
+              />
+              Run Claude in a bubblewrap sandbox (Linux only)
+            </label>
+            {sandbox && (
+              <>
+                <p className="mt-1.5 text-xs text-gray-600">
+                  Everything outside the working directory, the additional directories and Claude's own
+                  settings is read-only, and TMPDIR points at a scratch directory. What the sandbox
+                  blocks is listed in the run output.
+                </p>
+                <select
+                  value={sandboxNetwork}
+                  onChange={(e) => setSandboxNetwork(e.target.value as SandboxNetwork)}
+                  className="mt-2 w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                >
+                  <option value="full">Full network access</option>
+                  <option value="restricted">Anthropic API and allowed hosts only</option>
+                </select>
+                {sandboxNetwork === "restricted" && (
+                  <div className="mt-2">
+                    <ListEditor
+                      value={sandboxHosts}
+                      onChange={setSandboxHosts}
+                      placeholder="api.github.com or *.example.com"
+                    />
+                    <p className="mt-1.5 text-xs text-gray-600">
+                      Connections go through a proxy that only lets these hosts through. Tools that
+                      ignore HTTPS_PROXY have no network at all.
+                    </p>
+                  </div>
+                )}
+              </>
+            )}
+          </div>
+        )}
+
+        {isClaude && (
+          <div>
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Output Schema
+            </label>
+            <textarea
+              value={outputSchema}
+              onChange={(e) => {
+                setOutputSchema(e.target.value);
+                setErrors((prev) => ({ ...prev, outputSchema: "" }));
+              }}
+              rows={4}
+              placeholder='{"type": "object", "properties": {"changed": {"type": "boolean"}}, "required": ["changed"]}'
+              className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 font-mono focus:border-blue-500 focus:outline-none resize-y"
+            />
+            {errors.outputSchema && (
+              <p className="mt-1 text-xs text-red-400">{errors.outputSchema}</p>
+            )}
+            <p className="mt-1.5 text-xs text-gray-600">
+              A JSON Schema the final result must match. Claude is asked to end with a JSON value,
+              which is stored with the run; a run whose result does not match fails.
+            </p>
+            {outputSchema.trim() && (
+              <label className="mt-2 flex items-center gap-2 text-xs text-gray-400 cursor-pointer">
+                <input
+                  type="checkbox"
+                  checked={schemaRetry}
+                  onChange={(e) => setSchemaRetry(e.target.checked)}
+                  className="rounded border-gray-600 bg-gray-800 text-blue-500 focus:ring-blue-500 focus:ring-offset-0"
+                />
+                Ask once more, with the problems, when the result does not match
+              </label>
+            )}
+          </div>
+        )}
+
+        <div>
+          <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+            Success Criteria
+          </label>
+          <AssertionEditor
+            value={assertions}
+            onChange={(v) => {
+              setAssertions(v);
+              setErrors((prev) => ({ ...prev, assertions: "" }));
+            }}
+          />
+          {errors.assertions && (
+            <p className="mt-1 text-xs text-red-400">{errors.assertions}</p>
+          )}
+          <p className="mt-1.5 text-xs text-gray-600">
+            Checked against the final result once a run finishes. A run that fails any of them is
+            marked as failing its checks. A judge asks the job's model whether the result meets
+            the criterion you describe.
+          </p>
         </div>
 
-        {allServers.length > 0 && (
+        <div>
+          <label className="flex items-center gap-2 text-sm text-gray-300 cursor-pointer">
+            <input
+              type="checkbox"
+              checked={reportOutcome}
+              onChange={(e) => setReportOutcome(e.target.checked)}
+              className="rounded border-gray-600 bg-gray-800 text-blue-500 focus:ring-blue-500 focus:ring-offset-0"
+            />
+            Notify only when something changed
+          </label>
+          {errors.reportOutcome && (
+            <p className="mt-1 text-xs text-red-400">{errors.reportOutcome}</p>
+          )}
+          <p className="mt-1.5 text-xs text-gray-600">
+            {executor === "shell"
+              ? "End the script's output with a line OUTCOME: changed, OUTCOME: unchanged or OUTCOME: no-op."
+              : "Claude is asked to end with OUTCOME: changed, unchanged or no-op."}{" "}
+            Runs reporting unchanged or no-op are recorded without a notification.
+          </p>
+        </div>
+
+        <div>
+          <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+            Notify on Change
+          </label>
+          <div className="flex gap-2 items-center">
+            <select
+              value={notifyOnChange}
+              onChange={(e) => setNotifyOnChange(e.target.value as ChangeMode)}
+              className="flex-1 bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+            >
+              <option value="">Always notify</option>
+              <option value="exact">When the result differs at all</option>
+              <option value="whitespace">When the result differs, ignoring whitespace</option>
+              <option value="similarity">When the result is less similar than…</option>
+            </select>
+            {notifyOnChange === "similarity" && (
+              <input
+                type="number"
+                min={0.01}
+                max={0.99}
+                step={0.05}
+                value={changeThreshold}
+                onChange={(e) => {
+                  setChangeThreshold(parseFloat(e.target.value) || 0);
+                  setErrors((prev) => ({ ...prev, changeThreshold: "" }));
+                }}
+                className="w-24 bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+              />
+            )}
+          </div>
+          {errors.changeThreshold && (
+            <p className="mt-1 text-xs text-red-400">{errors.changeThreshold}</p>
+          )}
+          <p className="mt-1.5 text-xs text-gray-600">
+            Compares the final result with the last successful run's. Unchanged runs are recorded
+            without a notification; the notification for a change shows what changed.
+          </p>
+        </div>
+
+        {isClaude && (
+          <div>
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Resource Limits
+            </label>
+            <div className="flex gap-2">
+              <div className="flex-1">
+                <label className="block text-xs text-gray-500 mb-1">CPU seconds</label>
+                <input
+                  type="number"
+                  min={0}
+                  value={cpuLimit}
+                  onChange={(e) => setCpuLimit(Math.max(0, parseInt(e.target.value, 10) || 0))}
+                  className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                />
+              </div>
+              <div className="flex-1">
+                <label className="block text-xs text-gray-500 mb-1">Memory (MB)</label>
+                <input
+                  type="number"
+                  min={0}
+                  step={256}
+                  value={memoryLimit}
+                  onChange={(e) => setMemoryLimit(Math.max(0, parseInt(e.target.value, 10) || 0))}
+                  className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                />
+              </div>
+              <div className="flex-1">
+                <label className="block text-xs text-gray-500 mb-1">Processes</label>
+                <input
+                  type="number"
+                  min={0}
+                  value={processLimit}
+                  onChange={(e) => setProcessLimit(Math.max(0, parseInt(e.target.value, 10) || 0))}
+                  className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+                />
+              </div>
+            </div>
+            <p className="mt-1.5 text-xs text-gray-600">
+              Apply to Claude and everything it starts (Linux only). Leave a limit at 0 to ignore it.
+              Without a delegated cgroup v2 cgroup the CPU limit applies to each process separately,
+              the memory limit is checked every second and a process limit cannot be set.
+            </p>
+          </div>
+        )}
+
+        {isClaude && allServers.length > 0 && (
           <div>
             <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
               MCP Servers
@@ -195,6 +852,39 @@ export default function JobForm({ job, onSave, onCancel, saveError }: Props) {
           </div>
         )}
 
+        {allWebhooks.length > 0 && (
+          <div>
+            <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
+              Webhooks
+            </label>
+            <div className="space-y-1.5">
+              {allWebhooks.map((w) => (
+                <label
+                  key={w.id}
+                  className={`flex items-center gap-2 px-3 py-2 rounded border border-gray-700 transition-colors ${
+                    w.allJobs ? "opacity-60" : "hover:border-gray-600 cursor-pointer"
+                  }`}
+                >
+                  <input
+                    type="checkbox"
+                    checked={w.allJobs || selectedWebhookIds.has(w.id)}
+                    disabled={w.allJobs}
+                    onChange={() => toggleWebhook(w.id)}
+                    className="rounded border-gray-600 bg-gray-800 text-blue-500 focus:ring-blue-500 focus:ring-offset-0"
+                  />
+                  <span className="text-sm text-gray-200">{w.name}</span>
+                  <span className="text-xs px-1.5 py-0.5 rounded bg-gray-800 text-gray-500 border border-gray-700">
+                    {w.allJobs ? "all jobs" : w.format}
+                  </span>
+                </label>
+              ))}
+            </div>
+            <p className="mt-1.5 text-xs text-gray-600">
+              Selected webhooks are sent this job's events. Webhooks for all jobs always are.
+            </p>
+          </div>
+        )}
+
         <div>
           <label className="block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5">
             Status
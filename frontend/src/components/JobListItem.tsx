@@ -13,6 +13,7 @@ const statusColors: Record<JobStatus, string> = {
   running: "bg-yellow-500 animate-pulse",
   pending: "bg-gray-500",
   waiting: "bg-amber-500 animate-pulse",
+  assertion_failed: "bg-orange-500",
 };
 
 export default function JobListItem({ job, isSelected, onSelect }: Props) {
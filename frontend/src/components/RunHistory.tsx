@@ -1,8 +1,20 @@
 import { useEffect, useMemo, useState } from "react";
 import { marked } from "marked";
-import { GetRunsForJob, OnEvent } from "../wailsbridge";
-import { JobRun } from "../types";
-import { formatTime } from "../utils";
+import {
+  DiscardRunWorktree,
+  GetRunArtifacts,
+  GetRunDiff,
+  GetRunRawEvents,
+  GetRunsForJob,
+  KeepRunWorktree,
+  MergeRunWorktree,
+  OnEvent,
+  OpenRunArtifact,
+  RerenderRun,
+} from "../wailsbridge";
+import { JobRun, RunArtifact, WorktreeCommit } from "../types";
+import { formatBytes, formatCost, formatTime } from "../utils";
+import FollowUp from "./FollowUp";
 
 interface Props {
   jobId: string;
@@ -12,6 +24,27 @@ const statusDot: Record<string, string> = {
   success: "bg-green-400",
   failed: "bg-red-400",
   running: "bg-yellow-400 animate-pulse",
+  assertion_failed: "bg-orange-400",
+};
+
+// Successful runs that report an outcome show it in place of plain success.
+const outcomeDot: Record<string, string> = {
+  changed: "bg-blue-400",
+  unchanged: "bg-gray-500",
+  no_op: "bg-gray-600",
+};
+
+const outcomeLabels: Record<string, string> = {
+  changed: "changed",
+  unchanged: "unchanged",
+  no_op: "no-op",
+};
+
+const failureLabels: Record<string, string> = {
+  cpu_limit: "CPU limit",
+  memory_limit: "memory limit",
+  process_limit: "process limit",
+  output_schema: "schema mismatch",
 };
 
 function duration(start: string, end: string): string {
@@ -50,6 +83,298 @@ function RunOutput({ output }: { output: string }) {
   );
 }
 
+// RunArtifacts lists the files the run wrote to its artifacts directory.
+function RunArtifacts({ run }: { run: JobRun }) {
+  const [artifacts, setArtifacts] = useState<RunArtifact[]>([]);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    GetRunArtifacts(run.id).then((data) => setArtifacts(data ?? []));
+    // Follow-ups can add files; they update the run's output too.
+  }, [run.id, run.status, run.output]);
+
+  if (artifacts.length === 0) {
+    return null;
+  }
+
+  const handleOpen = async (name: string) => {
+    setError(null);
+    try {
+      await OpenRunArtifact(run.id, name);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    }
+  };
+
+  return (
+    <div className="border-t border-gray-700 px-3 py-2">
+      <p className="text-xs text-gray-500 mb-1">Artifacts</p>
+      <ul className="space-y-0.5">
+        {artifacts.map((a) => (
+          <li key={a.name} className="flex items-center gap-3 text-xs">
+            <button
+              onClick={() => handleOpen(a.name)}
+              className="text-blue-400 hover:text-blue-300 truncate text-left"
+              title={`${a.mimeType}\nSHA-256 ${a.sha256}`}
+            >
+              {a.name}
+            </button>
+            <span className="text-gray-500 shrink-0">{formatBytes(a.size)}</span>
+          </li>
+        ))}
+      </ul>
+      {error && <p className="text-xs text-red-400 mt-1">{error}</p>}
+    </div>
+  );
+}
+
+// RunStructuredOutput shows the JSON result of a run of a job with an output
+// schema.
+function RunStructuredOutput({ run }: { run: JobRun }) {
+  const pretty = useMemo(() => {
+    try {
+      return JSON.stringify(JSON.parse(run.structuredOutput), null, 2);
+    } catch {
+      return run.structuredOutput;
+    }
+  }, [run.structuredOutput]);
+
+  if (!run.structuredOutput) {
+    return null;
+  }
+
+  return (
+    <div className="border-t border-gray-700 px-3 py-2">
+      <p className="text-xs text-gray-500 mb-1">Structured output</p>
+      <pre className="text-xs text-gray-300 bg-gray-950 rounded p-2 overflow-x-auto max-h-64">{pretty}</pre>
+    </div>
+  );
+}
+
+// RunResultDiff shows how the run's result differs from the previous
+// successful run's, for jobs that compare results.
+function RunResultDiff({ run }: { run: JobRun }) {
+  const [diff, setDiff] = useState("");
+  const [show, setShow] = useState(false);
+
+  useEffect(() => {
+    if (run.status !== "success") {
+      setDiff("");
+      return;
+    }
+    GetRunDiff(run.id).then((data) => setDiff(data ?? ""));
+  }, [run.id, run.status]);
+
+  if (!diff) {
+    return null;
+  }
+
+  return (
+    <div className="border-t border-gray-700 px-3 py-2">
+      <button onClick={() => setShow(!show)} className="text-xs text-gray-400 hover:text-gray-200">
+        {show ? "Hide changes since the previous run" : "Show changes since the previous run"}
+      </button>
+      {show && (
+        <pre className="mt-1 text-xs bg-gray-950 rounded p-2 overflow-x-auto max-h-96">
+          {diff.split("\n").map((line, i) => (
+            <div
+              key={i}
+              className={
+                line.startsWith("+") && !line.startsWith("+++")
+                  ? "text-green-400"
+                  : line.startsWith("-") && !line.startsWith("---")
+                    ? "text-red-400"
+                    : "text-gray-400"
+              }
+            >
+              {line || " "}
+            </div>
+          ))}
+        </pre>
+      )}
+    </div>
+  );
+}
+
+// RunAssertionFailures lists the success criteria a run failed.
+function RunAssertionFailures({ run }: { run: JobRun }) {
+  const failures = useMemo(() => {
+    try {
+      const parsed = JSON.parse(run.assertionFailures || "[]");
+      return Array.isArray(parsed) ? parsed.map(String) : [];
+    } catch {
+      return [];
+    }
+  }, [run.assertionFailures]);
+
+  if (failures.length === 0) {
+    return null;
+  }
+
+  return (
+    <div className="border-t border-gray-700 px-3 py-2">
+      <p className="text-xs text-gray-500 mb-1">Failed success criteria</p>
+      <ul className="space-y-0.5">
+        {failures.map((f, i) => (
+          <li key={i} className="text-xs text-orange-300">
+            {f}
+          </li>
+        ))}
+      </ul>
+    </div>
+  );
+}
+
+const worktreeStateLabel: Record<string, string> = {
+  kept: "Worktree removed, branch kept",
+  discarded: "Discarded",
+  merged: "Merged",
+};
+
+// RunWorktree shows the branch a worktree run left behind and lets the user
+// decide what happens to it.
+function RunWorktree({ run }: { run: JobRun }) {
+  const [error, setError] = useState<string | null>(null);
+  const [busy, setBusy] = useState(false);
+  const [showDiff, setShowDiff] = useState(false);
+  const commits = useMemo<WorktreeCommit[]>(() => {
+    try {
+      return JSON.parse(run.commits || "[]") ?? [];
+    } catch {
+      return [];
+    }
+  }, [run.commits]);
+
+  if (!run.worktreeState) {
+    return null;
+  }
+  const active = run.worktreeState === "active";
+  const inProgress = run.status === "running" || run.status === "waiting";
+
+  const act = async (action: (runId: string) => Promise<void>) => {
+    setError(null);
+    setBusy(true);
+    try {
+      await action(run.id);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  return (
+    <div className="border-t border-gray-700 px-3 py-2 space-y-1">
+      <p className="text-xs text-gray-500">
+        Branch <code className="text-gray-300">{run.branch}</code>
+        {!active && <> · {worktreeStateLabel[run.worktreeState] ?? run.worktreeState}</>}
+      </p>
+      {commits.length > 0 ? (
+        <ul className="space-y-0.5">
+          {commits.map((c) => (
+            <li key={c.hash} className="text-xs text-gray-300 truncate" title={`${c.author}, ${formatTime(c.date)}`}>
+              <code className="text-gray-500">{c.hash.slice(0, 8)}</code> {c.subject}
+            </li>
+          ))}
+        </ul>
+      ) : (
+        !inProgress && <p className="text-xs text-gray-500 italic">No changes.</p>
+      )}
+      {run.diff && (
+        <>
+          <button onClick={() => setShowDiff(!showDiff)} className="text-xs text-gray-400 hover:text-gray-200">
+            {showDiff ? "Hide diff" : "Show diff"}
+          </button>
+          {showDiff && (
+            <pre className="text-xs text-gray-300 bg-gray-950 rounded p-2 overflow-x-auto max-h-96">{run.diff}</pre>
+          )}
+        </>
+      )}
+      {active && !inProgress && (
+        <div className="flex items-center gap-3 pt-1">
+          {commits.length > 0 && (
+            <button
+              onClick={() => act(MergeRunWorktree)}
+              disabled={busy}
+              className="text-xs text-blue-400 hover:text-blue-300 disabled:opacity-50"
+            >
+              Merge
+            </button>
+          )}
+          <button
+            onClick={() => act(KeepRunWorktree)}
+            disabled={busy}
+            className="text-xs text-gray-400 hover:text-gray-200 disabled:opacity-50"
+          >
+            Keep branch
+          </button>
+          <button
+            onClick={() => act(DiscardRunWorktree)}
+            disabled={busy}
+            className="text-xs text-red-400 hover:text-red-300 disabled:opacity-50"
+          >
+            Discard
+          </button>
+        </div>
+      )}
+      {error && <p className="text-xs text-red-400">{error}</p>}
+    </div>
+  );
+}
+
+// RunActions offers the run's stored stream-json output for download and
+// renders the run again from it.
+function RunActions({ run }: { run: JobRun }) {
+  const [error, setError] = useState<string | null>(null);
+
+  if (run.status === "running" || run.status === "waiting") {
+    return null;
+  }
+
+  const handleDownload = async () => {
+    setError(null);
+    try {
+      const lines = (await GetRunRawEvents(run.id)) ?? [];
+      if (lines.length === 0) {
+        setError("No raw events stored for this run.");
+        return;
+      }
+      const blob = new Blob([lines.join("\n") + "\n"], { type: "application/x-ndjson" });
+      const url = URL.createObjectURL(blob);
+      const a = document.createElement("a");
+      a.href = url;
+      a.download = `run-${run.id}.jsonl`;
+      a.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    }
+  };
+
+  const handleRerender = async () => {
+    setError(null);
+    try {
+      await RerenderRun(run.id);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    }
+  };
+
+  return (
+    <div className="border-t border-gray-700 px-3 py-1.5 flex items-center gap-3">
+      <button onClick={handleDownload} className="text-xs text-gray-400 hover:text-gray-200">
+        Raw events
+      </button>
+      {run.status !== "failed" && (
+        <button onClick={handleRerender} className="text-xs text-gray-400 hover:text-gray-200">
+          Re-render
+        </button>
+      )}
+      {error && <span className="text-xs text-red-400">{error}</span>}
+    </div>
+  );
+}
+
 export default function RunHistory({ jobId }: Props) {
   const [runs, setRuns] = useState<JobRun[]>([]);
   const [expandedId, setExpandedId] = useState<string | null>(null);
@@ -82,7 +407,7 @@ export default function RunHistory({ jobId }: Props) {
     <div className="space-y-2">
       {runs.map((run) => {
         const isExpanded = expandedId === run.id;
-        const dot = statusDot[run.status] ?? "bg-gray-400";
+        const dot = (run.outcome && outcomeDot[run.outcome]) || statusDot[run.status] || "bg-gray-400";
         return (
           <div key={run.id} className="border border-gray-700 rounded">
             <button
@@ -93,6 +418,33 @@ export default function RunHistory({ jobId }: Props) {
               <span className="text-xs text-gray-300 flex-1">
                 {formatTime(run.startedAt)}
               </span>
+              {run.outcome && (
+                <span
+                  className={`text-xs px-1.5 py-0.5 rounded border ${
+                    run.outcome === "changed"
+                      ? "bg-blue-900/40 text-blue-300 border-blue-800"
+                      : "bg-gray-800 text-gray-400 border-gray-700"
+                  }`}
+                >
+                  {outcomeLabels[run.outcome]}
+                </span>
+              )}
+              {run.failureReason && (
+                <span className="text-xs px-1.5 py-0.5 rounded bg-red-900/40 text-red-300 border border-red-800">
+                  {failureLabels[run.failureReason]}
+                </span>
+              )}
+              {run.model && (
+                <span className="text-xs text-gray-500">{run.model}</span>
+              )}
+              {run.costUsd > 0 && (
+                <span
+                  className="text-xs text-gray-500"
+                  title={`${run.numTurns} turns · ${run.inputTokens} in / ${run.outputTokens} out tokens`}
+                >
+                  {formatCost(run.costUsd)}
+                </span>
+              )}
               <span className="text-xs text-gray-500">
                 {run.status === "running" ? "running…" : duration(run.startedAt, run.endedAt)}
               </span>
@@ -101,7 +453,16 @@ export default function RunHistory({ jobId }: Props) {
               </span>
             </button>
             {isExpanded && (
-              <RunOutput output={run.output} />
+              <>
+                <RunAssertionFailures run={run} />
+                <RunOutput output={run.output} />
+                <RunStructuredOutput run={run} />
+                <RunResultDiff run={run} />
+                <RunWorktree run={run} />
+                <RunArtifacts run={run} />
+                <RunActions run={run} />
+                <FollowUp run={run} />
+              </>
             )}
           </div>
         );
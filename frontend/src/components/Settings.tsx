@@ -0,0 +1,158 @@
+import { useEffect, useState } from "react";
+import { DetectClaudePath, GetSettings, UpdateSettings } from "../wailsbridge";
+import { KeyValueEditor, ListEditor } from "./FieldEditors";
+import type { Settings as AppSettings } from "../types";
+
+const emptySettings: AppSettings = {
+  defaultSystemPrompt: "",
+  anthropicApiKey: "",
+  claudePath: "",
+  claudeArgs: "[]",
+  claudeEnv: "{}",
+};
+
+interface Props {
+  onClose: () => void;
+}
+
+export default function Settings({ onClose }: Props) {
+  const [settings, setSettings] = useState<AppSettings>(emptySettings);
+  const [error, setError] = useState<string | null>(null);
+  const [saved, setSaved] = useState(false);
+
+  useEffect(() => {
+    GetSettings().then((data) => {
+      setSettings({ ...emptySettings, ...data });
+    });
+  }, []);
+
+  const handleSave = async () => {
+    setError(null);
+    setSaved(false);
+    try {
+      await UpdateSettings(settings);
+      setSaved(true);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    }
+  };
+
+  const update = (patch: Partial<AppSettings>) => {
+    setSettings((prev) => ({ ...prev, ...patch }));
+    setSaved(false);
+  };
+
+  const handleDetect = async () => {
+    setError(null);
+    try {
+      update({ claudePath: await DetectClaudePath() });
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    }
+  };
+
+  const labelClass =
+    "block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5";
+
+  return (
+    <div className="h-full flex flex-col bg-gray-900">
+      <div className="px-6 py-4 border-b border-gray-700 flex items-center justify-between">
+        <h1 className="text-lg font-semibold text-gray-100">Settings</h1>
+        <button
+          onClick={onClose}
+          className="text-gray-400 hover:text-gray-200 transition-colors text-sm"
+        >
+          Back
+        </button>
+      </div>
+
+      <div className="flex-1 overflow-y-auto p-6 space-y-5">
+        {error && (
+          <p className="text-sm text-red-400 bg-red-900/20 border border-red-800 rounded px-3 py-2">
+            {error}
+          </p>
+        )}
+
+        <div>
+          <label className={labelClass}>Default System Prompt</label>
+          <textarea
+            value={settings.defaultSystemPrompt}
+            onChange={(e) => update({ defaultSystemPrompt: e.target.value })}
+            rows={6}
+            placeholder="Appended to every Claude CLI job's system prompt unless the job opts out..."
+            className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none resize-y"
+          />
+          <p className="mt-1.5 text-xs text-gray-600">
+            Leave empty to run jobs with only their own system prompt. Anthropic API jobs never get it.
+          </p>
+        </div>
+
+        <div>
+          <label className={labelClass}>Claude CLI Path</label>
+          <div className="flex gap-2">
+            <input
+              type="text"
+              value={settings.claudePath}
+              onChange={(e) => update({ claudePath: e.target.value })}
+              placeholder="Detected automatically"
+              className="flex-1 bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+            />
+            <button
+              onClick={handleDetect}
+              className="px-3 py-2 rounded text-sm font-medium bg-gray-700 text-gray-300 hover:bg-gray-600 transition-colors"
+            >
+              Detect
+            </button>
+          </div>
+          <p className="mt-1.5 text-xs text-gray-600">
+            Leave empty to look on PATH and in common install locations (native installer, npm, nvm, Homebrew, bun).
+          </p>
+        </div>
+
+        <div>
+          <label className={labelClass}>Extra CLI Arguments</label>
+          <ListEditor
+            value={settings.claudeArgs || "[]"}
+            onChange={(claudeArgs) => update({ claudeArgs })}
+            placeholder="--flag"
+          />
+        </div>
+
+        <div>
+          <label className={labelClass}>CLI Environment Variables</label>
+          <KeyValueEditor
+            value={settings.claudeEnv || "{}"}
+            onChange={(claudeEnv) => update({ claudeEnv })}
+          />
+          <p className="mt-1.5 text-xs text-gray-600">
+            Applied to every Claude CLI run. A job's own environment takes precedence.
+          </p>
+        </div>
+
+        <div>
+          <label className={labelClass}>Anthropic API Key</label>
+          <input
+            type="password"
+            value={settings.anthropicApiKey}
+            onChange={(e) => update({ anthropicApiKey: e.target.value })}
+            placeholder="sk-ant-..."
+            className="w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+          />
+          <p className="mt-1.5 text-xs text-gray-600">
+            Used by jobs that run with the Anthropic API executor. Falls back to the ANTHROPIC_API_KEY environment variable.
+          </p>
+        </div>
+      </div>
+
+      <div className="px-6 py-4 border-t border-gray-700 flex gap-3 justify-end items-center">
+        {saved && <span className="text-xs text-green-400">Saved</span>}
+        <button
+          onClick={handleSave}
+          className="px-4 py-2 rounded text-sm font-medium bg-blue-600 text-white hover:bg-blue-700 transition-colors"
+        >
+          Save
+        </button>
+      </div>
+    </div>
+  );
+}
@@ -0,0 +1,96 @@
+import { useEffect, useState } from "react";
+import { GetUsageStats } from "../wailsbridge";
+import { JobUsage } from "../types";
+import { formatCost, formatTokens } from "../utils";
+
+interface Props {
+  onClose: () => void;
+}
+
+const ranges: { label: string; days: number }[] = [
+  { label: "Last 24 hours", days: 1 },
+  { label: "Last 7 days", days: 7 },
+  { label: "Last 30 days", days: 30 },
+  { label: "All time", days: 0 },
+];
+
+export default function UsageStats({ onClose }: Props) {
+  const [days, setDays] = useState(7);
+  const [stats, setStats] = useState<JobUsage[]>([]);
+
+  useEffect(() => {
+    const from = days > 0 ? new Date(Date.now() - days * 24 * 60 * 60 * 1000).toISOString().replace(/\.\d+Z$/, "Z") : "";
+    GetUsageStats(from, "").then((data) => setStats(data ?? []));
+  }, [days]);
+
+  const total = stats.reduce((sum, s) => sum + s.costUsd, 0);
+
+  return (
+    <div className="h-full flex flex-col bg-gray-900">
+      <div className="px-6 py-4 border-b border-gray-700 flex items-center justify-between">
+        <h1 className="text-lg font-semibold text-gray-100">Usage</h1>
+        <button
+          onClick={onClose}
+          className="text-gray-400 hover:text-gray-200 transition-colors text-sm"
+        >
+          Back
+        </button>
+      </div>
+
+      <div className="flex-1 overflow-y-auto p-6">
+        <div className="mb-4 flex items-center justify-between">
+          <select
+            value={days}
+            onChange={(e) => setDays(parseInt(e.target.value, 10))}
+            className="bg-gray-800 border border-gray-600 rounded px-3 py-1.5 text-sm text-gray-100 focus:border-blue-500 focus:outline-none"
+            style={{ colorScheme: "dark" }}
+          >
+            {ranges.map((r) => (
+              <option key={r.days} value={r.days}>
+                {r.label}
+              </option>
+            ))}
+          </select>
+          <span className="text-sm text-gray-300">
+            Total: <span className="font-semibold">{formatCost(total)}</span>
+          </span>
+        </div>
+
+        {stats.length === 0 ? (
+          <p className="text-sm text-gray-500 italic">No runs in this period.</p>
+        ) : (
+          <table className="w-full text-sm">
+            <thead>
+              <tr className="text-xs text-gray-500 uppercase tracking-wider text-left">
+                <th className="py-2 font-semibold">Job</th>
+                <th className="py-2 font-semibold text-right">Runs</th>
+                <th className="py-2 font-semibold text-right">Turns</th>
+                <th className="py-2 font-semibold text-right">Input</th>
+                <th className="py-2 font-semibold text-right">Output</th>
+                <th className="py-2 font-semibold text-right">Cost</th>
+              </tr>
+            </thead>
+            <tbody>
+              {stats.map((s) => (
+                <tr key={s.jobId} className="border-t border-gray-800 text-gray-300">
+                  <td className="py-2">
+                    {s.jobName || <span className="italic text-gray-500">unnamed job</span>}
+                    {s.deleted && <span className="ml-1.5 text-xs italic text-gray-500">deleted</span>}
+                  </td>
+                  <td className="py-2 text-right">{s.runs}</td>
+                  <td className="py-2 text-right">{s.numTurns}</td>
+                  <td className="py-2 text-right">{formatTokens(s.inputTokens + s.cacheReadTokens + s.cacheCreationTokens)}</td>
+                  <td className="py-2 text-right">{formatTokens(s.outputTokens)}</td>
+                  <td className="py-2 text-right">{formatCost(s.costUsd)}</td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+        <p className="mt-4 text-xs text-gray-600">
+          Only runs still kept in each job's history are counted.
+        </p>
+      </div>
+    </div>
+  );
+}
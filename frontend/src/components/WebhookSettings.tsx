@@ -0,0 +1,431 @@
+import { useEffect, useState } from "react";
+import { DeliveryState, Webhook, WebhookDelivery, WebhookFormat } from "../types";
+import {
+  GetWebhooks,
+  CreateWebhook,
+  UpdateWebhook,
+  DeleteWebhook,
+  TestWebhook,
+  GetWebhookDeliveries,
+} from "../wailsbridge";
+import { formatTime } from "../utils";
+import { KeyValueEditor } from "./FieldEditors";
+
+const emptyWebhook: Webhook = {
+  id: "",
+  name: "",
+  url: "",
+  format: "json",
+  events: '["success","failed","assertion_failed","waiting"]',
+  headers: "{}",
+  allJobs: false,
+};
+
+const eventLabels: Record<string, string> = {
+  running: "Started",
+  success: "Succeeded",
+  failed: "Failed",
+  assertion_failed: "Failed its checks",
+  waiting: "Waiting for input",
+};
+
+const formatLabels: Record<WebhookFormat, string> = {
+  json: "JSON",
+  slack: "Slack",
+  discord: "Discord",
+};
+
+const stateColors: Record<DeliveryState, string> = {
+  pending: "text-yellow-400",
+  delivered: "text-green-400",
+  failed: "text-red-400",
+};
+
+interface Props {
+  onClose: () => void;
+}
+
+export default function WebhookSettings({ onClose }: Props) {
+  const [webhooks, setWebhooks] = useState<Webhook[]>([]);
+  const [editing, setEditing] = useState<Webhook | null>(null);
+  const [viewing, setViewing] = useState<Webhook | null>(null);
+  const [error, setError] = useState<string | null>(null);
+
+  const refresh = () => {
+    GetWebhooks().then((data) => setWebhooks(data ?? []));
+  };
+
+  useEffect(() => {
+    refresh();
+  }, []);
+
+  const handleSave = async (webhook: Webhook) => {
+    setError(null);
+    try {
+      if (webhook.id) {
+        await UpdateWebhook(webhook);
+      } else {
+        await CreateWebhook(webhook);
+      }
+      setEditing(null);
+      refresh();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    }
+  };
+
+  const handleDelete = async (id: string) => {
+    setError(null);
+    try {
+      await DeleteWebhook(id);
+      refresh();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    }
+  };
+
+  return (
+    <div className="h-full flex flex-col bg-gray-900">
+      <div className="px-6 py-4 border-b border-gray-700 flex items-center justify-between">
+        <h1 className="text-lg font-semibold text-gray-100">Webhooks</h1>
+        <button
+          onClick={onClose}
+          className="text-gray-400 hover:text-gray-200 transition-colors text-sm"
+        >
+          Back
+        </button>
+      </div>
+
+      <div className="flex-1 overflow-y-auto p-6">
+        {error && (
+          <p className="mb-4 text-sm text-red-400 bg-red-900/20 border border-red-800 rounded px-3 py-2">
+            {error}
+          </p>
+        )}
+
+        {editing ? (
+          <WebhookForm
+            webhook={editing}
+            onSave={handleSave}
+            onCancel={() => {
+              setEditing(null);
+              setError(null);
+            }}
+          />
+        ) : viewing ? (
+          <DeliveryLog webhook={viewing} onBack={() => setViewing(null)} />
+        ) : (
+          <>
+            <button
+              onClick={() => setEditing({ ...emptyWebhook })}
+              className="mb-4 px-4 py-2 rounded text-sm font-medium bg-blue-600 text-white hover:bg-blue-700 transition-colors"
+            >
+              Add Webhook
+            </button>
+
+            {webhooks.length === 0 ? (
+              <p className="text-sm text-gray-500 italic">
+                No webhooks configured.
+              </p>
+            ) : (
+              <div className="space-y-2">
+                {webhooks.map((w) => (
+                  <div
+                    key={w.id}
+                    className="border border-gray-700 rounded px-4 py-3 flex items-center justify-between"
+                  >
+                    <div className="min-w-0">
+                      <p className="text-sm font-medium text-gray-100">
+                        {w.name}
+                      </p>
+                      <p className="text-xs text-gray-500 truncate">{w.url}</p>
+                    </div>
+                    <div className="flex gap-2 shrink-0">
+                      <span className="text-xs px-2 py-0.5 rounded bg-gray-800 text-gray-400 border border-gray-700">
+                        {formatLabels[w.format]}
+                      </span>
+                      {w.allJobs && (
+                        <span className="text-xs px-2 py-0.5 rounded bg-gray-800 text-gray-400 border border-gray-700">
+                          all jobs
+                        </span>
+                      )}
+                      <button
+                        onClick={() => setViewing(w)}
+                        className="text-xs text-blue-400 hover:text-blue-300"
+                      >
+                        Deliveries
+                      </button>
+                      <button
+                        onClick={() => setEditing({ ...w })}
+                        className="text-xs text-blue-400 hover:text-blue-300"
+                      >
+                        Edit
+                      </button>
+                      <button
+                        onClick={() => handleDelete(w.id)}
+                        className="text-xs text-red-400 hover:text-red-300"
+                      >
+                        Delete
+                      </button>
+                    </div>
+                  </div>
+                ))}
+              </div>
+            )}
+          </>
+        )}
+      </div>
+    </div>
+  );
+}
+
+function parseEvents(value: string): string[] {
+  try {
+    const list = JSON.parse(value);
+    return Array.isArray(list) ? list.map(String) : [];
+  } catch {
+    return [];
+  }
+}
+
+function WebhookForm({
+  webhook,
+  onSave,
+  onCancel,
+}: {
+  webhook: Webhook;
+  onSave: (webhook: Webhook) => void;
+  onCancel: () => void;
+}) {
+  const [name, setName] = useState(webhook.name);
+  const [url, setUrl] = useState(webhook.url);
+  const [format, setFormat] = useState<WebhookFormat>(webhook.format);
+  const [events, setEvents] = useState<string[]>(parseEvents(webhook.events));
+  const [headers, setHeaders] = useState(webhook.headers);
+  const [allJobs, setAllJobs] = useState(webhook.allJobs);
+  const [formError, setFormError] = useState<string | null>(null);
+
+  const toggleEvent = (event: string) => {
+    setEvents((prev) =>
+      prev.includes(event) ? prev.filter((e) => e !== event) : [...prev, event]
+    );
+  };
+
+  const handleSubmit = () => {
+    if (!name.trim()) {
+      setFormError("Name is required");
+      return;
+    }
+    if (!/^https?:\/\/\S+$/.test(url.trim())) {
+      setFormError("URL must start with http:// or https://");
+      return;
+    }
+    if (events.length === 0) {
+      setFormError("Pick at least one event to send");
+      return;
+    }
+    setFormError(null);
+    onSave({
+      id: webhook.id,
+      name: name.trim(),
+      url: url.trim(),
+      format,
+      events: JSON.stringify(Object.keys(eventLabels).filter((e) => events.includes(e))),
+      headers: format === "json" ? headers : "{}",
+      allJobs,
+    });
+  };
+
+  const inputClass =
+    "w-full bg-gray-800 border border-gray-600 rounded px-3 py-2 text-sm text-gray-100 focus:border-blue-500 focus:outline-none";
+  const labelClass =
+    "block text-xs font-semibold text-gray-400 uppercase tracking-wider mb-1.5";
+
+  return (
+    <div className="space-y-4">
+      <h2 className="text-sm font-semibold text-gray-200">
+        {webhook.id ? "Edit Webhook" : "New Webhook"}
+      </h2>
+
+      {formError && <p className="text-sm text-red-400">{formError}</p>}
+
+      <div>
+        <label className={labelClass}>Name</label>
+        <input
+          type="text"
+          value={name}
+          onChange={(e) => setName(e.target.value)}
+          placeholder="team-alerts"
+          className={inputClass}
+        />
+      </div>
+
+      <div>
+        <label className={labelClass}>Format</label>
+        <select
+          value={format}
+          onChange={(e) => setFormat(e.target.value as WebhookFormat)}
+          className={inputClass}
+          style={{ colorScheme: "dark" }}
+        >
+          <option value="json">Generic JSON</option>
+          <option value="slack">Slack</option>
+          <option value="discord">Discord</option>
+        </select>
+      </div>
+
+      <div>
+        <label className={labelClass}>URL</label>
+        <input
+          type="text"
+          value={url}
+          onChange={(e) => setUrl(e.target.value)}
+          placeholder={
+            format === "slack"
+              ? "https://hooks.slack.com/services/..."
+              : format === "discord"
+                ? "https://discord.com/api/webhooks/..."
+                : "https://example.com/hooks/claude"
+          }
+          className={inputClass}
+        />
+      </div>
+
+      <div>
+        <label className={labelClass}>Events</label>
+        <div className="flex flex-wrap gap-x-4 gap-y-1.5">
+          {Object.entries(eventLabels).map(([event, label]) => (
+            <label key={event} className="flex items-center gap-2 text-sm text-gray-200 cursor-pointer">
+              <input
+                type="checkbox"
+                checked={events.includes(event)}
+                onChange={() => toggleEvent(event)}
+                className="rounded border-gray-600 bg-gray-800 text-blue-500 focus:ring-blue-500 focus:ring-offset-0"
+              />
+              {label}
+            </label>
+          ))}
+        </div>
+        <p className="mt-1.5 text-xs text-gray-600">
+          Jobs that only notify on change send nothing when their result did not change.
+        </p>
+      </div>
+
+      {format === "json" && (
+        <div>
+          <label className={labelClass}>Headers</label>
+          <KeyValueEditor value={headers} onChange={setHeaders} />
+        </div>
+      )}
+
+      <div>
+        <label className="flex items-center gap-2 text-sm text-gray-200 cursor-pointer">
+          <input
+            type="checkbox"
+            checked={allJobs}
+            onChange={(e) => setAllJobs(e.target.checked)}
+            className="rounded border-gray-600 bg-gray-800 text-blue-500 focus:ring-blue-500 focus:ring-offset-0"
+          />
+          Send events of every job
+        </label>
+        <p className="mt-1.5 text-xs text-gray-600">
+          Otherwise, pick the jobs that use this webhook in their settings.
+        </p>
+      </div>
+
+      <div className="flex gap-3 pt-2">
+        <button
+          onClick={onCancel}
+          className="px-4 py-2 rounded text-sm font-medium bg-gray-700 text-gray-300 hover:bg-gray-600 transition-colors"
+        >
+          Cancel
+        </button>
+        <button
+          onClick={handleSubmit}
+          className="px-4 py-2 rounded text-sm font-medium bg-blue-600 text-white hover:bg-blue-700 transition-colors"
+        >
+          Save
+        </button>
+      </div>
+    </div>
+  );
+}
+
+function DeliveryLog({ webhook, onBack }: { webhook: Webhook; onBack: () => void }) {
+  const [deliveries, setDeliveries] = useState<WebhookDelivery[]>([]);
+  const [testing, setTesting] = useState(false);
+
+  const refresh = () => {
+    GetWebhookDeliveries(webhook.id).then((data) => setDeliveries(data ?? []));
+  };
+
+  useEffect(() => {
+    refresh();
+  }, [webhook.id]);
+
+  const handleTest = async () => {
+    setTesting(true);
+    try {
+      await TestWebhook(webhook.id);
+    } finally {
+      setTesting(false);
+      refresh();
+    }
+  };
+
+  return (
+    <div className="space-y-4">
+      <div className="flex items-center justify-between">
+        <h2 className="text-sm font-semibold text-gray-200">
+          Deliveries to {webhook.name}
+        </h2>
+        <div className="flex gap-3">
+          <button
+            onClick={refresh}
+            className="text-xs text-gray-400 hover:text-gray-200"
+          >
+            Refresh
+          </button>
+          <button
+            onClick={handleTest}
+            disabled={testing}
+            className="px-3 py-1.5 rounded text-xs font-medium bg-blue-600 text-white hover:bg-blue-700 disabled:opacity-50 transition-colors"
+          >
+            {testing ? "Sending..." : "Send Test"}
+          </button>
+          <button
+            onClick={onBack}
+            className="px-3 py-1.5 rounded text-xs font-medium bg-gray-700 text-gray-300 hover:bg-gray-600 transition-colors"
+          >
+            Done
+          </button>
+        </div>
+      </div>
+
+      {deliveries.length === 0 ? (
+        <p className="text-sm text-gray-500 italic">Nothing has been sent yet.</p>
+      ) : (
+        <div className="space-y-2">
+          {deliveries.map((d) => (
+            <div key={d.id} className="border border-gray-700 rounded px-4 py-2.5">
+              <div className="flex items-center justify-between text-xs">
+                <span className="text-gray-200">
+                  {d.jobName} · {eventLabels[d.event] ?? d.event}
+                </span>
+                <span className={stateColors[d.state]}>
+                  {d.state}
+                  {d.statusCode > 0 && ` (${d.statusCode})`}
+                  {d.attempts > 1 && ` after ${d.attempts} attempts`}
+                </span>
+              </div>
+              <p className="mt-0.5 text-xs text-gray-500">{formatTime(d.createdAt)}</p>
+              {d.error && (
+                <p className="mt-1 text-xs text-red-400 break-words">{d.error}</p>
+              )}
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+}
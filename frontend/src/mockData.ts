@@ -20,6 +20,37 @@ export const mockJobs: ScheduledJob[] = [
 [2026-01-31 02:00:22] Upload complete.
 [2026-01-31 02:00:22] Backup completed successfully.`,
     pendingQuestion: "",
+    workingDir: "",
+    addDirs: "[]",
+    model: "",
+    fallbackModel: "",
+    maxTurns: 0,
+    systemPrompt: "",
+    systemPromptMode: "append",
+    skipDefaultSystemPrompt: false,
+    sessionPolicy: "resume",
+    sessionRotateRuns: 0,
+    sessionMaxTokens: 0,
+    sessionId: "",
+    sessionRuns: 0,
+    sessionTokens: 0,
+    timezone: "",
+    variables: "{}",
+    executor: "claude",
+    env: "{}",
+    worktree: false,
+    sandbox: false,
+    sandboxNetwork: "full",
+    sandboxHosts: "[]",
+    cpuLimit: 0,
+    memoryLimit: 0,
+    processLimit: 0,
+    outputSchema: "",
+    schemaRetry: false,
+    assertions: "[]",
+    reportOutcome: false,
+    notifyOnChange: "",
+    changeThreshold: 0,
   },
   {
     id: "2",
@@ -37,6 +68,37 @@ export const mockJobs: ScheduledJob[] = [
 [2026-01-26 00:00:02] ERROR: Permission denied: /var/log/app/access.log
 [2026-01-26 00:00:02] Log rotation failed with exit code 1.`,
     pendingQuestion: "",
+    workingDir: "",
+    addDirs: "[]",
+    model: "",
+    fallbackModel: "",
+    maxTurns: 0,
+    systemPrompt: "",
+    systemPromptMode: "append",
+    skipDefaultSystemPrompt: false,
+    sessionPolicy: "resume",
+    sessionRotateRuns: 0,
+    sessionMaxTokens: 0,
+    sessionId: "",
+    sessionRuns: 0,
+    sessionTokens: 0,
+    timezone: "",
+    variables: "{}",
+    executor: "claude",
+    env: "{}",
+    worktree: false,
+    sandbox: false,
+    sandboxNetwork: "full",
+    sandboxHosts: "[]",
+    cpuLimit: 0,
+    memoryLimit: 0,
+    processLimit: 0,
+    outputSchema: "",
+    schemaRetry: false,
+    assertions: "[]",
+    reportOutcome: false,
+    notifyOnChange: "",
+    changeThreshold: 0,
   },
   {
     id: "3",
@@ -54,6 +116,37 @@ export const mockJobs: ScheduledJob[] = [
 [2026-01-31 14:00:01] API: OK (response 200, 45ms)
 [2026-01-31 14:00:02] Checking database connectivity...`,
     pendingQuestion: "",
+    workingDir: "",
+    addDirs: "[]",
+    model: "",
+    fallbackModel: "",
+    maxTurns: 0,
+    systemPrompt: "",
+    systemPromptMode: "append",
+    skipDefaultSystemPrompt: false,
+    sessionPolicy: "resume",
+    sessionRotateRuns: 0,
+    sessionMaxTokens: 0,
+    sessionId: "",
+    sessionRuns: 0,
+    sessionTokens: 0,
+    timezone: "",
+    variables: "{}",
+    executor: "claude",
+    env: "{}",
+    worktree: false,
+    sandbox: false,
+    sandboxNetwork: "full",
+    sandboxHosts: "[]",
+    cpuLimit: 0,
+    memoryLimit: 0,
+    processLimit: 0,
+    outputSchema: "",
+    schemaRetry: false,
+    assertions: "[]",
+    reportOutcome: false,
+    notifyOnChange: "",
+    changeThreshold: 0,
   },
   {
     id: "4",
@@ -72,6 +165,37 @@ export const mockJobs: ScheduledJob[] = [
 [2026-01-27 08:00:13] Emailing report to team@example.com
 [2026-01-27 08:00:14] Done.`,
     pendingQuestion: "",
+    workingDir: "",
+    addDirs: "[]",
+    model: "",
+    fallbackModel: "",
+    maxTurns: 0,
+    systemPrompt: "",
+    systemPromptMode: "append",
+    skipDefaultSystemPrompt: false,
+    sessionPolicy: "resume",
+    sessionRotateRuns: 0,
+    sessionMaxTokens: 0,
+    sessionId: "",
+    sessionRuns: 0,
+    sessionTokens: 0,
+    timezone: "",
+    variables: "{}",
+    executor: "claude",
+    env: "{}",
+    worktree: false,
+    sandbox: false,
+    sandboxNetwork: "full",
+    sandboxHosts: "[]",
+    cpuLimit: 0,
+    memoryLimit: 0,
+    processLimit: 0,
+    outputSchema: "",
+    schemaRetry: false,
+    assertions: "[]",
+    reportOutcome: false,
+    notifyOnChange: "",
+    changeThreshold: 0,
   },
   {
     id: "5",
@@ -86,5 +210,36 @@ export const mockJobs: ScheduledJob[] = [
     status: "pending",
     output: "No output yet. Job has not run.",
     pendingQuestion: "",
+    workingDir: "",
+    addDirs: "[]",
+    model: "",
+    fallbackModel: "",
+    maxTurns: 0,
+    systemPrompt: "",
+    systemPromptMode: "append",
+    skipDefaultSystemPrompt: false,
+    sessionPolicy: "resume",
+    sessionRotateRuns: 0,
+    sessionMaxTokens: 0,
+    sessionId: "",
+    sessionRuns: 0,
+    sessionTokens: 0,
+    timezone: "",
+    variables: "{}",
+    executor: "claude",
+    env: "{}",
+    worktree: false,
+    sandbox: false,
+    sandboxNetwork: "full",
+    sandboxHosts: "[]",
+    cpuLimit: 0,
+    memoryLimit: 0,
+    processLimit: 0,
+    outputSchema: "",
+    schemaRetry: false,
+    assertions: "[]",
+    reportOutcome: false,
+    notifyOnChange: "",
+    changeThreshold: 0,
   },
 ];
@@ -1,8 +1,25 @@
-export type JobStatus = "success" | "failed" | "running" | "pending" | "waiting";
+export type JobStatus = "success" | "failed" | "running" | "pending" | "waiting" | "assertion_failed";
 
 export type IntervalUnit = "minutes" | "hours" | "days" | "weeks";
 
-export interface JobRun {
+export interface Usage {
+  durationMs: number;
+  numTurns: number;
+  inputTokens: number;
+  outputTokens: number;
+  cacheCreationTokens: number;
+  cacheReadTokens: number;
+  costUsd: number;
+}
+
+export interface JobUsage extends Usage {
+  jobId: string;
+  jobName: string;
+  deleted: boolean;
+  runs: number;
+}
+
+export interface JobRun extends Usage {
   id: string;
   jobId: string;
   startedAt: string;
@@ -10,6 +27,99 @@ export interface JobRun {
   status: JobStatus;
   output: string;
   pendingQuestion: string;
+  model: string;
+  sessionId: string;
+  followUpSessionId: string;
+  summary: string;
+  renderVersion: number;
+  worktree: string;
+  branch: string;
+  baseCommit: string;
+  diff: string;
+  commits: string;
+  worktreeState: WorktreeState;
+  failureReason: FailureReason;
+  structuredOutput: string;
+  assertionFailures: string;
+  outcome: RunOutcome;
+}
+
+export type ChangeMode = "" | "exact" | "whitespace" | "similarity";
+
+export type WorktreeState = "" | "active" | "kept" | "discarded" | "merged";
+
+export type RunOutcome = "" | "changed" | "unchanged" | "no_op";
+
+export type FailureReason = "" | "cpu_limit" | "memory_limit" | "process_limit" | "output_schema";
+
+export type AssertionKind = "matches" | "not_matches" | "tool_called" | "max_cost" | "judge";
+
+export interface Assertion {
+  kind: AssertionKind;
+  value: string;
+}
+
+export interface WorktreeCommit {
+  hash: string;
+  subject: string;
+  author: string;
+  date: string;
+}
+
+export interface RunArtifact {
+  runId: string;
+  name: string;
+  size: number;
+  mimeType: string;
+  sha256: string;
+  modifiedAt: string;
+}
+
+export type TranscriptEventKind =
+  | "text"
+  | "tool_use"
+  | "tool_result"
+  | "question"
+  | "summary"
+  | "follow_up"
+  | "follow_up_error";
+
+export interface TranscriptQuestion {
+  question: string;
+  header: string;
+  options: { label: string; description: string }[];
+}
+
+export interface TranscriptEvent {
+  kind: TranscriptEventKind;
+  time: string;
+  text?: string;
+  tool?: string;
+  toolId?: string;
+  input?: unknown;
+  questions?: TranscriptQuestion[];
+  isError?: boolean;
+  truncated?: number;
+}
+
+export type SystemPromptMode = "append" | "replace";
+
+export type SessionPolicy = "resume" | "fresh" | "rotate";
+
+export type SandboxNetwork = "full" | "restricted";
+
+export interface Settings {
+  defaultSystemPrompt: string;
+  anthropicApiKey: string;
+  claudePath: string;
+  claudeArgs: string;
+  claudeEnv: string;
+}
+
+export interface RunProgress {
+  runId: string;
+  jobId: string;
+  fragment: string;
 }
 
 export type MCPServerType = "http" | "stdio";
@@ -25,6 +135,34 @@ export interface MCPServer {
   headers: string;
 }
 
+export type WebhookFormat = "json" | "slack" | "discord";
+
+export interface Webhook {
+  id: string;
+  name: string;
+  url: string;
+  format: WebhookFormat;
+  events: string; // JSON array of the job statuses to send
+  headers: string;
+  allJobs: boolean;
+}
+
+export type DeliveryState = "pending" | "delivered" | "failed";
+
+export interface WebhookDelivery {
+  id: string;
+  webhookId: string;
+  jobName: string;
+  runId: string;
+  event: string;
+  state: DeliveryState;
+  attempts: number;
+  statusCode: number;
+  error: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
 export interface ScheduledJob {
   id: string;
   name: string;
@@ -38,4 +176,35 @@ export interface ScheduledJob {
   status: JobStatus;
   output: string;
   pendingQuestion: string;
+  workingDir: string;
+  addDirs: string;
+  model: string;
+  fallbackModel: string;
+  maxTurns: number;
+  systemPrompt: string;
+  systemPromptMode: SystemPromptMode;
+  skipDefaultSystemPrompt: boolean;
+  sessionPolicy: SessionPolicy;
+  sessionRotateRuns: number;
+  sessionMaxTokens: number;
+  sessionId: string;
+  sessionRuns: number;
+  sessionTokens: number;
+  timezone: string;
+  variables: string;
+  executor: string;
+  env: string;
+  worktree: boolean;
+  sandbox: boolean;
+  sandboxNetwork: SandboxNetwork;
+  sandboxHosts: string;
+  cpuLimit: number;
+  memoryLimit: number;
+  processLimit: number;
+  outputSchema: string;
+  schemaRetry: boolean;
+  assertions: string;
+  reportOutcome: boolean;
+  notifyOnChange: ChangeMode;
+  changeThreshold: number;
 }
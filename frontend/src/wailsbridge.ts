@@ -1,5 +1,5 @@
 import { Call, Events } from "@wailsio/runtime";
-import type { ScheduledJob, JobRun, MCPServer } from "./types";
+import type { ScheduledJob, JobRun, JobUsage, MCPServer, RunArtifact, Settings, TranscriptEvent, Webhook, WebhookDelivery } from "./types";
 
 // Call Go service methods by name. These will be replaced by auto-generated
 // bindings once `wails3 generate bindings` is run.
@@ -20,10 +20,54 @@ export function DeleteJob(id: string): Promise<void> {
   return Call.ByName("main.App.DeleteJob", id);
 }
 
+export function ResetJobSession(jobId: string): Promise<void> {
+  return Call.ByName("main.App.ResetJobSession", jobId);
+}
+
 export function GetRunsForJob(jobId: string): Promise<JobRun[]> {
   return Call.ByName("main.App.GetRunsForJob", jobId);
 }
 
+export function GetRunEvents(runId: string): Promise<TranscriptEvent[]> {
+  return Call.ByName("main.App.GetRunEvents", runId);
+}
+
+export function GetRunDiff(runId: string): Promise<string> {
+  return Call.ByName("main.App.GetRunDiff", runId);
+}
+
+export function GetRunRawEvents(runId: string): Promise<string[]> {
+  return Call.ByName("main.App.GetRunRawEvents", runId);
+}
+
+export function RerenderRun(runId: string): Promise<JobRun> {
+  return Call.ByName("main.App.RerenderRun", runId);
+}
+
+export function GetRunArtifacts(runId: string): Promise<RunArtifact[]> {
+  return Call.ByName("main.App.GetRunArtifacts", runId);
+}
+
+export function OpenRunArtifact(runId: string, name: string): Promise<void> {
+  return Call.ByName("main.App.OpenRunArtifact", runId, name);
+}
+
+export function KeepRunWorktree(runId: string): Promise<void> {
+  return Call.ByName("main.App.KeepRunWorktree", runId);
+}
+
+export function DiscardRunWorktree(runId: string): Promise<void> {
+  return Call.ByName("main.App.DiscardRunWorktree", runId);
+}
+
+export function MergeRunWorktree(runId: string): Promise<void> {
+  return Call.ByName("main.App.MergeRunWorktree", runId);
+}
+
+export function GetUsageStats(from: string, to: string): Promise<JobUsage[]> {
+  return Call.ByName("main.App.GetUsageStats", from, to);
+}
+
 // MCP Server methods.
 
 export function GetMCPServers(): Promise<MCPServer[]> {
@@ -50,14 +94,78 @@ export function SetJobMCPServers(jobId: string, serverIds: string[]): Promise<vo
   return Call.ByName("main.App.SetJobMCPServers", jobId, serverIds);
 }
 
+// Webhook methods.
+
+export function GetWebhooks(): Promise<Webhook[]> {
+  return Call.ByName("main.App.GetWebhooks");
+}
+
+export function CreateWebhook(webhook: Webhook): Promise<Webhook> {
+  return Call.ByName("main.App.CreateWebhook", webhook);
+}
+
+export function UpdateWebhook(webhook: Webhook): Promise<Webhook> {
+  return Call.ByName("main.App.UpdateWebhook", webhook);
+}
+
+export function DeleteWebhook(id: string): Promise<void> {
+  return Call.ByName("main.App.DeleteWebhook", id);
+}
+
+export function TestWebhook(id: string): Promise<WebhookDelivery> {
+  return Call.ByName("main.App.TestWebhook", id);
+}
+
+export function GetWebhookDeliveries(webhookId: string): Promise<WebhookDelivery[]> {
+  return Call.ByName("main.App.GetWebhookDeliveries", webhookId);
+}
+
+export function GetJobWebhookIDs(jobId: string): Promise<string[]> {
+  return Call.ByName("main.App.GetJobWebhookIDs", jobId);
+}
+
+export function SetJobWebhooks(jobId: string, webhookIds: string[]): Promise<void> {
+  return Call.ByName("main.App.SetJobWebhooks", jobId, webhookIds);
+}
+
+// Settings methods.
+
+export function GetExecutors(): Promise<string[]> {
+  return Call.ByName("main.App.GetExecutors");
+}
+
+export function DetectClaudePath(): Promise<string> {
+  return Call.ByName("main.App.DetectClaudePath");
+}
+
+export function GetSettings(): Promise<Settings> {
+  return Call.ByName("main.App.GetSettings");
+}
+
+export function UpdateSettings(settings: Settings): Promise<Settings> {
+  return Call.ByName("main.App.UpdateSettings", settings);
+}
+
 export function RunJobNow(jobId: string): Promise<void> {
   return Call.ByName("main.App.RunJobNow", jobId);
 }
 
+export function RunJobWithPayload(jobId: string, payload: string): Promise<void> {
+  return Call.ByName("main.App.RunJobWithPayload", jobId, payload);
+}
+
+export function PreviewPrompt(job: ScheduledJob): Promise<string> {
+  return Call.ByName("main.App.PreviewPrompt", job);
+}
+
 export function AnswerQuestion(jobId: string, answer: string): Promise<void> {
   return Call.ByName("main.App.AnswerQuestion", jobId, answer);
 }
 
+export function SendFollowUp(runId: string, message: string): Promise<void> {
+  return Call.ByName("main.App.SendFollowUp", runId, message);
+}
+
 // Event helpers wrapping the v3 Events API.
 export function OnEvent(name: string, callback: (data: unknown) => void): () => void {
   return Events.On(name, callback);
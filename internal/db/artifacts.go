@@ -0,0 +1,112 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RunArtifact is a file a run produced in its artifacts directory.
+type RunArtifact struct {
+	RunID      string `json:"runId"`
+	Name       string `json:"name"`       // path relative to the run's artifacts directory, with forward slashes
+	Size       int64  `json:"size"`       // in bytes
+	MimeType   string `json:"mimeType"`   // guessed from the extension or content
+	SHA256     string `json:"sha256"`     // hex-encoded hash of the content
+	ModifiedAt string `json:"modifiedAt"` // RFC3339
+}
+
+// SetArtifactsRoot sets the directory that holds each run's artifacts
+// directory. Without one, runs get no artifacts directory.
+func (s *Store) SetArtifactsRoot(dir string) {
+	s.artifactsRoot = dir
+}
+
+// ArtifactsDir returns the directory for the files of run id, or "" when no
+// artifacts root is set.
+func (s *Store) ArtifactsDir(id string) string {
+	if s.artifactsRoot == "" || id == "" {
+		return ""
+	}
+	return filepath.Join(s.artifactsRoot, id)
+}
+
+// SetRunArtifacts replaces the artifacts recorded for a run.
+func (s *Store) SetRunArtifacts(runID string, artifacts []RunArtifact) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM run_artifacts WHERE run_id = ?", runID); err != nil {
+		return err
+	}
+	for _, a := range artifacts {
+		if _, err := tx.Exec(
+			`INSERT INTO run_artifacts (run_id, name, size, mime_type, sha256, modified_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			runID, a.Name, a.Size, a.MimeType, a.SHA256, a.ModifiedAt,
+		); err != nil {
+			return fmt.Errorf("recording artifact %s: %w", a.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetRunArtifacts returns the artifacts recorded for a run, ordered by name.
+func (s *Store) GetRunArtifacts(runID string) ([]RunArtifact, error) {
+	rows, err := s.db.Query(
+		`SELECT run_id, name, size, mime_type, sha256, modified_at
+		 FROM run_artifacts WHERE run_id = ? ORDER BY name`,
+		runID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artifacts := []RunArtifact{}
+	for rows.Next() {
+		var a RunArtifact
+		if err := rows.Scan(&a.RunID, &a.Name, &a.Size, &a.MimeType, &a.SHA256, &a.ModifiedAt); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
+
+// deleteArtifacts removes the artifact records and directories of runs that
+// are being deleted.
+func (s *Store) deleteArtifacts(runIDs []string) error {
+	for _, id := range runIDs {
+		if _, err := s.db.Exec("DELETE FROM run_artifacts WHERE run_id = ?", id); err != nil {
+			return err
+		}
+		if dir := s.ArtifactsDir(id); dir != "" {
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("removing artifacts of run %s: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runIDs returns the IDs of the runs a query selects.
+func (s *Store) runIDs(query string, args ...any) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunArtifacts(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Artifacts"))
+	require.NoError(t, err)
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+
+	require.Empty(t, store.ArtifactsDir(run.ID))
+	root := t.TempDir()
+	store.SetArtifactsRoot(root)
+	require.Equal(t, filepath.Join(root, run.ID), store.ArtifactsDir(run.ID))
+
+	report := db.RunArtifact{RunID: run.ID, Name: "report.md", Size: 9, MimeType: "text/markdown; charset=utf-8", SHA256: "ab", ModifiedAt: "2026-02-01T00:01:00Z"}
+	chart := db.RunArtifact{RunID: run.ID, Name: "charts/a.png", Size: 100, MimeType: "image/png", SHA256: "cd", ModifiedAt: "2026-02-01T00:02:00Z"}
+	require.NoError(t, store.SetRunArtifacts(run.ID, []db.RunArtifact{report, chart}))
+	got, err := store.GetRunArtifacts(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, []db.RunArtifact{chart, report}, got)
+
+	// Indexing again replaces the list.
+	require.NoError(t, store.SetRunArtifacts(run.ID, []db.RunArtifact{report}))
+	got, err = store.GetRunArtifacts(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, []db.RunArtifact{report}, got)
+}
+
+func TestPruneRunsRemovesArtifacts(t *testing.T) {
+	store := openTestStore(t)
+	store.SetArtifactsRoot(t.TempDir())
+	job, err := store.CreateJob(validJob("Prune"))
+	require.NoError(t, err)
+
+	var runs []db.JobRun
+	for i := 0; i < 11; i++ {
+		run := createTestRun(t, store, job.ID, fmt.Sprintf("2026-02-01T00:%02d:00Z", i))
+		require.NoError(t, os.MkdirAll(store.ArtifactsDir(run.ID), 0o755))
+		require.NoError(t, store.SetRunArtifacts(run.ID, []db.RunArtifact{{Name: "out.txt"}}))
+		runs = append(runs, run)
+	}
+
+	require.NoError(t, store.PruneRuns(job.ID))
+	require.NoDirExists(t, store.ArtifactsDir(runs[0].ID))
+	got, err := store.GetRunArtifacts(runs[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, got)
+	require.DirExists(t, store.ArtifactsDir(runs[1].ID))
+
+	// Deleting the job removes the rest.
+	require.NoError(t, store.DeleteJob(job.ID))
+	for _, run := range runs[1:] {
+		require.NoDirExists(t, store.ArtifactsDir(run.ID))
+	}
+}
@@ -1,7 +1,16 @@
 package db
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"claude-schedule/internal/prompt"
+	"claude-schedule/internal/structured"
 
 	"github.com/google/uuid"
 )
@@ -14,6 +23,53 @@ var validIntervalUnits = map[string]bool{
 	"weeks":   true,
 }
 
+// Valid system prompt modes. "append" adds the job's prompt to Claude's
+// default system prompt; "replace" substitutes it entirely.
+var validSystemPromptModes = map[string]bool{
+	"append":  true,
+	"replace": true,
+}
+
+// Valid session policies. "resume" continues the job's conversation across
+// runs, "fresh" starts a new conversation every run, and "rotate" resumes
+// until a run count or context size threshold is reached.
+var validSessionPolicies = map[string]bool{
+	"resume": true,
+	"fresh":  true,
+	"rotate": true,
+}
+
+// Valid sandbox network modes. "full" shares the host's network; "restricted"
+// only reaches the Anthropic API and the job's SandboxHosts.
+var validSandboxNetworks = map[string]bool{
+	"full":       true,
+	"restricted": true,
+}
+
+// validChangeModes are the ways NotifyOnChange compares results: "exact",
+// after collapsing whitespace, or by word similarity against ChangeThreshold.
+var validChangeModes = map[string]bool{
+	"exact":      true,
+	"whitespace": true,
+	"similarity": true,
+}
+
+// Kinds of success criteria a job's runs are checked against.
+const (
+	AssertMatches    = "matches"     // the final result matches the regular expression Value
+	AssertNotMatches = "not_matches" // the final result does not match the regular expression Value
+	AssertToolCalled = "tool_called" // the run called the tool Value; * matches any run of characters
+	AssertMaxCost    = "max_cost"    // the run cost at most Value US dollars
+	AssertJudge      = "judge"       // a second model finds the final result meets the criterion Value
+)
+
+// Assertion is a success criterion. A run that fails one ends with status
+// "assertion_failed" rather than "success".
+type Assertion struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
 // Job represents a scheduled job persisted in the database.
 type Job struct {
 	ID              string `json:"id"`
@@ -28,6 +84,68 @@ type Job struct {
 	Status          string `json:"status"`
 	Output          string `json:"output"`
 	PendingQuestion string `json:"pendingQuestion"`
+	WorkingDir      string `json:"workingDir"`    // directory the CLI runs in
+	AddDirs         string `json:"addDirs"`       // JSON array string of extra accessible directories
+	Model           string `json:"model"`         // empty uses the CLI default
+	FallbackModel   string `json:"fallbackModel"` // used when the primary model is overloaded
+	MaxTurns        int    `json:"maxTurns"`      // 0 means no limit
+
+	SystemPrompt            string `json:"systemPrompt"`
+	SystemPromptMode        string `json:"systemPromptMode"`        // "append" or "replace"
+	SkipDefaultSystemPrompt bool   `json:"skipDefaultSystemPrompt"` // opt out of the global default
+
+	SessionPolicy     string `json:"sessionPolicy"`     // "resume", "fresh" or "rotate"
+	SessionRotateRuns int    `json:"sessionRotateRuns"` // rotate after this many runs (0 = never)
+	SessionMaxTokens  int64  `json:"sessionMaxTokens"`  // rotate once the context reaches this size (0 = never)
+	SessionID         string `json:"sessionId"`         // conversation resumed by the next run
+	SessionRuns       int    `json:"sessionRuns"`       // runs completed in the current session
+	SessionTokens     int64  `json:"sessionTokens"`     // context size at the end of the last run
+
+	Timezone  string `json:"timezone"`  // IANA zone for prompt templates; empty is the system zone
+	Variables string `json:"variables"` // JSON object string of custom prompt template variables
+
+	Executor string `json:"executor"` // backend that runs the job: "claude", "api" or "shell"
+	Env      string `json:"env"`      // JSON object string of environment overrides for the child process
+
+	Worktree bool `json:"worktree"` // run in a fresh git worktree of WorkingDir on a branch of its own
+
+	Sandbox        bool   `json:"sandbox"`        // run the CLI in a bubblewrap sandbox (Linux only)
+	SandboxNetwork string `json:"sandboxNetwork"` // "full" or "restricted"
+	SandboxHosts   string `json:"sandboxHosts"`   // JSON array string of hosts a restricted sandbox may reach
+
+	// Resource limits on the CLI and everything it starts (Linux only). 0 means no limit.
+	CPULimit     int `json:"cpuLimit"`     // CPU time in seconds
+	MemoryLimit  int `json:"memoryLimit"`  // memory in megabytes
+	ProcessLimit int `json:"processLimit"` // number of processes
+
+	OutputSchema string `json:"outputSchema"` // JSON Schema the final result must match (claude executor only); empty for free-form results
+	SchemaRetry  bool   `json:"schemaRetry"`  // ask once more, with the problems, when the result does not match
+
+	Assertions string `json:"assertions"` // JSON array string of Assertions a successful run must pass
+
+	ReportOutcome bool `json:"reportOutcome"` // ask for an outcome marker and notify only when something changed
+
+	// Notify only when the final result differs from the last successful run's.
+	NotifyOnChange  string  `json:"notifyOnChange"`  // how results are compared, see validChangeModes; empty to always notify
+	ChangeThreshold float64 `json:"changeThreshold"` // similarity mode: results less similar than this (0-1) have changed
+}
+
+// jobColumns lists the jobs table columns in the order scanJob expects.
+const jobColumns = "id, name, start_date, interval_value, interval_unit, prompt, active, next_run, last_run, status, output, pending_question, working_dir, add_dirs, model, fallback_model, max_turns, system_prompt, system_prompt_mode, skip_default_system_prompt, " +
+	"session_policy, session_rotate_runs, session_max_tokens, session_id, session_runs, session_tokens, timezone, variables, executor, env, worktree, " +
+	"sandbox, sandbox_network, sandbox_hosts, cpu_limit, memory_limit, process_limit, output_schema, schema_retry, assertions, report_outcome, notify_on_change, change_threshold"
+
+// scanJob reads a row selected with jobColumns into a Job.
+func scanJob(row interface{ Scan(...any) error }) (Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Name, &j.StartDate, &j.IntervalValue, &j.IntervalUnit,
+		&j.Prompt, &j.Active, &j.NextRun, &j.LastRun, &j.Status, &j.Output, &j.PendingQuestion,
+		&j.WorkingDir, &j.AddDirs, &j.Model, &j.FallbackModel, &j.MaxTurns,
+		&j.SystemPrompt, &j.SystemPromptMode, &j.SkipDefaultSystemPrompt,
+		&j.SessionPolicy, &j.SessionRotateRuns, &j.SessionMaxTokens, &j.SessionID, &j.SessionRuns, &j.SessionTokens,
+		&j.Timezone, &j.Variables, &j.Executor, &j.Env, &j.Worktree,
+		&j.Sandbox, &j.SandboxNetwork, &j.SandboxHosts, &j.CPULimit, &j.MemoryLimit, &j.ProcessLimit, &j.OutputSchema, &j.SchemaRetry, &j.Assertions, &j.ReportOutcome, &j.NotifyOnChange, &j.ChangeThreshold)
+	return j, err
 }
 
 func validateJob(j Job) error {
@@ -37,13 +155,240 @@ func validateJob(j Job) error {
 	if !validIntervalUnits[j.IntervalUnit] {
 		return fmt.Errorf("invalid interval unit: %s", j.IntervalUnit)
 	}
+	if _, err := j.AddDirList(); err != nil {
+		return err
+	}
+	if j.MaxTurns < 0 {
+		return fmt.Errorf("max turns must not be negative")
+	}
+	if j.FallbackModel != "" && j.FallbackModel == j.Model {
+		return fmt.Errorf("fallback model must differ from the main model")
+	}
+	if j.SystemPromptMode != "" && !validSystemPromptModes[j.SystemPromptMode] {
+		return fmt.Errorf("invalid system prompt mode: %s", j.SystemPromptMode)
+	}
+	if j.SystemPromptMode == "replace" && strings.TrimSpace(j.SystemPrompt) == "" {
+		return fmt.Errorf("system prompt is required when replacing the default")
+	}
+	if j.SessionPolicy != "" && !validSessionPolicies[j.SessionPolicy] {
+		return fmt.Errorf("invalid session policy: %s", j.SessionPolicy)
+	}
+	if j.SessionRotateRuns < 0 || j.SessionMaxTokens < 0 {
+		return fmt.Errorf("session rotation thresholds must not be negative")
+	}
+	if j.SessionPolicy == "rotate" && j.SessionRotateRuns == 0 && j.SessionMaxTokens == 0 {
+		return fmt.Errorf("rotate session policy requires a run count or context size")
+	}
+	if _, err := prompt.LoadLocation(j.Timezone); err != nil {
+		return err
+	}
+	if _, err := j.VariableMap(); err != nil {
+		return err
+	}
+	if _, err := j.EnvMap(); err != nil {
+		return err
+	}
+	if j.Worktree && j.WorkingDir == "" {
+		return fmt.Errorf("a working directory is required to run in a worktree")
+	}
+	if j.SandboxNetwork != "" && !validSandboxNetworks[j.SandboxNetwork] {
+		return fmt.Errorf("invalid sandbox network: %s", j.SandboxNetwork)
+	}
+	if _, err := j.SandboxHostList(); err != nil {
+		return err
+	}
+	if j.Sandbox && j.WorkingDir == "" {
+		return fmt.Errorf("a working directory is required to run in a sandbox")
+	}
+	if j.CPULimit < 0 || j.MemoryLimit < 0 || j.ProcessLimit < 0 {
+		return fmt.Errorf("resource limits must not be negative")
+	}
+	if j.CPULimit > 0 || j.MemoryLimit > 0 || j.ProcessLimit > 0 {
+		// Only the claude backend enforces them, and only on Linux.
+		if j.Executor != "" && j.Executor != "claude" {
+			return fmt.Errorf("resource limits are only supported by the claude executor")
+		}
+		if runtime.GOOS != "linux" {
+			return fmt.Errorf("resource limits need Linux")
+		}
+	}
+	if strings.TrimSpace(j.OutputSchema) != "" {
+		if _, err := structured.Compile(j.OutputSchema); err != nil {
+			return err
+		}
+		// Only the claude backend asks for and checks a structured result.
+		if j.Executor != "" && j.Executor != "claude" {
+			return fmt.Errorf("an output schema is only supported by the claude executor")
+		}
+		// A structured result must end with its JSON, leaving no place for
+		// the outcome marker.
+		if j.ReportOutcome {
+			return fmt.Errorf("a job with an output schema cannot report an outcome")
+		}
+	}
+	if _, err := j.AssertionList(); err != nil {
+		return err
+	}
+	if j.NotifyOnChange != "" && !validChangeModes[j.NotifyOnChange] {
+		return fmt.Errorf("invalid change comparison: %s", j.NotifyOnChange)
+	}
+	if j.NotifyOnChange == "similarity" && (j.ChangeThreshold <= 0 || j.ChangeThreshold >= 1) {
+		return fmt.Errorf("change threshold must be between 0 and 1")
+	}
+	return nil
+}
+
+// applyJobDefaults fills in stored defaults for optional fields left empty.
+func applyJobDefaults(j *Job) {
+	if j.AddDirs == "" {
+		j.AddDirs = "[]"
+	}
+	if j.SystemPromptMode == "" {
+		j.SystemPromptMode = "append"
+	}
+	if j.SessionPolicy == "" {
+		j.SessionPolicy = "resume"
+	}
+	if j.Variables == "" {
+		j.Variables = "{}"
+	}
+	if j.Executor == "" {
+		j.Executor = "claude"
+	}
+	if j.Env == "" {
+		j.Env = "{}"
+	}
+	if j.SandboxNetwork == "" {
+		j.SandboxNetwork = "full"
+	}
+	if j.SandboxHosts == "" {
+		j.SandboxHosts = "[]"
+	}
+	if j.Assertions == "" {
+		j.Assertions = "[]"
+	}
+}
+
+// AddDirList parses AddDirs into a slice. An empty string yields no directories.
+func (j Job) AddDirList() ([]string, error) {
+	return parseStringList(j.AddDirs, "additional directories")
+}
+
+// SandboxHostList parses SandboxHosts into a slice. An empty string yields no
+// hosts.
+func (j Job) SandboxHostList() ([]string, error) {
+	return parseStringList(j.SandboxHosts, "sandbox hosts")
+}
+
+// VariableMap parses Variables into a map. An empty string yields no variables.
+func (j Job) VariableMap() (map[string]string, error) {
+	return parseStringMap(j.Variables, "prompt variables")
+}
+
+// EnvMap parses Env into a map. An empty string yields no overrides.
+func (j Job) EnvMap() (map[string]string, error) {
+	return parseStringMap(j.Env, "environment")
+}
+
+// AssertionList parses and checks Assertions. An empty string yields no
+// assertions.
+func (j Job) AssertionList() ([]Assertion, error) {
+	if j.Assertions == "" {
+		return nil, nil
+	}
+	var list []Assertion
+	if err := json.Unmarshal([]byte(j.Assertions), &list); err != nil {
+		return nil, fmt.Errorf("invalid assertions: %w", err)
+	}
+	for _, a := range list {
+		if strings.TrimSpace(a.Value) == "" {
+			return nil, fmt.Errorf("assertion %s needs a value", a.Kind)
+		}
+		switch a.Kind {
+		case AssertMatches, AssertNotMatches:
+			if _, err := regexp.Compile(a.Value); err != nil {
+				return nil, fmt.Errorf("invalid assertion pattern: %w", err)
+			}
+		case AssertToolCalled, AssertJudge:
+		case AssertMaxCost:
+			if cost, err := strconv.ParseFloat(a.Value, 64); err != nil || cost <= 0 {
+				return nil, fmt.Errorf("invalid assertion cost: %s", a.Value)
+			}
+		default:
+			return nil, fmt.Errorf("invalid assertion kind: %s", a.Kind)
+		}
+	}
+	return list, nil
+}
+
+// parseStringList parses a JSON array of strings; what names the field in
+// errors. An empty string yields nil.
+func parseStringList(s, what string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(s), &list); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", what, err)
+	}
+	return list, nil
+}
+
+// parseStringMap parses a JSON object of strings; what names the field in
+// errors. An empty string yields an empty map.
+func parseStringMap(s, what string) (map[string]string, error) {
+	m := map[string]string{}
+	if s == "" {
+		return m, nil
+	}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", what, err)
+	}
+	if m == nil {
+		m = map[string]string{}
+	}
+	return m, nil
+}
+
+// ValidateJobPrompt checks that the job's prompt is a valid template that only
+// refers to known fields and the job's own variables. Like ValidateJobDirs it
+// runs when a job is saved from the UI, so a prompt written before templating
+// existed never blocks the scheduler from updating the job.
+func ValidateJobPrompt(j Job) error {
+	vars, err := j.VariableMap()
+	if err != nil {
+		return err
+	}
+	return prompt.Validate(j.Prompt, vars)
+}
+
+// ValidateJobDirs checks that the job's working directory and any additional
+// directories exist. It is called when a job is saved from the UI rather than
+// from validateJob so that scheduler status updates keep working if a
+// directory is removed later; the run itself then fails with a clear error.
+func ValidateJobDirs(j Job) error {
+	dirs, err := j.AddDirList()
+	if err != nil {
+		return err
+	}
+	if j.WorkingDir != "" {
+		dirs = append([]string{j.WorkingDir}, dirs...)
+	}
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("directory %s: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("not a directory: %s", dir)
+		}
+	}
 	return nil
 }
 
 // GetJobs returns all jobs sorted by name.
 func (s *Store) GetJobs() ([]Job, error) {
-	rows, err := s.db.Query(
-		"SELECT id, name, start_date, interval_value, interval_unit, prompt, active, next_run, last_run, status, output, pending_question FROM jobs ORDER BY name")
+	rows, err := s.db.Query("SELECT " + jobColumns + " FROM jobs ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -51,9 +396,8 @@ func (s *Store) GetJobs() ([]Job, error) {
 
 	jobs := []Job{}
 	for rows.Next() {
-		var j Job
-		if err := rows.Scan(&j.ID, &j.Name, &j.StartDate, &j.IntervalValue, &j.IntervalUnit,
-			&j.Prompt, &j.Active, &j.NextRun, &j.LastRun, &j.Status, &j.Output, &j.PendingQuestion); err != nil {
+		j, err := scanJob(rows)
+		if err != nil {
 			return nil, err
 		}
 		jobs = append(jobs, j)
@@ -63,13 +407,7 @@ func (s *Store) GetJobs() ([]Job, error) {
 
 // GetJob returns a single job by ID.
 func (s *Store) GetJob(id string) (Job, error) {
-	var j Job
-	err := s.db.QueryRow(
-		"SELECT id, name, start_date, interval_value, interval_unit, prompt, active, next_run, last_run, status, output, pending_question FROM jobs WHERE id = ?",
-		id,
-	).Scan(&j.ID, &j.Name, &j.StartDate, &j.IntervalValue, &j.IntervalUnit,
-		&j.Prompt, &j.Active, &j.NextRun, &j.LastRun, &j.Status, &j.Output, &j.PendingQuestion)
-	return j, err
+	return scanJob(s.db.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
 }
 
 // CreateJob inserts a new job. It assigns a UUID if ID is empty and defaults status to "pending".
@@ -83,11 +421,17 @@ func (s *Store) CreateJob(j Job) (Job, error) {
 	if j.Status == "" {
 		j.Status = "pending"
 	}
+	applyJobDefaults(&j)
 	_, err := s.db.Exec(
-		`INSERT INTO jobs (id, name, start_date, interval_value, interval_unit, prompt, active, next_run, last_run, status, output, pending_question)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO jobs (`+jobColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		j.ID, j.Name, j.StartDate, j.IntervalValue, j.IntervalUnit,
 		j.Prompt, j.Active, j.NextRun, j.LastRun, j.Status, j.Output, j.PendingQuestion,
+		j.WorkingDir, j.AddDirs, j.Model, j.FallbackModel, j.MaxTurns,
+		j.SystemPrompt, j.SystemPromptMode, j.SkipDefaultSystemPrompt,
+		j.SessionPolicy, j.SessionRotateRuns, j.SessionMaxTokens, j.SessionID, j.SessionRuns, j.SessionTokens,
+		j.Timezone, j.Variables, j.Executor, j.Env, j.Worktree,
+		j.Sandbox, j.SandboxNetwork, j.SandboxHosts, j.CPULimit, j.MemoryLimit, j.ProcessLimit, j.OutputSchema, j.SchemaRetry, j.Assertions, j.ReportOutcome, j.NotifyOnChange, j.ChangeThreshold,
 	)
 	return j, err
 }
@@ -97,11 +441,22 @@ func (s *Store) UpdateJob(j Job) (Job, error) {
 	if err := validateJob(j); err != nil {
 		return j, err
 	}
+	applyJobDefaults(&j)
 	result, err := s.db.Exec(
-		`UPDATE jobs SET name=?, start_date=?, interval_value=?, interval_unit=?, prompt=?, active=?, next_run=?, last_run=?, status=?, output=?, pending_question=?
+		`UPDATE jobs SET name=?, start_date=?, interval_value=?, interval_unit=?, prompt=?, active=?, next_run=?, last_run=?, status=?, output=?, pending_question=?,
+		 working_dir=?, add_dirs=?, model=?, fallback_model=?, max_turns=?,
+		 system_prompt=?, system_prompt_mode=?, skip_default_system_prompt=?,
+		 session_policy=?, session_rotate_runs=?, session_max_tokens=?, session_id=?, session_runs=?, session_tokens=?,
+		 timezone=?, variables=?, executor=?, env=?, worktree=?,
+		 sandbox=?, sandbox_network=?, sandbox_hosts=?, cpu_limit=?, memory_limit=?, process_limit=?, output_schema=?, schema_retry=?, assertions=?, report_outcome=?, notify_on_change=?, change_threshold=?
 		 WHERE id=?`,
 		j.Name, j.StartDate, j.IntervalValue, j.IntervalUnit,
-		j.Prompt, j.Active, j.NextRun, j.LastRun, j.Status, j.Output, j.PendingQuestion, j.ID,
+		j.Prompt, j.Active, j.NextRun, j.LastRun, j.Status, j.Output, j.PendingQuestion,
+		j.WorkingDir, j.AddDirs, j.Model, j.FallbackModel, j.MaxTurns,
+		j.SystemPrompt, j.SystemPromptMode, j.SkipDefaultSystemPrompt,
+		j.SessionPolicy, j.SessionRotateRuns, j.SessionMaxTokens, j.SessionID, j.SessionRuns, j.SessionTokens,
+		j.Timezone, j.Variables, j.Executor, j.Env, j.Worktree,
+		j.Sandbox, j.SandboxNetwork, j.SandboxHosts, j.CPULimit, j.MemoryLimit, j.ProcessLimit, j.OutputSchema, j.SchemaRetry, j.Assertions, j.ReportOutcome, j.NotifyOnChange, j.ChangeThreshold, j.ID,
 	)
 	if err != nil {
 		return j, err
@@ -126,8 +481,33 @@ func (s *Store) ResetRunningJobs() (int64, error) {
 	return n, nil
 }
 
-// DeleteJob removes a job by ID. Returns an error if the job does not exist.
+// ResetJobSession clears a job's conversation session so that its next run
+// starts a fresh conversation. It refuses while the job is running or waiting
+// for an answer, since those need the current session.
+func (s *Store) ResetJobSession(id string) error {
+	job, err := s.GetJob(id)
+	if err != nil {
+		return err
+	}
+	if job.Status == "running" || job.Status == "waiting" {
+		return fmt.Errorf("cannot reset the session while the job is %s", job.Status)
+	}
+	_, err = s.db.Exec(
+		`UPDATE jobs SET session_id='', session_runs=0, session_tokens=0 WHERE id=?`, id)
+	return err
+}
+
+// DeleteJob removes a job by ID, along with the artifacts of its runs and
+// the worktrees that still await a decision. Returns an error if the job does
+// not exist.
 func (s *Store) DeleteJob(id string) error {
+	runIDs, err := s.runIDs(`SELECT id FROM job_runs WHERE job_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if err := s.removeWorktrees(id); err != nil {
+		return err
+	}
 	result, err := s.db.Exec("DELETE FROM jobs WHERE id = ?", id)
 	if err != nil {
 		return err
@@ -136,5 +516,5 @@ func (s *Store) DeleteJob(id string) error {
 	if n == 0 {
 		return fmt.Errorf("job not found: %s", id)
 	}
-	return nil
+	return s.deleteArtifacts(runIDs)
 }
@@ -1,6 +1,9 @@
 package db_test
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"claude-schedule/internal/db"
@@ -224,3 +227,483 @@ func TestUpdateJobModifiesScheduleFields(t *testing.T) {
 	require.Equal(t, 30, fetched.IntervalValue)
 	require.Equal(t, "minutes", fetched.IntervalUnit)
 }
+
+func TestCreateJobPersistsDirectories(t *testing.T) {
+	store := openTestStore(t)
+	j := validJob("Repo")
+	j.WorkingDir = "/src/project"
+	j.AddDirs = `["/src/shared"]`
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "/src/project", fetched.WorkingDir)
+	require.Equal(t, `["/src/shared"]`, fetched.AddDirs)
+}
+
+func TestCreateJobDefaultsAddDirsToEmptyArray(t *testing.T) {
+	store := openTestStore(t)
+	created, err := store.CreateJob(validJob("NoDirs"))
+	require.NoError(t, err)
+
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "[]", fetched.AddDirs)
+}
+
+func TestCreateJobValidatesAddDirs(t *testing.T) {
+	store := openTestStore(t)
+	j := validJob("BadDirs")
+	j.AddDirs = "not json"
+	_, err := store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid additional directories")
+}
+
+func TestValidateJobDirs(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0o644))
+
+	j := validJob("Dirs")
+	require.NoError(t, db.ValidateJobDirs(j))
+
+	j.WorkingDir = dir
+	j.AddDirs = `["` + dir + `"]`
+	require.NoError(t, db.ValidateJobDirs(j))
+
+	j.WorkingDir = filepath.Join(dir, "missing")
+	require.Error(t, db.ValidateJobDirs(j))
+
+	j.WorkingDir = file
+	err := db.ValidateJobDirs(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not a directory")
+
+	j.WorkingDir = dir
+	j.AddDirs = `["` + filepath.Join(dir, "missing") + `"]`
+	require.Error(t, db.ValidateJobDirs(j))
+}
+
+func TestCreateJobPersistsModelSettings(t *testing.T) {
+	store := openTestStore(t)
+	j := validJob("Models")
+	j.Model = "opus"
+	j.FallbackModel = "sonnet"
+	j.MaxTurns = 12
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "opus", fetched.Model)
+	require.Equal(t, "sonnet", fetched.FallbackModel)
+	require.Equal(t, 12, fetched.MaxTurns)
+}
+
+func TestCreateJobValidatesModelSettings(t *testing.T) {
+	store := openTestStore(t)
+
+	j := validJob("NegativeTurns")
+	j.MaxTurns = -1
+	_, err := store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "max turns")
+
+	j = validJob("SameFallback")
+	j.Model = "sonnet"
+	j.FallbackModel = "sonnet"
+	_, err = store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fallback model")
+}
+
+func TestCreateJobPersistsSystemPrompt(t *testing.T) {
+	store := openTestStore(t)
+	j := validJob("SystemPrompt")
+	j.SystemPrompt = "You are offline."
+	j.SkipDefaultSystemPrompt = true
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "You are offline.", fetched.SystemPrompt)
+	require.Equal(t, "append", fetched.SystemPromptMode)
+	require.True(t, fetched.SkipDefaultSystemPrompt)
+}
+
+func TestCreateJobValidatesSystemPromptMode(t *testing.T) {
+	store := openTestStore(t)
+
+	j := validJob("BadMode")
+	j.SystemPromptMode = "prepend"
+	_, err := store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid system prompt mode")
+
+	j = validJob("EmptyReplace")
+	j.SystemPromptMode = "replace"
+	_, err = store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "system prompt is required")
+}
+
+func TestCreateJobPersistsSessionSettings(t *testing.T) {
+	store := openTestStore(t)
+	j := validJob("Session")
+	j.SessionPolicy = "rotate"
+	j.SessionRotateRuns = 5
+	j.SessionMaxTokens = 150000
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+
+	created.SessionID = "0b0e4c4e-5f0c-4a36-9a53-7a4c1f0d2b11"
+	created.SessionRuns = 2
+	created.SessionTokens = 4200
+	_, err = store.UpdateJob(created)
+	require.NoError(t, err)
+
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "rotate", fetched.SessionPolicy)
+	require.Equal(t, 5, fetched.SessionRotateRuns)
+	require.Equal(t, int64(150000), fetched.SessionMaxTokens)
+	require.Equal(t, created.SessionID, fetched.SessionID)
+	require.Equal(t, 2, fetched.SessionRuns)
+	require.Equal(t, int64(4200), fetched.SessionTokens)
+}
+
+func TestCreateJobValidatesSessionPolicy(t *testing.T) {
+	store := openTestStore(t)
+
+	created, err := store.CreateJob(validJob("DefaultPolicy"))
+	require.NoError(t, err)
+	require.Equal(t, "resume", created.SessionPolicy)
+
+	j := validJob("BadPolicy")
+	j.SessionPolicy = "forever"
+	_, err = store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid session policy")
+
+	j = validJob("NoThreshold")
+	j.SessionPolicy = "rotate"
+	_, err = store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rotate session policy")
+
+	j = validJob("NegativeThreshold")
+	j.SessionRotateRuns = -1
+	_, err = store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "must not be negative")
+}
+
+func TestResetJobSession(t *testing.T) {
+	store := openTestStore(t)
+	j := validJob("ResetSession")
+	j.SessionID = "0b0e4c4e-5f0c-4a36-9a53-7a4c1f0d2b11"
+	j.SessionRuns = 3
+	j.SessionTokens = 9000
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+
+	require.NoError(t, store.ResetJobSession(created.ID))
+
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Empty(t, fetched.SessionID)
+	require.Zero(t, fetched.SessionRuns)
+	require.Zero(t, fetched.SessionTokens)
+}
+
+func TestResetJobSessionRefusesWhileRunning(t *testing.T) {
+	store := openTestStore(t)
+	j := validJob("BusySession")
+	j.Status = "running"
+	j.SessionID = "0b0e4c4e-5f0c-4a36-9a53-7a4c1f0d2b11"
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+
+	err = store.ResetJobSession(created.ID)
+	require.Error(t, err)
+
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, j.SessionID, fetched.SessionID)
+}
+
+func TestCreateJobPersistsPromptSettings(t *testing.T) {
+	store := openTestStore(t)
+
+	created, err := store.CreateJob(validJob("DefaultVariables"))
+	require.NoError(t, err)
+	require.Equal(t, "{}", created.Variables)
+
+	j := validJob("PromptSettings")
+	j.Timezone = "America/New_York"
+	j.Variables = `{"repo":"acme/api"}`
+	created, err = store.CreateJob(j)
+	require.NoError(t, err)
+
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "America/New_York", fetched.Timezone)
+	vars, err := fetched.VariableMap()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"repo": "acme/api"}, vars)
+}
+
+func TestCreateJobValidatesPromptSettings(t *testing.T) {
+	store := openTestStore(t)
+
+	j := validJob("BadZone")
+	j.Timezone = "Nowhere/Special"
+	_, err := store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid time zone")
+
+	j = validJob("BadVariables")
+	j.Variables = `["not","an","object"]`
+	_, err = store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid prompt variables")
+}
+
+func TestValidateJobPrompt(t *testing.T) {
+	j := validJob("Template")
+	j.Variables = `{"repo":"acme/api"}`
+
+	j.Prompt = "Review {{.Vars.repo}} since {{.LastRun}}"
+	require.NoError(t, db.ValidateJobPrompt(j))
+
+	j.Prompt = "Review {{.Vars.repo"
+	require.Error(t, db.ValidateJobPrompt(j))
+
+	j.Prompt = "Review {{.Vars.branch}}"
+	require.Error(t, db.ValidateJobPrompt(j))
+}
+
+func TestCreateJobAllowsLegacyPromptBraces(t *testing.T) {
+	store := openTestStore(t)
+	j := validJob("Braces")
+	j.Prompt = "Print {{ unbalanced"
+	_, err := store.CreateJob(j)
+	require.NoError(t, err)
+}
+
+func TestCreateJobPersistsExecutor(t *testing.T) {
+	store := openTestStore(t)
+
+	created, err := store.CreateJob(validJob("DefaultExecutor"))
+	require.NoError(t, err)
+	require.Equal(t, "claude", created.Executor)
+
+	j := validJob("ShellExecutor")
+	j.Executor = "shell"
+	created, err = store.CreateJob(j)
+	require.NoError(t, err)
+
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "shell", fetched.Executor)
+}
+
+func TestCreateJobPersistsEnv(t *testing.T) {
+	store := openTestStore(t)
+
+	created, err := store.CreateJob(validJob("DefaultEnv"))
+	require.NoError(t, err)
+	require.Equal(t, "{}", created.Env)
+
+	j := validJob("Env")
+	j.Env = `{"GITHUB_TOKEN":"abc"}`
+	created, err = store.CreateJob(j)
+	require.NoError(t, err)
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	env, err := fetched.EnvMap()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"GITHUB_TOKEN": "abc"}, env)
+
+	j = validJob("BadEnv")
+	j.Env = `{"A":1}`
+	_, err = store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid environment")
+}
+
+func TestCreateJobValidatesWorktree(t *testing.T) {
+	store := openTestStore(t)
+
+	j := validJob("Worktree")
+	j.Worktree = true
+	_, err := store.CreateJob(j)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "working directory is required")
+
+	j.WorkingDir = "/repo"
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.True(t, fetched.Worktree)
+}
+
+func TestCreateJobPersistsSandbox(t *testing.T) {
+	store := openTestStore(t)
+
+	created, err := store.CreateJob(validJob("NoSandbox"))
+	require.NoError(t, err)
+	require.False(t, created.Sandbox)
+	require.Equal(t, "full", created.SandboxNetwork)
+	require.Equal(t, "[]", created.SandboxHosts)
+
+	j := validJob("Sandbox")
+	j.Sandbox = true
+	j.SandboxNetwork = "restricted"
+	j.SandboxHosts = `["api.github.com"]`
+	_, err = store.CreateJob(j)
+	require.ErrorContains(t, err, "working directory is required")
+
+	j.WorkingDir = "/repo"
+	created, err = store.CreateJob(j)
+	require.NoError(t, err)
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.True(t, fetched.Sandbox)
+	require.Equal(t, "restricted", fetched.SandboxNetwork)
+	hosts, err := fetched.SandboxHostList()
+	require.NoError(t, err)
+	require.Equal(t, []string{"api.github.com"}, hosts)
+
+	j.SandboxNetwork = "partial"
+	_, err = store.CreateJob(j)
+	require.ErrorContains(t, err, "invalid sandbox network")
+	j.SandboxNetwork = "full"
+	j.SandboxHosts = "api.github.com"
+	_, err = store.CreateJob(j)
+	require.ErrorContains(t, err, "invalid sandbox hosts")
+}
+
+func TestCreateJobPersistsResourceLimits(t *testing.T) {
+	store := openTestStore(t)
+
+	j := validJob("Limited")
+	j.MemoryLimit = -1
+	_, err := store.CreateJob(j)
+	require.ErrorContains(t, err, "must not be negative")
+
+	j.CPULimit, j.MemoryLimit, j.ProcessLimit = 600, 2048, 200
+	for _, backend := range []string{"api", "shell"} {
+		j.Executor = backend
+		_, err = store.CreateJob(j)
+		require.ErrorContains(t, err, "only supported by the claude executor", backend)
+	}
+
+	j.Executor = "claude"
+	if runtime.GOOS != "linux" {
+		_, err = store.CreateJob(j)
+		require.ErrorContains(t, err, "resource limits need Linux")
+		return
+	}
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, 600, fetched.CPULimit)
+	require.Equal(t, 2048, fetched.MemoryLimit)
+	require.Equal(t, 200, fetched.ProcessLimit)
+}
+
+func TestCreateJobPersistsOutputSchema(t *testing.T) {
+	store := openTestStore(t)
+
+	j := validJob("Structured")
+	j.OutputSchema = `{"type": "objekt"}`
+	_, err := store.CreateJob(j)
+	require.ErrorContains(t, err, "invalid output schema")
+
+	j.OutputSchema = `{"type": "object", "required": ["changed"]}`
+	j.ReportOutcome = true
+	_, err = store.CreateJob(j)
+	require.ErrorContains(t, err, "cannot report an outcome")
+
+	j.ReportOutcome = false
+	for _, backend := range []string{"api", "shell"} {
+		j.Executor = backend
+		_, err = store.CreateJob(j)
+		require.ErrorContains(t, err, "only supported by the claude executor", backend)
+	}
+
+	j.Executor = "claude"
+	j.SchemaRetry = true
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, j.OutputSchema, fetched.OutputSchema)
+	require.True(t, fetched.SchemaRetry)
+}
+
+func TestCreateJobValidatesAssertions(t *testing.T) {
+	store := openTestStore(t)
+
+	created, err := store.CreateJob(validJob("NoAssertions"))
+	require.NoError(t, err)
+	require.Equal(t, "[]", created.Assertions)
+
+	for _, tc := range []struct {
+		assertions string
+		err        string
+	}{
+		{`{"kind": "matches"}`, "invalid assertions"},
+		{`[{"kind": "contains", "value": "x"}]`, "invalid assertion kind"},
+		{`[{"kind": "matches", "value": "("}]`, "invalid assertion pattern"},
+		{`[{"kind": "max_cost", "value": "cheap"}]`, "invalid assertion cost"},
+		{`[{"kind": "max_cost", "value": "-1"}]`, "invalid assertion cost"},
+		{`[{"kind": "judge", "value": " "}]`, "needs a value"},
+	} {
+		j := validJob("Bad")
+		j.Assertions = tc.assertions
+		_, err := store.CreateJob(j)
+		require.ErrorContains(t, err, tc.err, tc.assertions)
+	}
+
+	j := validJob("Asserted")
+	j.Assertions = `[{"kind": "not_matches", "value": "(?i)couldn't access"}, {"kind": "tool_called", "value": "mcp__github__*"}, {"kind": "max_cost", "value": "0.5"}]`
+	created, err = store.CreateJob(j)
+	require.NoError(t, err)
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	list, err := fetched.AssertionList()
+	require.NoError(t, err)
+	require.Equal(t, []db.Assertion{
+		{Kind: db.AssertNotMatches, Value: "(?i)couldn't access"},
+		{Kind: db.AssertToolCalled, Value: "mcp__github__*"},
+		{Kind: db.AssertMaxCost, Value: "0.5"},
+	}, list)
+}
+
+func TestCreateJobValidatesChangeComparison(t *testing.T) {
+	store := openTestStore(t)
+
+	j := validJob("Monitor")
+	j.NotifyOnChange = "fuzzy"
+	_, err := store.CreateJob(j)
+	require.ErrorContains(t, err, "invalid change comparison")
+
+	j.NotifyOnChange = "similarity"
+	_, err = store.CreateJob(j)
+	require.ErrorContains(t, err, "change threshold must be between 0 and 1")
+
+	j.ChangeThreshold = 0.9
+	created, err := store.CreateJob(j)
+	require.NoError(t, err)
+	fetched, err := store.GetJob(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "similarity", fetched.NotifyOnChange)
+	require.Equal(t, 0.9, fetched.ChangeThreshold)
+}
@@ -1,26 +1,127 @@
 package db
 
 import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
 	"fmt"
+	"io"
+	"strings"
+
+	"claude-schedule/internal/transcript"
 
 	"github.com/google/uuid"
 )
 
 const (
 	maxRunsPerJob   = 10
-	maxOutputBytes  = 100 * 1024 // 100 KB
+	maxOutputBytes  = 100 * 1024      // 100 KB
+	maxEventsBytes  = 1024 * 1024     // 1 MB
+	maxRawBytes     = 4 * 1024 * 1024 // 4 MB compressed
 	truncatedMarker = "\n\n[truncated]"
 )
 
 // JobRun represents a single execution of a scheduled job.
 type JobRun struct {
-	ID              string `json:"id"`
-	JobID           string `json:"jobId"`
-	StartedAt       string `json:"startedAt"`
-	EndedAt         string `json:"endedAt"`
-	Status          string `json:"status"`
-	Output          string `json:"output"`
-	PendingQuestion string `json:"pendingQuestion"`
+	ID                string `json:"id"`
+	JobID             string `json:"jobId"`
+	StartedAt         string `json:"startedAt"`
+	EndedAt           string `json:"endedAt"`
+	Status            string `json:"status"`
+	Output            string `json:"output"`
+	PendingQuestion   string `json:"pendingQuestion"`
+	Model             string `json:"model"`             // model reported by the CLI's init event
+	SessionID         string `json:"sessionId"`         // conversation the run used
+	FollowUpSessionID string `json:"followUpSessionId"` // fork of SessionID that follow-ups continue
+	Summary           string `json:"summary"`           // final result text of the run
+	Events            string `json:"-"`                 // structured transcript as JSON, see GetRunEvents
+	RenderVersion     int    `json:"renderVersion"`     // transcript.Version Output was rendered with; 0 if not rendered from Events
+
+	// Runs of jobs with Worktree set work in a git worktree of their own.
+	Worktree      string `json:"worktree"`      // path of the worktree, empty if the run had none
+	Branch        string `json:"branch"`        // branch checked out in the worktree
+	BaseCommit    string `json:"baseCommit"`    // commit the branch started from
+	Diff          string `json:"diff"`          // changes on the branch since BaseCommit
+	Commits       string `json:"commits"`       // JSON array of the commits on the branch, newest first
+	WorktreeState string `json:"worktreeState"` // one of the WorktreeState constants
+
+	FailureReason    string `json:"failureReason"`    // one of the Failure constants when known, else empty
+	StructuredOutput string `json:"structuredOutput"` // final result as JSON, for jobs with an output schema
+
+	AssertionFailures string `json:"assertionFailures"` // JSON array of why the run failed its job's assertions
+	Outcome           string `json:"outcome"`           // one of the Outcome constants if the run reported one, else empty
+	ResultDiff        string `json:"-"`                 // unified diff of Summary against the previous successful run's, see GetRunDiff
+
+	Usage
+}
+
+// States of a run's worktree.
+const (
+	WorktreeActive    = "active"    // the worktree exists and awaits a decision
+	WorktreeKept      = "kept"      // the worktree was removed but its branch kept
+	WorktreeDiscarded = "discarded" // the worktree and its branch were removed
+	WorktreeMerged    = "merged"    // the branch was merged into the repository
+)
+
+// Reasons a run failed, beyond the error in its output.
+const (
+	FailureCPULimit     = "cpu_limit"     // the job's CPU time limit was used up
+	FailureMemoryLimit  = "memory_limit"  // the job's memory limit was reached
+	FailureProcessLimit = "process_limit" // the job's process limit was reached
+	FailureOutputSchema = "output_schema" // the final result did not match the job's output schema
+)
+
+// Outcomes a successful run can report, beyond having succeeded.
+const (
+	OutcomeChanged   = "changed"   // the run found or made a change
+	OutcomeUnchanged = "unchanged" // the run found nothing new
+	OutcomeNoOp      = "no_op"     // the run had nothing to do
+)
+
+// Usage holds the resource consumption reported by the CLI's result event.
+type Usage struct {
+	DurationMs          int64   `json:"durationMs"`
+	NumTurns            int64   `json:"numTurns"`
+	InputTokens         int64   `json:"inputTokens"`
+	OutputTokens        int64   `json:"outputTokens"`
+	CacheCreationTokens int64   `json:"cacheCreationTokens"`
+	CacheReadTokens     int64   `json:"cacheReadTokens"`
+	CostUSD             float64 `json:"costUsd"`
+}
+
+// Add accumulates other into u, e.g. when a run is resumed after a question.
+func (u *Usage) Add(other Usage) {
+	u.DurationMs += other.DurationMs
+	u.NumTurns += other.NumTurns
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationTokens += other.CacheCreationTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CostUSD += other.CostUSD
+}
+
+// JobUsage aggregates the usage of a job's runs over a time range.
+type JobUsage struct {
+	JobID   string `json:"jobId"`
+	JobName string `json:"jobName"`
+	Deleted bool   `json:"deleted"` // the job no longer exists
+	Runs    int64  `json:"runs"`
+	Usage
+}
+
+// runColumns lists the job_runs table columns in the order scanRun expects.
+const runColumns = "id, job_id, started_at, ended_at, status, output, pending_question, model, session_id, follow_up_session_id, summary, events, render_version, " +
+	"worktree, branch, base_commit, diff, commits, worktree_state, failure_reason, structured_output, assertion_failures, outcome, result_diff, " +
+	"duration_ms, num_turns, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, cost_usd"
+
+// scanRun reads a row selected with runColumns into a JobRun.
+func scanRun(row interface{ Scan(...any) error }) (JobRun, error) {
+	var r JobRun
+	err := row.Scan(&r.ID, &r.JobID, &r.StartedAt, &r.EndedAt, &r.Status, &r.Output, &r.PendingQuestion,
+		&r.Model, &r.SessionID, &r.FollowUpSessionID, &r.Summary, &r.Events, &r.RenderVersion,
+		&r.Worktree, &r.Branch, &r.BaseCommit, &r.Diff, &r.Commits, &r.WorktreeState, &r.FailureReason, &r.StructuredOutput, &r.AssertionFailures, &r.Outcome, &r.ResultDiff, &r.DurationMs, &r.NumTurns, &r.InputTokens, &r.OutputTokens,
+		&r.CacheCreationTokens, &r.CacheReadTokens, &r.CostUSD)
+	return r, err
 }
 
 // truncateOutput trims output to maxOutputBytes and appends a marker if truncated.
@@ -31,28 +132,83 @@ func truncateOutput(s string) string {
 	return s[:maxOutputBytes-len(truncatedMarker)] + truncatedMarker
 }
 
+// limitEvents returns the events JSON to store. Events cannot be cut without
+// breaking the JSON, so an oversized list is dropped entirely; the run keeps
+// its rendered output but can no longer be rendered again.
+func limitEvents(s string) string {
+	if s == "" || len(s) > maxEventsBytes {
+		return "[]"
+	}
+	return s
+}
+
 // CreateRun inserts a new job run with an auto-generated UUID.
 func (s *Store) CreateRun(run JobRun) (JobRun, error) {
 	if run.ID == "" {
 		run.ID = uuid.New().String()
 	}
 	run.Output = truncateOutput(run.Output)
+	run.Summary = truncateOutput(run.Summary)
+	run.Events = limitEvents(run.Events)
+	run.Diff = truncateOutput(run.Diff)
+	run.ResultDiff = truncateOutput(run.ResultDiff)
+	if run.Commits == "" {
+		run.Commits = "[]"
+	}
+	if run.AssertionFailures == "" {
+		run.AssertionFailures = "[]"
+	}
 
-	_, err := s.db.Exec(
-		`INSERT INTO job_runs (id, job_id, started_at, ended_at, status, output, pending_question)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+	tx, err := s.db.Begin()
+	if err != nil {
+		return run, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO job_runs (`+runColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		run.ID, run.JobID, run.StartedAt, run.EndedAt, run.Status, run.Output, run.PendingQuestion,
+		run.Model, run.SessionID, run.FollowUpSessionID, run.Summary, run.Events, run.RenderVersion,
+		run.Worktree, run.Branch, run.BaseCommit, run.Diff, run.Commits, run.WorktreeState, run.FailureReason, run.StructuredOutput, run.AssertionFailures, run.Outcome, run.ResultDiff, run.DurationMs, run.NumTurns, run.InputTokens, run.OutputTokens,
+		run.CacheCreationTokens, run.CacheReadTokens, run.CostUSD,
 	)
-	return run, err
+	if err != nil {
+		return run, err
+	}
+	if err := recordUsage(tx, run.ID); err != nil {
+		return run, err
+	}
+	return run, tx.Commit()
 }
 
 // UpdateRun updates an existing run's status, output, and ended_at.
 func (s *Store) UpdateRun(run JobRun) error {
 	run.Output = truncateOutput(run.Output)
+	run.Summary = truncateOutput(run.Summary)
+	run.Events = limitEvents(run.Events)
+	run.Diff = truncateOutput(run.Diff)
+	run.ResultDiff = truncateOutput(run.ResultDiff)
+	if run.Commits == "" {
+		run.Commits = "[]"
+	}
+	if run.AssertionFailures == "" {
+		run.AssertionFailures = "[]"
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	result, err := s.db.Exec(
-		`UPDATE job_runs SET status=?, output=?, ended_at=?, pending_question=? WHERE id=?`,
-		run.Status, run.Output, run.EndedAt, run.PendingQuestion, run.ID,
+	result, err := tx.Exec(
+		`UPDATE job_runs SET status=?, output=?, ended_at=?, pending_question=?, model=?, session_id=?, follow_up_session_id=?, summary=?,
+		 events=?, render_version=?, worktree=?, branch=?, base_commit=?, diff=?, commits=?, worktree_state=?, failure_reason=?, structured_output=?, assertion_failures=?, outcome=?, result_diff=?, duration_ms=?, num_turns=?, input_tokens=?, output_tokens=?, cache_creation_tokens=?, cache_read_tokens=?, cost_usd=?
+		 WHERE id=?`,
+		run.Status, run.Output, run.EndedAt, run.PendingQuestion, run.Model, run.SessionID, run.FollowUpSessionID, run.Summary,
+		run.Events, run.RenderVersion, run.Worktree, run.Branch, run.BaseCommit, run.Diff, run.Commits, run.WorktreeState, run.FailureReason, run.StructuredOutput, run.AssertionFailures, run.Outcome, run.ResultDiff, run.DurationMs, run.NumTurns, run.InputTokens, run.OutputTokens,
+		run.CacheCreationTokens, run.CacheReadTokens, run.CostUSD, run.ID,
 	)
 	if err != nil {
 		return err
@@ -61,13 +217,40 @@ func (s *Store) UpdateRun(run JobRun) error {
 	if n == 0 {
 		return fmt.Errorf("run not found: %s", run.ID)
 	}
-	return nil
+	if err := recordUsage(tx, run.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// usageColumns lists the usage counters job_runs and run_usage share.
+const usageColumns = "duration_ms, num_turns, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, cost_usd"
+
+// runUsageColumns lists usageColumns of job_runs selected as r.
+const runUsageColumns = "r.duration_ms, r.num_turns, r.input_tokens, r.output_tokens, r.cache_creation_tokens, r.cache_read_tokens, r.cost_usd"
+
+// recordUsage copies a run's usage and its job's name into run_usage, where
+// they outlive the run and the job.
+func recordUsage(tx *sql.Tx, runID string) error {
+	_, err := tx.Exec(
+		`INSERT INTO run_usage (run_id, job_id, job_name, started_at, `+usageColumns+`)
+		 SELECT r.id, r.job_id, COALESCE(j.name, ''), r.started_at, `+runUsageColumns+`
+		 FROM job_runs r LEFT JOIN jobs j ON j.id = r.job_id WHERE r.id = ?
+		 ON CONFLICT(run_id) DO UPDATE SET
+		   job_name=excluded.job_name,
+		   duration_ms=excluded.duration_ms, num_turns=excluded.num_turns,
+		   input_tokens=excluded.input_tokens, output_tokens=excluded.output_tokens,
+		   cache_creation_tokens=excluded.cache_creation_tokens, cache_read_tokens=excluded.cache_read_tokens,
+		   cost_usd=excluded.cost_usd`,
+		runID,
+	)
+	return err
 }
 
 // GetRunsForJob returns the most recent runs for a job, ordered newest first.
 func (s *Store) GetRunsForJob(jobID string) ([]JobRun, error) {
 	rows, err := s.db.Query(
-		`SELECT id, job_id, started_at, ended_at, status, output, pending_question
+		`SELECT `+runColumns+`
 		 FROM job_runs WHERE job_id = ?
 		 ORDER BY started_at DESC LIMIT ?`,
 		jobID, maxRunsPerJob,
@@ -79,8 +262,8 @@ func (s *Store) GetRunsForJob(jobID string) ([]JobRun, error) {
 
 	runs := []JobRun{}
 	for rows.Next() {
-		var r JobRun
-		if err := rows.Scan(&r.ID, &r.JobID, &r.StartedAt, &r.EndedAt, &r.Status, &r.Output, &r.PendingQuestion); err != nil {
+		r, err := scanRun(rows)
+		if err != nil {
 			return nil, err
 		}
 		runs = append(runs, r)
@@ -88,32 +271,282 @@ func (s *Store) GetRunsForJob(jobID string) ([]JobRun, error) {
 	return runs, rows.Err()
 }
 
+// GetRun returns a single run by ID.
+func (s *Store) GetRun(id string) (JobRun, error) {
+	return scanRun(s.db.QueryRow(
+		`SELECT `+runColumns+` FROM job_runs WHERE id = ?`,
+		id,
+	))
+}
+
+// GetRunEvents returns the structured transcript of a run.
+func (s *Store) GetRunEvents(id string) ([]transcript.Event, error) {
+	var events string
+	if err := s.db.QueryRow(`SELECT events FROM job_runs WHERE id = ?`, id).Scan(&events); err != nil {
+		return nil, err
+	}
+	return transcript.Decode(events)
+}
+
+// GetRunDiff returns how a run's final result differs from the previous
+// successful run's, as a unified diff. It is empty unless the run's job
+// compares results.
+func (s *Store) GetRunDiff(id string) (string, error) {
+	var diff string
+	err := s.db.QueryRow(`SELECT result_diff FROM job_runs WHERE id = ?`, id).Scan(&diff)
+	return diff, err
+}
+
+// AppendRawEvents adds lines to the raw event stream stored with a run. The
+// stream is kept gzip-compressed; lines that would take it past maxRawBytes
+// are not stored and an error is returned.
+func (s *Store) AppendRawEvents(id string, lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var stored []byte
+	if err := tx.QueryRow(`SELECT raw_events FROM job_runs WHERE id = ?`, id).Scan(&stored); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("run not found: %s", id)
+		}
+		return err
+	}
+	prev, err := gunzipLines(stored)
+	if err != nil {
+		return err
+	}
+	data, err := gzipLines(append(prev, lines...))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxRawBytes {
+		return fmt.Errorf("raw events of run %s exceed %d bytes", id, maxRawBytes)
+	}
+	if _, err := tx.Exec(`UPDATE job_runs SET raw_events = ? WHERE id = ?`, data, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetRawEvents returns the raw event stream stored with a run, one JSON
+// object per line.
+func (s *Store) GetRawEvents(id string) ([]string, error) {
+	var stored []byte
+	if err := s.db.QueryRow(`SELECT raw_events FROM job_runs WHERE id = ?`, id).Scan(&stored); err != nil {
+		return nil, err
+	}
+	return gunzipLines(stored)
+}
+
+func gzipLines(lines []string) ([]byte, error) {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	for _, line := range lines {
+		if _, err := io.WriteString(zw, line+"\n"); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func gunzipLines(data []byte) ([]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw events: %w", err)
+	}
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw events: %w", err)
+	}
+	if len(text) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(string(text), "\n"), "\n"), nil
+}
+
+// RerenderRuns renders the output of finished runs again from their events
+// when it was rendered by a version of the renderer older than version, and
+// returns how many runs were updated. Runs whose output did not come from
+// their events are left alone.
+func (s *Store) RerenderRuns(version int, render func([]transcript.Event) string) (int, error) {
+	rows, err := s.db.Query(
+		`SELECT id, events FROM job_runs
+		 WHERE render_version > 0 AND render_version < ? AND status NOT IN ('running', 'waiting') AND events != '[]'`,
+		version,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type stale struct{ id, events string }
+	var runs []stale
+	for rows.Next() {
+		var r stale
+		if err := rows.Scan(&r.id, &r.events); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		runs = append(runs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, r := range runs {
+		events, err := transcript.Decode(r.events)
+		if err != nil {
+			continue
+		}
+		if _, err := s.db.Exec(`UPDATE job_runs SET output = ?, render_version = ? WHERE id = ?`,
+			truncateOutput(render(events)), version, r.id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
 // GetLatestRun returns the most recent run for a job.
 func (s *Store) GetLatestRun(jobID string) (JobRun, error) {
-	var r JobRun
-	err := s.db.QueryRow(
-		`SELECT id, job_id, started_at, ended_at, status, output, pending_question
+	return scanRun(s.db.QueryRow(
+		`SELECT `+runColumns+`
 		 FROM job_runs WHERE job_id = ?
 		 ORDER BY started_at DESC LIMIT 1`,
 		jobID,
-	).Scan(&r.ID, &r.JobID, &r.StartedAt, &r.EndedAt, &r.Status, &r.Output, &r.PendingQuestion)
-	return r, err
+	))
+}
+
+// GetLastSuccessfulRun returns the most recent successful run for a job.
+func (s *Store) GetLastSuccessfulRun(jobID string) (JobRun, error) {
+	return scanRun(s.db.QueryRow(
+		`SELECT `+runColumns+`
+		 FROM job_runs WHERE job_id = ? AND status = 'success'
+		 ORDER BY started_at DESC LIMIT 1`,
+		jobID,
+	))
+}
+
+// GetUsageStats aggregates run usage per job for runs started within
+// [from, to). Both bounds are RFC3339 timestamps; an empty bound is open.
+// Jobs are ordered by cost, most expensive first. Usage is read from
+// run_usage, so runs removed by PruneRuns and jobs since deleted are still
+// counted; a deleted job goes by the name it had at its last run.
+func (s *Store) GetUsageStats(from, to string) ([]JobUsage, error) {
+	rows, err := s.db.Query(
+		`SELECT r.job_id,
+		        COALESCE(j.name, (SELECT job_name FROM run_usage WHERE job_id = r.job_id ORDER BY started_at DESC LIMIT 1)),
+		        j.id IS NULL, COUNT(*),
+		        SUM(r.duration_ms), SUM(r.num_turns), SUM(r.input_tokens), SUM(r.output_tokens),
+		        SUM(r.cache_creation_tokens), SUM(r.cache_read_tokens), SUM(r.cost_usd)
+		 FROM run_usage r
+		 LEFT JOIN jobs j ON j.id = r.job_id
+		 WHERE (? = '' OR r.started_at >= ?) AND (? = '' OR r.started_at < ?)
+		 GROUP BY r.job_id
+		 ORDER BY SUM(r.cost_usd) DESC, 2`,
+		from, from, to, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []JobUsage{}
+	for rows.Next() {
+		var u JobUsage
+		if err := rows.Scan(&u.JobID, &u.JobName, &u.Deleted, &u.Runs,
+			&u.DurationMs, &u.NumTurns, &u.InputTokens, &u.OutputTokens,
+			&u.CacheCreationTokens, &u.CacheReadTokens, &u.CostUSD); err != nil {
+			return nil, err
+		}
+		stats = append(stats, u)
+	}
+	return stats, rows.Err()
+}
+
+// SetWorktreeRemover sets the function that removes the worktree and branch
+// of a run whose worktree still awaits a decision, for when the run is
+// deleted with its job or the job's other runs.
+func (s *Store) SetWorktreeRemover(fn func(run JobRun)) {
+	s.removeWorktree = fn
+}
+
+// removeWorktrees removes the worktrees of a job's runs that still await a
+// decision, which nothing leads back to once the runs are gone.
+func (s *Store) removeWorktrees(jobID string) error {
+	if s.removeWorktree == nil {
+		return nil
+	}
+	rows, err := s.db.Query(`SELECT id, worktree, branch FROM job_runs WHERE job_id = ? AND worktree_state = ?`,
+		jobID, WorktreeActive)
+	if err != nil {
+		return err
+	}
+	var runs []JobRun
+	for rows.Next() {
+		run := JobRun{JobID: jobID, WorktreeState: WorktreeActive}
+		if err := rows.Scan(&run.ID, &run.Worktree, &run.Branch); err != nil {
+			rows.Close()
+			return err
+		}
+		runs = append(runs, run)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, run := range runs {
+		s.removeWorktree(run)
+	}
+	return nil
 }
 
-// PruneRuns deletes all but the most recent maxRunsPerJob runs for a job.
+// PruneRuns deletes all but the most recent maxRunsPerJob runs for a job,
+// along with their artifacts. Runs whose worktree still awaits a decision are
+// kept so their changes are not lost track of.
 func (s *Store) PruneRuns(jobID string) error {
-	_, err := s.db.Exec(
-		`DELETE FROM job_runs WHERE job_id = ? AND id NOT IN (
+	ids, err := s.runIDs(
+		`SELECT id FROM job_runs WHERE job_id = ? AND worktree_state != ? AND id NOT IN (
 			SELECT id FROM job_runs WHERE job_id = ?
 			ORDER BY started_at DESC LIMIT ?
 		)`,
-		jobID, jobID, maxRunsPerJob,
+		jobID, WorktreeActive, jobID, maxRunsPerJob,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := s.db.Exec(`DELETE FROM job_runs WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return s.deleteArtifacts(ids)
 }
 
-// DeleteRunsForJob removes all runs for a given job.
+// DeleteRunsForJob removes all runs for a given job, along with their
+// artifacts and the worktrees that still await a decision.
 func (s *Store) DeleteRunsForJob(jobID string) error {
-	_, err := s.db.Exec(`DELETE FROM job_runs WHERE job_id = ?`, jobID)
-	return err
+	ids, err := s.runIDs(`SELECT id FROM job_runs WHERE job_id = ?`, jobID)
+	if err != nil {
+		return err
+	}
+	if err := s.removeWorktrees(jobID); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM job_runs WHERE job_id = ?`, jobID); err != nil {
+		return err
+	}
+	return s.deleteArtifacts(ids)
 }
@@ -0,0 +1,456 @@
+package db_test
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/transcript"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createTestRun(t *testing.T, store *db.Store, jobID string, startedAt string) db.JobRun {
+	t.Helper()
+	run, err := store.CreateRun(db.JobRun{
+		JobID:     jobID,
+		StartedAt: startedAt,
+		Status:    "running",
+	})
+	require.NoError(t, err)
+	return run
+}
+
+func TestUpdateRunPersistsModel(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Runs"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	run.Status = "success"
+	run.Model = "claude-sonnet-4-5"
+	require.NoError(t, store.UpdateRun(run))
+
+	latest, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, run.ID, latest.ID)
+	require.Equal(t, "success", latest.Status)
+	require.Equal(t, "claude-sonnet-4-5", latest.Model)
+}
+
+func TestUpdateRunPersistsUsage(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Usage"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	run.Usage = db.Usage{
+		DurationMs:          1500,
+		NumTurns:            3,
+		InputTokens:         100,
+		OutputTokens:        200,
+		CacheCreationTokens: 10,
+		CacheReadTokens:     20,
+		CostUSD:             0.25,
+	}
+	require.NoError(t, store.UpdateRun(run))
+
+	runs, err := store.GetRunsForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, run.Usage, runs[0].Usage)
+}
+
+func TestUsageAdd(t *testing.T) {
+	u := db.Usage{NumTurns: 1, InputTokens: 10, CostUSD: 0.5}
+	u.Add(db.Usage{NumTurns: 2, InputTokens: 5, CostUSD: 0.25, DurationMs: 100})
+	require.Equal(t, db.Usage{NumTurns: 3, InputTokens: 15, CostUSD: 0.75, DurationMs: 100}, u)
+}
+
+func TestGetUsageStatsAggregatesPerJob(t *testing.T) {
+	store := openTestStore(t)
+	cheap, err := store.CreateJob(validJob("Cheap"))
+	require.NoError(t, err)
+	costly, err := store.CreateJob(validJob("Costly"))
+	require.NoError(t, err)
+
+	for _, r := range []struct {
+		jobID     string
+		startedAt string
+		cost      float64
+	}{
+		{cheap.ID, "2026-02-01T00:00:00Z", 0.10},
+		{costly.ID, "2026-02-01T01:00:00Z", 1.00},
+		{costly.ID, "2026-02-02T01:00:00Z", 2.00},
+		{costly.ID, "2026-03-01T00:00:00Z", 4.00}, // outside range
+	} {
+		run := createTestRun(t, store, r.jobID, r.startedAt)
+		run.CostUSD = r.cost
+		run.NumTurns = 1
+		require.NoError(t, store.UpdateRun(run))
+	}
+
+	stats, err := store.GetUsageStats("2026-02-01T00:00:00Z", "2026-03-01T00:00:00Z")
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	require.Equal(t, "Costly", stats[0].JobName)
+	require.Equal(t, int64(2), stats[0].Runs)
+	require.Equal(t, int64(2), stats[0].NumTurns)
+	require.InDelta(t, 3.00, stats[0].CostUSD, 0.0001)
+
+	require.Equal(t, "Cheap", stats[1].JobName)
+	require.Equal(t, int64(1), stats[1].Runs)
+
+	all, err := store.GetUsageStats("", "")
+	require.NoError(t, err)
+	require.InDelta(t, 7.00, all[0].CostUSD, 0.0001)
+}
+
+func TestGetUsageStatsCountsPrunedRuns(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Busy"))
+	require.NoError(t, err)
+
+	for i := 0; i < 12; i++ {
+		run := createTestRun(t, store, job.ID, fmt.Sprintf("2026-02-01T00:%02d:00Z", i))
+		run.CostUSD = 0.25
+		require.NoError(t, store.UpdateRun(run))
+	}
+	require.NoError(t, store.PruneRuns(job.ID))
+	runs, err := store.GetRunsForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 10)
+
+	stats, err := store.GetUsageStats("", "")
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, int64(12), stats[0].Runs)
+	require.InDelta(t, 3.00, stats[0].CostUSD, 0.0001)
+
+	require.False(t, stats[0].Deleted)
+}
+
+func TestGetUsageStatsCountsDeletedJobs(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Nightly"))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		run := createTestRun(t, store, job.ID, fmt.Sprintf("2026-02-01T00:%02d:00Z", i))
+		run.CostUSD = 0.50
+		require.NoError(t, store.UpdateRun(run))
+	}
+
+	require.NoError(t, store.DeleteJob(job.ID))
+	stats, err := store.GetUsageStats("", "")
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, job.ID, stats[0].JobID)
+	require.Equal(t, "Nightly", stats[0].JobName)
+	require.True(t, stats[0].Deleted)
+	require.Equal(t, int64(3), stats[0].Runs)
+	require.InDelta(t, 1.50, stats[0].CostUSD, 0.0001)
+}
+
+func TestGetRunReturnsSessions(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("GetRun"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	run.SessionID = "session-1"
+	run.FollowUpSessionID = "fork-1"
+	require.NoError(t, store.UpdateRun(run))
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, job.ID, got.JobID)
+	require.Equal(t, "session-1", got.SessionID)
+	require.Equal(t, "fork-1", got.FollowUpSessionID)
+
+	_, err = store.GetRun("missing")
+	require.Error(t, err)
+}
+
+func TestUpdateRunPersistsSummary(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Summary"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	run.Summary = "All checks passed."
+	require.NoError(t, store.UpdateRun(run))
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, "All checks passed.", got.Summary)
+}
+
+func TestRunEvents(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Events"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	events, err := store.GetRunEvents(run.ID)
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	run.Events = transcript.Encode([]transcript.Event{{Kind: transcript.KindText, Text: "hi"}})
+	run.RenderVersion = 1
+	require.NoError(t, store.UpdateRun(run))
+
+	events, err = store.GetRunEvents(run.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "hi", events[0].Text)
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, got.RenderVersion)
+}
+
+func TestRunEvents_DropsOversizedList(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Big"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	run.Events = transcript.Encode([]transcript.Event{{Kind: transcript.KindText, Text: strings.Repeat("x", 2*1024*1024)}})
+	require.NoError(t, store.UpdateRun(run))
+
+	events, err := store.GetRunEvents(run.ID)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestRerenderRuns(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Rerender"))
+	require.NoError(t, err)
+	events := transcript.Encode([]transcript.Event{{Kind: transcript.KindText, Text: "hello"}})
+
+	stale := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	stale.Status, stale.Output, stale.Events, stale.RenderVersion = "success", "old", events, 1
+	require.NoError(t, store.UpdateRun(stale))
+
+	// Output that was not rendered from events, and runs still in progress,
+	// are left alone.
+	plain := createTestRun(t, store, job.ID, "2026-02-02T00:00:00Z")
+	plain.Status, plain.Output, plain.Events = "failed", "exit status 1", events
+	require.NoError(t, store.UpdateRun(plain))
+	waiting := createTestRun(t, store, job.ID, "2026-02-03T00:00:00Z")
+	waiting.Status, waiting.Output, waiting.Events, waiting.RenderVersion = "waiting", "old", events, 1
+	require.NoError(t, store.UpdateRun(waiting))
+
+	render := func(events []transcript.Event) string { return "v2: " + events[0].Text }
+	n, err := store.RerenderRuns(2, render)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	got, err := store.GetRun(stale.ID)
+	require.NoError(t, err)
+	require.Equal(t, "v2: hello", got.Output)
+	require.Equal(t, 2, got.RenderVersion)
+
+	got, err = store.GetRun(plain.ID)
+	require.NoError(t, err)
+	require.Equal(t, "exit status 1", got.Output)
+	got, err = store.GetRun(waiting.ID)
+	require.NoError(t, err)
+	require.Equal(t, "old", got.Output)
+
+	// Up-to-date runs are not rendered again.
+	n, err = store.RerenderRuns(2, render)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestRawEvents(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Raw"))
+	require.NoError(t, err)
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+
+	got, err := store.GetRawEvents(run.ID)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	// Each invocation's lines are appended to what the run already has.
+	require.NoError(t, store.AppendRawEvents(run.ID, []string{`{"type":"system"}`, `{"type":"result"}`}))
+	require.NoError(t, store.AppendRawEvents(run.ID, nil))
+	require.NoError(t, store.AppendRawEvents(run.ID, []string{`{"type":"assistant"}`}))
+	got, err = store.GetRawEvents(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"type":"system"}`, `{"type":"result"}`, `{"type":"assistant"}`}, got)
+
+	// Listing runs does not load the stream.
+	runs, err := store.GetRunsForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+
+	require.Error(t, store.AppendRawEvents("missing", []string{"{}"}))
+}
+
+func TestRawEvents_RejectsOversizedStream(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Raw"))
+	require.NoError(t, err)
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	require.NoError(t, store.AppendRawEvents(run.ID, []string{`{"type":"system"}`}))
+
+	// Hex-encoded random data compresses to half its size, well over the
+	// 4 MB limit.
+	noise := make([]byte, 6*1024*1024)
+	_, err = rand.Read(noise)
+	require.NoError(t, err)
+	require.Error(t, store.AppendRawEvents(run.ID, []string{hex.EncodeToString(noise)}))
+
+	got, err := store.GetRawEvents(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"type":"system"}`}, got)
+}
+
+func TestUpdateRunPersistsWorktree(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Worktree"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	require.Equal(t, "[]", run.Commits)
+	run.Worktree = "/data/worktrees/" + run.ID
+	run.Branch = "claude-schedule/run-1"
+	run.BaseCommit = "abc123"
+	run.Diff = "+notes"
+	run.Commits = `[{"hash":"def456","subject":"Fix","author":"A","date":"2026-02-01T00:01:00Z"}]`
+	run.WorktreeState = db.WorktreeActive
+	require.NoError(t, store.UpdateRun(run))
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, run.Worktree, got.Worktree)
+	require.Equal(t, run.Branch, got.Branch)
+	require.Equal(t, run.BaseCommit, got.BaseCommit)
+	require.Equal(t, run.Diff, got.Diff)
+	require.Equal(t, run.Commits, got.Commits)
+	require.Equal(t, db.WorktreeActive, got.WorktreeState)
+}
+
+func TestUpdateRunPersistsFailureReason(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Failure"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	require.Empty(t, run.FailureReason)
+	run.Status = "failed"
+	run.FailureReason = db.FailureMemoryLimit
+	require.NoError(t, store.UpdateRun(run))
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.FailureMemoryLimit, got.FailureReason)
+}
+
+func TestUpdateRunPersistsStructuredOutput(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Structured"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	run.Status = "success"
+	run.StructuredOutput = `{"changed":true}`
+	require.NoError(t, store.UpdateRun(run))
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, `{"changed":true}`, got.StructuredOutput)
+}
+
+func TestUpdateRunPersistsAssertionFailures(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Asserted"))
+	require.NoError(t, err)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	require.Equal(t, "[]", run.AssertionFailures)
+	run.Status = "assertion_failed"
+	run.AssertionFailures = `["the run cost $0.62, more than $0.50"]`
+	require.NoError(t, store.UpdateRun(run))
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, "assertion_failed", got.Status)
+	require.Equal(t, run.AssertionFailures, got.AssertionFailures)
+}
+
+func TestUpdateRunPersistsOutcome(t *testing.T) {
+	store := openTestStore(t)
+	j := validJob("Monitor")
+	j.ReportOutcome = true
+	job, err := store.CreateJob(j)
+	require.NoError(t, err)
+	fetched, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	require.True(t, fetched.ReportOutcome)
+
+	run := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	require.Empty(t, run.Outcome)
+	run.Status = "success"
+	run.Outcome = db.OutcomeUnchanged
+	require.NoError(t, store.UpdateRun(run))
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.OutcomeUnchanged, got.Outcome)
+}
+
+func TestGetLastSuccessfulRun(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Monitor"))
+	require.NoError(t, err)
+
+	_, err = store.GetLastSuccessfulRun(job.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	first := createTestRun(t, store, job.ID, "2026-02-01T00:00:00Z")
+	first.Status = "success"
+	first.Summary = "three plans"
+	require.NoError(t, store.UpdateRun(first))
+	second := createTestRun(t, store, job.ID, "2026-02-01T01:00:00Z")
+	second.Status = "failed"
+	second.ResultDiff = "-three plans\n+four plans\n"
+	require.NoError(t, store.UpdateRun(second))
+	createTestRun(t, store, job.ID, "2026-02-01T02:00:00Z")
+
+	got, err := store.GetLastSuccessfulRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, first.ID, got.ID)
+
+	diff, err := store.GetRunDiff(second.ID)
+	require.NoError(t, err)
+	require.Equal(t, second.ResultDiff, diff)
+}
+
+func TestPruneRunsKeepsActiveWorktrees(t *testing.T) {
+	store := openTestStore(t)
+	job, err := store.CreateJob(validJob("Prune"))
+	require.NoError(t, err)
+
+	var runs []db.JobRun
+	for i := 0; i < 12; i++ {
+		runs = append(runs, createTestRun(t, store, job.ID, fmt.Sprintf("2026-02-01T00:%02d:00Z", i)))
+	}
+	runs[0].WorktreeState = db.WorktreeActive
+	require.NoError(t, store.UpdateRun(runs[0]))
+
+	require.NoError(t, store.PruneRuns(job.ID))
+	_, err = store.GetRun(runs[0].ID)
+	require.NoError(t, err)
+	_, err = store.GetRun(runs[1].ID)
+	require.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Settings holds global application settings persisted in the settings table.
+type Settings struct {
+	DefaultSystemPrompt string `json:"defaultSystemPrompt"` // appended to every CLI job unless it opts out
+	AnthropicAPIKey     string `json:"anthropicApiKey"`     // used by the "api" executor
+	ClaudePath          string `json:"claudePath"`          // CLI binary; empty auto-detects it
+	ClaudeArgs          string `json:"claudeArgs"`          // JSON array string of extra CLI arguments
+	ClaudeEnv           string `json:"claudeEnv"`           // JSON object string of environment overrides for the CLI
+}
+
+// ClaudeArgList parses ClaudeArgs into a slice.
+func (st Settings) ClaudeArgList() ([]string, error) {
+	return parseStringList(st.ClaudeArgs, "extra CLI arguments")
+}
+
+// ClaudeEnvMap parses ClaudeEnv into a map.
+func (st Settings) ClaudeEnvMap() (map[string]string, error) {
+	return parseStringMap(st.ClaudeEnv, "CLI environment")
+}
+
+// Keys used in the settings table.
+const (
+	settingDefaultSystemPrompt = "default_system_prompt"
+	settingAnthropicAPIKey     = "anthropic_api_key"
+	settingClaudePath          = "claude_path"
+	settingClaudeArgs          = "claude_args"
+	settingClaudeEnv           = "claude_env"
+)
+
+// legacySystemPrompt is the instruction that used to be appended to every
+// invocation. It seeds the default system prompt so existing installs keep
+// their behaviour until the user changes it.
+const legacySystemPrompt = "You have access to WebSearch and WebFetch tools. Use them whenever the task requires current or real-time information such as weather, news, prices, or live data. Do not tell the user to check a website themselves - use your tools to fetch the information directly."
+
+// GetSettings returns the global settings. Missing keys yield zero values.
+func (s *Store) GetSettings() (Settings, error) {
+	var st Settings
+	var err error
+	if st.DefaultSystemPrompt, err = s.getSetting(settingDefaultSystemPrompt); err != nil {
+		return st, err
+	}
+	if st.AnthropicAPIKey, err = s.getSetting(settingAnthropicAPIKey); err != nil {
+		return st, err
+	}
+	if st.ClaudePath, err = s.getSetting(settingClaudePath); err != nil {
+		return st, err
+	}
+	if st.ClaudeArgs, err = s.getSetting(settingClaudeArgs); err != nil {
+		return st, err
+	}
+	if st.ClaudeEnv, err = s.getSetting(settingClaudeEnv); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+// UpdateSettings replaces all global settings.
+func (s *Store) UpdateSettings(st Settings) (Settings, error) {
+	if _, err := st.ClaudeArgList(); err != nil {
+		return st, err
+	}
+	if _, err := st.ClaudeEnvMap(); err != nil {
+		return st, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return st, err
+	}
+	defer tx.Rollback()
+
+	values := map[string]string{
+		settingDefaultSystemPrompt: st.DefaultSystemPrompt,
+		settingAnthropicAPIKey:     st.AnthropicAPIKey,
+		settingClaudePath:          st.ClaudePath,
+		settingClaudeArgs:          st.ClaudeArgs,
+		settingClaudeEnv:           st.ClaudeEnv,
+	}
+	for key, value := range values {
+		if _, err := tx.Exec(
+			`INSERT INTO settings (key, value) VALUES (?, ?)
+			 ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+			key, value,
+		); err != nil {
+			return st, err
+		}
+	}
+	return st, tx.Commit()
+}
+
+func (s *Store) getSetting(key string) (string, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
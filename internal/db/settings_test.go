@@ -0,0 +1,77 @@
+package db_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSettingsSeedsDefaultSystemPrompt(t *testing.T) {
+	store := openTestStore(t)
+	settings, err := store.GetSettings()
+	require.NoError(t, err)
+	require.Contains(t, settings.DefaultSystemPrompt, "WebSearch")
+}
+
+func TestUpdateSettingsPersists(t *testing.T) {
+	store := openTestStore(t)
+	_, err := store.UpdateSettings(db.Settings{DefaultSystemPrompt: "Be brief.", AnthropicAPIKey: "sk-test"})
+	require.NoError(t, err)
+
+	settings, err := store.GetSettings()
+	require.NoError(t, err)
+	require.Equal(t, "Be brief.", settings.DefaultSystemPrompt)
+	require.Equal(t, "sk-test", settings.AnthropicAPIKey)
+}
+
+func TestClearedDefaultSystemPromptIsNotReseeded(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := db.Open(dbPath)
+	require.NoError(t, err)
+	_, err = store.UpdateSettings(db.Settings{DefaultSystemPrompt: ""})
+	require.NoError(t, err)
+	store.Close()
+
+	store, err = db.Open(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	settings, err := store.GetSettings()
+	require.NoError(t, err)
+	require.Equal(t, "", settings.DefaultSystemPrompt)
+}
+
+func TestUpdateSettingsPersistsCLISettings(t *testing.T) {
+	store := openTestStore(t)
+	_, err := store.UpdateSettings(db.Settings{
+		ClaudePath: "/usr/local/bin/claude",
+		ClaudeArgs: `["--verbose"]`,
+		ClaudeEnv:  `{"HTTPS_PROXY":"http://proxy:8080"}`,
+	})
+	require.NoError(t, err)
+
+	settings, err := store.GetSettings()
+	require.NoError(t, err)
+	require.Equal(t, "/usr/local/bin/claude", settings.ClaudePath)
+	args, err := settings.ClaudeArgList()
+	require.NoError(t, err)
+	require.Equal(t, []string{"--verbose"}, args)
+	env, err := settings.ClaudeEnvMap()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"HTTPS_PROXY": "http://proxy:8080"}, env)
+}
+
+func TestUpdateSettingsValidatesCLISettings(t *testing.T) {
+	store := openTestStore(t)
+
+	_, err := store.UpdateSettings(db.Settings{ClaudeArgs: `"--verbose"`})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "extra CLI arguments")
+
+	_, err = store.UpdateSettings(db.Settings{ClaudeEnv: `["A=1"]`})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "CLI environment")
+}
@@ -11,7 +11,9 @@ import (
 
 // Store wraps the SQLite database connection.
 type Store struct {
-	db *sql.DB
+	db             *sql.DB
+	artifactsRoot  string       // see SetArtifactsRoot
+	removeWorktree func(JobRun) // see SetWorktreeRemover
 }
 
 // Open creates or opens the SQLite database at the given path.
@@ -138,6 +140,218 @@ func (s *Store) init() error {
 	s.db.Exec("ALTER TABLE jobs ADD COLUMN pending_question TEXT NOT NULL DEFAULT ''")
 	s.db.Exec("ALTER TABLE job_runs ADD COLUMN pending_question TEXT NOT NULL DEFAULT ''")
 
+	// Per-job working directory and additional accessible directories.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN working_dir TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN add_dirs TEXT NOT NULL DEFAULT '[]'")
+
+	// Per-job model selection and the model each run actually used.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN model TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN fallback_model TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN max_turns INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN model TEXT NOT NULL DEFAULT ''")
+
+	// Per-job system prompt.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN system_prompt TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN system_prompt_mode TEXT NOT NULL DEFAULT 'append'")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN skip_default_system_prompt INTEGER NOT NULL DEFAULT 0")
+
+	// Per-run usage reported by the CLI's result event.
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN num_turns INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN input_tokens INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN output_tokens INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN cache_creation_tokens INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN cache_read_tokens INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN cost_usd REAL NOT NULL DEFAULT 0")
+
+	// Per-job session policy and the session each run used.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN session_policy TEXT NOT NULL DEFAULT 'resume'")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN session_rotate_runs INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN session_max_tokens INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN session_id TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN session_runs INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN session_tokens INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN session_id TEXT NOT NULL DEFAULT ''")
+
+	// Forked conversation used for follow-up messages on a run.
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN follow_up_session_id TEXT NOT NULL DEFAULT ''")
+
+	// Prompt templating: per-job time zone and variables, and each run's final
+	// result text for {{.LastSummary}}.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN timezone TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN variables TEXT NOT NULL DEFAULT '{}'")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN summary TEXT NOT NULL DEFAULT ''")
+
+	// Backend that runs each job.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN executor TEXT NOT NULL DEFAULT 'claude'")
+
+	// Per-job environment overrides for the child process.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN env TEXT NOT NULL DEFAULT '{}'")
+
+	// Structured transcript of each run and the renderer version its output
+	// was produced with.
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN events TEXT NOT NULL DEFAULT '[]'")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN render_version INTEGER NOT NULL DEFAULT 0")
+
+	// Gzip-compressed stream-json output of each run, see AppendRawEvents.
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN raw_events BLOB NOT NULL DEFAULT x''")
+
+	// Files each run produced in its artifacts directory.
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS run_artifacts (
+			run_id      TEXT NOT NULL,
+			name        TEXT NOT NULL,
+			size        INTEGER NOT NULL DEFAULT 0,
+			mime_type   TEXT NOT NULL DEFAULT '',
+			sha256      TEXT NOT NULL DEFAULT '',
+			modified_at TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (run_id, name),
+			FOREIGN KEY (run_id) REFERENCES job_runs(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Per-job git worktree isolation and what each run's worktree holds.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN worktree INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN worktree TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN branch TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN base_commit TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN diff TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN commits TEXT NOT NULL DEFAULT '[]'")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN worktree_state TEXT NOT NULL DEFAULT ''")
+
+	// Per-job bubblewrap sandbox.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN sandbox INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN sandbox_network TEXT NOT NULL DEFAULT 'full'")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN sandbox_hosts TEXT NOT NULL DEFAULT '[]'")
+
+	// Per-job resource limits and the reason a run failed.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN cpu_limit INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN memory_limit INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN process_limit INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN failure_reason TEXT NOT NULL DEFAULT ''")
+
+	// Per-job output schema and each run's result parsed against it.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN output_schema TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN schema_retry INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN structured_output TEXT NOT NULL DEFAULT ''")
+
+	// Per-job success criteria and why a run failed them.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN assertions TEXT NOT NULL DEFAULT '[]'")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN assertion_failures TEXT NOT NULL DEFAULT '[]'")
+
+	// Outcomes reported by runs, and which jobs ask for them.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN report_outcome INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN outcome TEXT NOT NULL DEFAULT ''")
+
+	// Per-job comparison with the previous result, and the diff it found.
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN notify_on_change TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE jobs ADD COLUMN change_threshold REAL NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE job_runs ADD COLUMN result_diff TEXT NOT NULL DEFAULT ''")
+
+	// Webhook destinations, the jobs routed to them, and their deliveries.
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id       TEXT PRIMARY KEY,
+			name     TEXT NOT NULL UNIQUE,
+			url      TEXT NOT NULL,
+			format   TEXT NOT NULL DEFAULT 'json',
+			events   TEXT NOT NULL DEFAULT '[]',
+			headers  TEXT NOT NULL DEFAULT '{}',
+			all_jobs INTEGER NOT NULL DEFAULT 0
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS job_webhooks (
+			job_id     TEXT NOT NULL,
+			webhook_id TEXT NOT NULL,
+			PRIMARY KEY (job_id, webhook_id),
+			FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE,
+			FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id          TEXT PRIMARY KEY,
+			webhook_id  TEXT NOT NULL,
+			job_name    TEXT NOT NULL DEFAULT '',
+			run_id      TEXT NOT NULL DEFAULT '',
+			event       TEXT NOT NULL DEFAULT '',
+			state       TEXT NOT NULL DEFAULT 'pending',
+			attempts    INTEGER NOT NULL DEFAULT 0,
+			status_code INTEGER NOT NULL DEFAULT 0,
+			error       TEXT NOT NULL DEFAULT '',
+			created_at  TEXT NOT NULL DEFAULT '',
+			updated_at  TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at)`)
+	if err != nil {
+		return err
+	}
+
+	// Usage of every run, kept when the run itself is pruned or its job is
+	// deleted, so it has no foreign key and keeps the job's name.
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS run_usage (
+			run_id                TEXT PRIMARY KEY,
+			job_id                TEXT NOT NULL,
+			job_name              TEXT NOT NULL DEFAULT '',
+			started_at            TEXT NOT NULL,
+			duration_ms           INTEGER NOT NULL DEFAULT 0,
+			num_turns             INTEGER NOT NULL DEFAULT 0,
+			input_tokens          INTEGER NOT NULL DEFAULT 0,
+			output_tokens         INTEGER NOT NULL DEFAULT 0,
+			cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+			cache_read_tokens     INTEGER NOT NULL DEFAULT 0,
+			cost_usd              REAL NOT NULL DEFAULT 0
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_run_usage_started_at ON run_usage(started_at)`)
+	if err != nil {
+		return err
+	}
+	// Copy the usage of runs recorded before the table existed.
+	_, err = s.db.Exec(`INSERT OR IGNORE INTO run_usage (run_id, job_id, job_name, started_at, ` + usageColumns + `)
+		SELECT r.id, r.job_id, COALESCE(j.name, ''), r.started_at, ` + runUsageColumns + `
+		FROM job_runs r LEFT JOIN jobs j ON j.id = r.job_id`)
+	if err != nil {
+		return err
+	}
+
+	// Global key/value settings.
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS settings (
+			key   TEXT PRIMARY KEY,
+			value TEXT NOT NULL DEFAULT ''
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		"INSERT OR IGNORE INTO settings (key, value) VALUES (?, ?)",
+		settingDefaultSystemPrompt, legacySystemPrompt,
+	)
+	if err != nil {
+		return err
+	}
+
 	// Enable foreign key enforcement (SQLite has it off by default).
 	_, err = s.db.Exec("PRAGMA foreign_keys = ON")
 	return err
@@ -0,0 +1,332 @@
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook is a destination that job events are posted to.
+type Webhook struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Format  string `json:"format"`  // payload shape: "json", "slack" or "discord"
+	Events  string `json:"events"`  // JSON array of the job statuses to send, see WebhookEvents
+	Headers string `json:"headers"` // JSON object string of extra request headers
+	AllJobs bool   `json:"allJobs"` // send events of every job, not only of jobs routed here
+}
+
+var validWebhookFormats = map[string]bool{
+	"json":    true,
+	"slack":   true,
+	"discord": true,
+}
+
+// WebhookEvents are the job statuses a webhook can be sent.
+var WebhookEvents = []string{"running", "success", "failed", "assertion_failed", "waiting"}
+
+// defaultWebhookEvents is what a webhook is sent when it names no events:
+// everything but runs starting.
+const defaultWebhookEvents = `["success","failed","assertion_failed","waiting"]`
+
+// EventList parses Events.
+func (w Webhook) EventList() ([]string, error) {
+	return parseStringList(w.Events, "webhook events")
+}
+
+// HeaderMap parses Headers into a map.
+func (w Webhook) HeaderMap() (map[string]string, error) {
+	return parseStringMap(w.Headers, "webhook headers")
+}
+
+// Wants reports whether the webhook is sent events for jobs reaching status.
+func (w Webhook) Wants(status string) bool {
+	events, _ := w.EventList()
+	for _, e := range events {
+		if e == status {
+			return true
+		}
+	}
+	return false
+}
+
+func validateWebhook(w Webhook) error {
+	if w.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url: %s", w.URL)
+	}
+	if !validWebhookFormats[w.Format] {
+		return fmt.Errorf("invalid webhook format: %s (must be json, slack or discord)", w.Format)
+	}
+	events, err := w.EventList()
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		valid := false
+		for _, known := range WebhookEvents {
+			valid = valid || e == known
+		}
+		if !valid {
+			return fmt.Errorf("invalid webhook event: %s", e)
+		}
+	}
+	if _, err := w.HeaderMap(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func applyWebhookDefaults(w *Webhook) {
+	if w.Format == "" {
+		w.Format = "json"
+	}
+	if w.Events == "" || w.Events == "[]" {
+		w.Events = defaultWebhookEvents
+	}
+	if w.Headers == "" {
+		w.Headers = "{}"
+	}
+}
+
+// webhookColumns lists the webhooks table columns in the order scanWebhook
+// expects.
+const webhookColumns = "id, name, url, format, events, headers, all_jobs"
+
+func scanWebhook(row interface{ Scan(...any) error }) (Webhook, error) {
+	var w Webhook
+	err := row.Scan(&w.ID, &w.Name, &w.URL, &w.Format, &w.Events, &w.Headers, &w.AllJobs)
+	return w, err
+}
+
+// GetWebhooks returns all webhooks ordered by name.
+func (s *Store) GetWebhooks() ([]Webhook, error) {
+	rows, err := s.db.Query("SELECT " + webhookColumns + " FROM webhooks ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhook returns a single webhook by ID.
+func (s *Store) GetWebhook(id string) (Webhook, error) {
+	return scanWebhook(s.db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+}
+
+// CreateWebhook inserts a new webhook. It assigns a UUID if ID is empty.
+func (s *Store) CreateWebhook(w Webhook) (Webhook, error) {
+	applyWebhookDefaults(&w)
+	if err := validateWebhook(w); err != nil {
+		return w, err
+	}
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO webhooks (`+webhookColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		w.ID, w.Name, w.URL, w.Format, w.Events, w.Headers, w.AllJobs,
+	)
+	return w, err
+}
+
+// UpdateWebhook updates an existing webhook.
+func (s *Store) UpdateWebhook(w Webhook) (Webhook, error) {
+	applyWebhookDefaults(&w)
+	if err := validateWebhook(w); err != nil {
+		return w, err
+	}
+	result, err := s.db.Exec(
+		`UPDATE webhooks SET name=?, url=?, format=?, events=?, headers=?, all_jobs=? WHERE id=?`,
+		w.Name, w.URL, w.Format, w.Events, w.Headers, w.AllJobs, w.ID,
+	)
+	if err != nil {
+		return w, err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return w, fmt.Errorf("webhook not found: %s", w.ID)
+	}
+	return w, nil
+}
+
+// DeleteWebhook removes a webhook by ID, with its routes and delivery log.
+func (s *Store) DeleteWebhook(id string) error {
+	result, err := s.db.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("webhook not found: %s", id)
+	}
+	return nil
+}
+
+// GetWebhooksForJob returns the webhooks routed to a job, including those
+// that take every job's events.
+func (s *Store) GetWebhooksForJob(jobID string) ([]Webhook, error) {
+	rows, err := s.db.Query(
+		`SELECT `+webhookColumns+` FROM webhooks
+		 WHERE all_jobs = 1 OR id IN (SELECT webhook_id FROM job_webhooks WHERE job_id = ?)
+		 ORDER BY name`,
+		jobID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetJobWebhookIDs returns the IDs of the webhooks explicitly routed to a
+// job.
+func (s *Store) GetJobWebhookIDs(jobID string) ([]string, error) {
+	rows, err := s.db.Query("SELECT webhook_id FROM job_webhooks WHERE job_id = ?", jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetJobWebhooks replaces the webhooks routed to a job.
+func (s *Store) SetJobWebhooks(jobID string, webhookIDs []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM job_webhooks WHERE job_id = ?", jobID); err != nil {
+		return err
+	}
+	for _, id := range webhookIDs {
+		if _, err := tx.Exec(
+			"INSERT INTO job_webhooks (job_id, webhook_id) VALUES (?, ?)",
+			jobID, id,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// WebhookDelivery records sending one event to a webhook.
+type WebhookDelivery struct {
+	ID         string `json:"id"`
+	WebhookID  string `json:"webhookId"`
+	JobName    string `json:"jobName"`
+	RunID      string `json:"runId"`
+	Event      string `json:"event"`      // job status that was sent
+	State      string `json:"state"`      // one of the Delivery constants
+	Attempts   int    `json:"attempts"`   // requests made so far
+	StatusCode int    `json:"statusCode"` // HTTP status of the last attempt, 0 if it got no response
+	Error      string `json:"error"`      // why the last attempt failed
+	CreatedAt  string `json:"createdAt"`
+	UpdatedAt  string `json:"updatedAt"`
+}
+
+// States of a webhook delivery.
+const (
+	DeliveryPending   = "pending"   // being sent or waiting to be retried
+	DeliveryDelivered = "delivered" // the destination accepted it
+	DeliveryFailed    = "failed"    // every attempt failed
+)
+
+// maxDeliveries is how many deliveries are kept per webhook.
+const maxDeliveries = 100
+
+// CreateWebhookDelivery records a new delivery, and drops the oldest ones of
+// its webhook beyond maxDeliveries.
+func (s *Store) CreateWebhookDelivery(d WebhookDelivery) (WebhookDelivery, error) {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	if d.State == "" {
+		d.State = DeliveryPending
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	d.CreatedAt, d.UpdatedAt = now, now
+	_, err := s.db.Exec(
+		`INSERT INTO webhook_deliveries (id, webhook_id, job_name, run_id, event, state, attempts, status_code, error, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.ID, d.WebhookID, d.JobName, d.RunID, d.Event, d.State, d.Attempts, d.StatusCode, d.Error, d.CreatedAt, d.UpdatedAt,
+	)
+	if err != nil {
+		return d, err
+	}
+	_, err = s.db.Exec(
+		`DELETE FROM webhook_deliveries WHERE webhook_id = ? AND id NOT IN (
+			SELECT id FROM webhook_deliveries WHERE webhook_id = ? ORDER BY created_at DESC LIMIT ?)`,
+		d.WebhookID, d.WebhookID, maxDeliveries,
+	)
+	return d, err
+}
+
+// UpdateWebhookDelivery records the progress of a delivery.
+func (s *Store) UpdateWebhookDelivery(d WebhookDelivery) error {
+	d.UpdatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	_, err := s.db.Exec(
+		`UPDATE webhook_deliveries SET state=?, attempts=?, status_code=?, error=?, updated_at=? WHERE id=?`,
+		d.State, d.Attempts, d.StatusCode, d.Error, d.UpdatedAt, d.ID,
+	)
+	return err
+}
+
+// GetWebhookDeliveries returns a webhook's deliveries, newest first.
+func (s *Store) GetWebhookDeliveries(webhookID string) ([]WebhookDelivery, error) {
+	rows, err := s.db.Query(
+		`SELECT id, webhook_id, job_name, run_id, event, state, attempts, status_code, error, created_at, updated_at
+		 FROM webhook_deliveries WHERE webhook_id = ? ORDER BY created_at DESC`,
+		webhookID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.JobName, &d.RunID, &d.Event, &d.State,
+			&d.Attempts, &d.StatusCode, &d.Error, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
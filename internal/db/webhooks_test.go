@@ -0,0 +1,102 @@
+package db_test
+
+import (
+	"testing"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWebhookValidates(t *testing.T) {
+	store := openTestStore(t)
+
+	tests := []struct {
+		name    string
+		webhook db.Webhook
+		err     string
+	}{
+		{"no name", db.Webhook{URL: "https://example.com/hook"}, "name is required"},
+		{"no url", db.Webhook{Name: "a"}, "invalid webhook url"},
+		{"not http", db.Webhook{Name: "a", URL: "file:///etc/passwd"}, "invalid webhook url"},
+		{"unknown format", db.Webhook{Name: "a", URL: "https://example.com", Format: "teams"}, "invalid webhook format"},
+		{"unknown event", db.Webhook{Name: "a", URL: "https://example.com", Events: `["done"]`}, "invalid webhook event: done"},
+		{"bad headers", db.Webhook{Name: "a", URL: "https://example.com", Headers: `["x"]`}, "invalid webhook headers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.CreateWebhook(tt.webhook)
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	created, err := store.CreateWebhook(db.Webhook{Name: "ops", URL: "https://hooks.slack.com/services/x", Format: "slack"})
+	require.NoError(t, err)
+	got, err := store.GetWebhook(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, created, got)
+	require.True(t, got.Wants("failed"))
+	require.False(t, got.Wants("running"), "runs starting are not sent by default")
+}
+
+func TestWebhookRouting(t *testing.T) {
+	store := openTestStore(t)
+	routed, err := store.CreateJob(validJob("Routed"))
+	require.NoError(t, err)
+	other, err := store.CreateJob(validJob("Other"))
+	require.NoError(t, err)
+
+	team, err := store.CreateWebhook(db.Webhook{Name: "team", URL: "https://example.com/team"})
+	require.NoError(t, err)
+	_, err = store.CreateWebhook(db.Webhook{Name: "all", URL: "https://example.com/all", AllJobs: true})
+	require.NoError(t, err)
+	require.NoError(t, store.SetJobWebhooks(routed.ID, []string{team.ID}))
+
+	names := func(jobID string) []string {
+		webhooks, err := store.GetWebhooksForJob(jobID)
+		require.NoError(t, err)
+		var out []string
+		for _, w := range webhooks {
+			out = append(out, w.Name)
+		}
+		return out
+	}
+	require.Equal(t, []string{"all", "team"}, names(routed.ID))
+	require.Equal(t, []string{"all"}, names(other.ID))
+	ids, err := store.GetJobWebhookIDs(routed.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{team.ID}, ids)
+
+	require.NoError(t, store.DeleteWebhook(team.ID))
+	require.Equal(t, []string{"all"}, names(routed.ID))
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	store := openTestStore(t)
+	w, err := store.CreateWebhook(db.Webhook{Name: "log", URL: "https://example.com/log"})
+	require.NoError(t, err)
+
+	d, err := store.CreateWebhookDelivery(db.WebhookDelivery{WebhookID: w.ID, JobName: "Nightly", RunID: "run-1", Event: "failed"})
+	require.NoError(t, err)
+	require.Equal(t, db.DeliveryPending, d.State)
+	d.State = db.DeliveryDelivered
+	d.Attempts = 2
+	d.StatusCode = 200
+	require.NoError(t, store.UpdateWebhookDelivery(d))
+
+	for i := 0; i < 105; i++ {
+		_, err := store.CreateWebhookDelivery(db.WebhookDelivery{WebhookID: w.ID, Event: "success"})
+		require.NoError(t, err)
+	}
+	got, err := store.GetWebhookDeliveries(w.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 100, "old deliveries are pruned")
+	for _, g := range got {
+		require.NotEqual(t, d.ID, g.ID)
+	}
+
+	require.NoError(t, store.DeleteWebhook(w.ID))
+	got, err = store.GetWebhookDeliveries(w.ID)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
@@ -0,0 +1,216 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/transcript"
+)
+
+const (
+	defaultAPIBaseURL = "https://api.anthropic.com"
+	apiVersion        = "2023-06-01"
+	defaultAPIModel   = "claude-sonnet-4-5"
+	apiMaxTokens      = 8192
+
+	// statusOverloaded is the API's status code for a temporarily
+	// overloaded model.
+	statusOverloaded = 529
+)
+
+// APIExecutor runs a job's prompt as a single request to the Anthropic
+// Messages API. It has no tools, MCP servers or conversations; the API key
+// comes from the settings or the ANTHROPIC_API_KEY environment variable.
+type APIExecutor struct {
+	BaseURL string       // defaults to the public API
+	Client  *http.Client // defaults to http.DefaultClient
+}
+
+type apiRequest struct {
+	Model     string       `json:"model"`
+	MaxTokens int          `json:"max_tokens"`
+	System    string       `json:"system,omitempty"`
+	Messages  []apiMessage `json:"messages"`
+}
+
+type apiMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type apiResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage *cliUsage `json:"usage"`
+}
+
+type apiErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// apiStatusError is a non-200 response from the API.
+type apiStatusError struct {
+	status  int
+	message string
+}
+
+func (e *apiStatusError) Error() string {
+	return fmt.Sprintf("anthropic api: %d: %s", e.status, e.message)
+}
+
+func (e *APIExecutor) Execute(ctx context.Context, job db.Job, _ []db.MCPServer, opts Options) (ExecuteResult, error) {
+	apiKey := currentSettings().AnthropicAPIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+	if apiKey == "" {
+		return ExecuteResult{}, fmt.Errorf("no Anthropic API key: set one in Settings or ANTHROPIC_API_KEY")
+	}
+
+	model := job.Model
+	if model == "" {
+		model = defaultAPIModel
+	}
+	req := apiRequest{
+		Model:     model,
+		MaxTokens: apiMaxTokens,
+		System:    apiSystemPrompt(job),
+		Messages:  []apiMessage{{Role: "user", Content: job.Prompt}},
+	}
+
+	start := time.Now()
+	resp, err := e.send(ctx, apiKey, req)
+	var statusErr *apiStatusError
+	if err != nil && job.FallbackModel != "" && errors.As(err, &statusErr) && statusErr.status == statusOverloaded {
+		req.Model = job.FallbackModel
+		resp, err = e.send(ctx, apiKey, req)
+	}
+	if err != nil {
+		return ExecuteResult{}, err
+	}
+
+	var texts []string
+	for _, block := range resp.Content {
+		if block.Type == "text" && block.Text != "" {
+			texts = append(texts, block.Text)
+		}
+	}
+	text := strings.Join(texts, "\n\n")
+	if text == "" {
+		return ExecuteResult{}, fmt.Errorf("anthropic api: empty response")
+	}
+	// The reply is markdown from the model, so it is rendered like any other
+	// transcript text.
+	events := []transcript.Event{{Kind: transcript.KindText, Time: time.Now().UTC(), Text: text}}
+	html := transcript.Render(events)
+	if opts.OnProgress != nil {
+		opts.OnProgress(html)
+	}
+
+	result := ExecuteResult{
+		Transcript: html,
+		Events:     events,
+		Model:      resp.Model,
+		Summary:    text,
+		Usage: db.Usage{
+			DurationMs: time.Since(start).Milliseconds(),
+			NumTurns:   1,
+		},
+	}
+	if resp.Usage != nil {
+		result.Usage.InputTokens = resp.Usage.InputTokens
+		result.Usage.OutputTokens = resp.Usage.OutputTokens
+		result.Usage.CacheCreationTokens = resp.Usage.CacheCreationInputTokens
+		result.Usage.CacheReadTokens = resp.Usage.CacheReadInputTokens
+	}
+	return result, nil
+}
+
+func (e *APIExecutor) Answer(context.Context, db.Job, []db.MCPServer, string, Options) (ExecuteResult, error) {
+	return ExecuteResult{}, errNoSessions(BackendAPI)
+}
+
+func (e *APIExecutor) FollowUp(context.Context, db.Job, []db.MCPServer, string, bool, Options) (ExecuteResult, error) {
+	return ExecuteResult{}, errNoSessions(BackendAPI)
+}
+
+func (e *APIExecutor) Sessions() bool { return false }
+
+// send posts a Messages API request and decodes the response.
+func (e *APIExecutor) send(ctx context.Context, apiKey string, req apiRequest) (apiResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return apiResponse{}, err
+	}
+
+	baseURL := e.BaseURL
+	if baseURL == "" {
+		baseURL = defaultAPIBaseURL
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return apiResponse{}, err
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("x-api-key", apiKey)
+	httpReq.Header.Set("anthropic-version", apiVersion)
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return apiResponse{}, fmt.Errorf("anthropic api: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return apiResponse{}, fmt.Errorf("anthropic api: reading response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		var apiErr apiErrorResponse
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			msg = apiErr.Error.Message
+		}
+		return apiResponse{}, &apiStatusError{status: httpResp.StatusCode, message: msg}
+	}
+
+	var resp apiResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return apiResponse{}, fmt.Errorf("anthropic api: decoding response: %w", err)
+	}
+	return resp, nil
+}
+
+// apiSystemPrompt returns the job's system prompt. The API has no built-in
+// system prompt to keep, so "append" and "replace" behave the same. The
+// default system prompt from Settings is written for the CLI and its tools,
+// which the API has none of, so it is left out.
+func apiSystemPrompt(job db.Job) string {
+	var parts []string
+	if p := strings.TrimSpace(job.SystemPrompt); p != "" {
+		parts = append(parts, p)
+	}
+	if job.ReportOutcome {
+		parts = append(parts, OutcomePrompt)
+	}
+	return strings.Join(parts, "\n\n")
+}
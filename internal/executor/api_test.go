@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/transcript"
+
+	"github.com/stretchr/testify/require"
+)
+
+// withSettings replaces the global settings for the duration of a test.
+func withSettings(t *testing.T, st db.Settings) {
+	t.Helper()
+	prev := currentSettings()
+	SetSettings(st)
+	t.Cleanup(func() { SetSettings(prev) })
+}
+
+func TestAPIExecutor_Execute(t *testing.T) {
+	withSettings(t, db.Settings{DefaultSystemPrompt: "Be brief.", AnthropicAPIKey: "sk-test"})
+
+	var got apiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/messages", r.URL.Path)
+		require.Equal(t, "sk-test", r.Header.Get("x-api-key"))
+		require.Equal(t, apiVersion, r.Header.Get("anthropic-version"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte(`{"model":"claude-haiku-4-5","content":[{"type":"text","text":"All quiet."}],` +
+			`"usage":{"input_tokens":20,"output_tokens":5,"cache_read_input_tokens":7}}`))
+	}))
+	defer srv.Close()
+
+	var progress []string
+	e := &APIExecutor{BaseURL: srv.URL}
+	job := db.Job{Prompt: "Anything new?", Model: "claude-haiku-4-5", SystemPrompt: "Use bullet points."}
+	result, err := e.Execute(context.Background(), job, nil, Options{
+		OnProgress: func(f string) { progress = append(progress, f) },
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "claude-haiku-4-5", got.Model)
+	require.Equal(t, "Use bullet points.", got.System, "the default system prompt is for the CLI")
+	require.Equal(t, []apiMessage{{Role: "user", Content: "Anything new?"}}, got.Messages)
+
+	require.Equal(t, transcript.Render(result.Events), result.Transcript)
+	require.Contains(t, result.Transcript, "<p>All quiet.</p>")
+	require.Equal(t, "All quiet.", result.Summary)
+	require.Equal(t, "claude-haiku-4-5", result.Model)
+	require.Equal(t, int64(20), result.Usage.InputTokens)
+	require.Equal(t, int64(5), result.Usage.OutputTokens)
+	require.Equal(t, int64(7), result.Usage.CacheReadTokens)
+	require.Equal(t, int64(1), result.Usage.NumTurns)
+	require.Equal(t, []string{result.Transcript}, progress)
+}
+
+func TestAPIExecutor_SanitizesReply(t *testing.T) {
+	withSettings(t, db.Settings{AnthropicAPIKey: "sk-test"})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content":[{"type":"text","text":"Done <img src=x onerror=alert(1)>"}]}`))
+	}))
+	defer srv.Close()
+
+	result, err := (&APIExecutor{BaseURL: srv.URL}).Execute(context.Background(), db.Job{Prompt: "x"}, nil, Options{})
+	require.NoError(t, err)
+	require.NotContains(t, result.Transcript, "<img")
+	require.Contains(t, result.Transcript, "Done")
+}
+
+func TestAPIExecutor_KeepsReplyInOneHTMLBlock(t *testing.T) {
+	withSettings(t, db.Settings{AnthropicAPIKey: "sk-test"})
+	// A blank line in the quoted code would end an HTML block spread over
+	// several lines, and the link after it would be parsed as markdown.
+	reply, err := json.Marshal("> ```\n> a\n>\n> b\n> ```\n\n[x](javascript:alert(1))")
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content":[{"type":"text","text":` + string(reply) + `}]}`))
+	}))
+	defer srv.Close()
+
+	result, err := (&APIExecutor{BaseURL: srv.URL}).Execute(context.Background(), db.Job{Prompt: "x"}, nil, Options{})
+	require.NoError(t, err)
+	require.NotContains(t, result.Transcript, "\n")
+	require.NotContains(t, result.Transcript, "javascript:")
+}
+
+func TestAPIExecutor_FallsBackWhenOverloaded(t *testing.T) {
+	withSettings(t, db.Settings{AnthropicAPIKey: "sk-test"})
+
+	var models []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req apiRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		models = append(models, req.Model)
+		if req.Model == "big" {
+			w.WriteHeader(statusOverloaded)
+			w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		w.Write([]byte(`{"model":"small","content":[{"type":"text","text":"ok"}]}`))
+	}))
+	defer srv.Close()
+
+	e := &APIExecutor{BaseURL: srv.URL}
+	result, err := e.Execute(context.Background(), db.Job{Prompt: "hi", Model: "big", FallbackModel: "small"}, nil, Options{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"big", "small"}, models)
+	require.Equal(t, "small", result.Model)
+}
+
+func TestAPIExecutor_ReportsAPIError(t *testing.T) {
+	withSettings(t, db.Settings{AnthropicAPIKey: "sk-bad"})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+	}))
+	defer srv.Close()
+
+	e := &APIExecutor{BaseURL: srv.URL}
+	_, err := e.Execute(context.Background(), db.Job{Prompt: "hi"}, nil, Options{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "401")
+	require.Contains(t, err.Error(), "invalid x-api-key")
+}
+
+func TestAPIExecutor_RequiresKey(t *testing.T) {
+	withSettings(t, db.Settings{})
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	e := &APIExecutor{BaseURL: "http://127.0.0.1:0"}
+	_, err := e.Execute(context.Background(), db.Job{Prompt: "hi"}, nil, Options{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "API key")
+}
@@ -6,26 +6,42 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"claude-schedule/internal/db"
+	"claude-schedule/internal/structured"
+	"claude-schedule/internal/transcript"
 )
 
-// DebugDir, when non-empty, causes each CLI invocation's raw JSONL output
-// to be written to a timestamped file in that directory.
-var DebugDir string
-
 // baseArgs are the flags shared by every invocation.
 var baseArgs = []string{
 	"--output-format", "stream-json",
 	"--verbose",
 	"--dangerously-skip-permissions",
-	"--append-system-prompt", "You have access to WebSearch and WebFetch tools. Use them whenever the task requires current or real-time information such as weather, news, prices, or live data. Do not tell the user to check a website themselves - use your tools to fetch the information directly.",
+}
+
+var (
+	settingsMu sync.RWMutex
+	settings   db.Settings
+)
+
+// SetSettings replaces the global settings applied to every invocation. It is
+// called at startup and whenever the user saves the settings.
+func SetSettings(st db.Settings) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	settings = st
+}
+
+func currentSettings() db.Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return settings
 }
 
 // defaultTools are the built-in tools always allowed.
@@ -33,60 +49,192 @@ var defaultTools = "Bash,Read,Write,Edit,WebFetch,WebSearch"
 
 // ExecuteResult holds the output and raw JSONL lines from a CLI invocation.
 type ExecuteResult struct {
-	Transcript string
+	Transcript string             // Events rendered, or plain output for backends without events
+	Events     []transcript.Event // structured transcript, if the backend produces one
 	RawLines   []string
+	Model      string   // model reported by the system init event
+	Usage      db.Usage // usage reported by the result event
+	SessionID  string   // session reported by the system init event
+	Summary    string   // final result text reported by the result event
+
+	// StructuredOutput is the final result as compact JSON, for jobs with an
+	// output schema.
+	StructuredOutput string
+
+	// ContextTokens is the context size of the final turn, used to decide when
+	// a long-running session should be rotated.
+	ContextTokens int64
+}
+
+// Options carries per-run state from the scheduler into an executor.
+type Options struct {
+	// OnProgress, when set, receives each new transcript fragment as the CLI
+	// streams events, so callers can show a live transcript.
+	OnProgress func(fragment string)
+	// NoTools runs the model without any tools, for invocations that only
+	// read untrusted text, such as a judge of a run's result. The API backend
+	// never has tools.
+	NoTools bool
 }
 
 // ClaudeExecute runs a job's prompt through the Claude Code CLI and returns the
-// response text. It tries to resume the job's previous session for continuity;
-// if no session exists yet it falls back to a fresh session.
-func ClaudeExecute(ctx context.Context, job db.Job, mcpServers []db.MCPServer) (ExecuteResult, error) {
-	mcpArgs, cleanup, err := buildMCPArgs(mcpServers)
+// response text. The job's SessionID selects the conversation: a session with
+// no completed runs is created under that ID, otherwise it is resumed. If a
+// resumed session no longer exists the run falls back to a fresh session with
+// the same ID.
+func ClaudeExecute(ctx context.Context, job db.Job, mcpServers []db.MCPServer, opts Options) (ExecuteResult, error) {
+	allBase, cleanup, err := jobArgs(job, mcpServers)
 	if err != nil {
-		return ExecuteResult{}, fmt.Errorf("building MCP config: %w", err)
+		return ExecuteResult{}, err
 	}
 	defer cleanup()
-
-	// Build allowed tools list.
-	tools := defaultTools
-	for _, srv := range mcpServers {
-		tools += ",mcp__" + srv.Name + "__*"
+	if opts.NoTools {
+		allBase = append(allBase, "--tools", "")
 	}
 
-	allBase := append([]string{}, baseArgs...)
-	allBase = append(allBase, "--allowedTools", tools)
-	allBase = append(allBase, mcpArgs...)
+	if job.SessionID == "" || job.SessionRuns == 0 {
+		args := append([]string{"-p", job.Prompt}, sessionArgs(job.SessionID, false)...)
+		result, err := runClaude(ctx, job, append(args, allBase...), opts.OnProgress)
+		if err != nil {
+			return result, err
+		}
+		return checkStructured(ctx, job, allBase, result, opts)
+	}
 
 	// Try resuming the previous session first.
-	args := append([]string{"-p", job.Prompt, "--resume", job.ID}, allBase...)
-	result, err := runClaude(ctx, args)
+	args := append([]string{"-p", job.Prompt}, sessionArgs(job.SessionID, true)...)
+	result, err := runClaude(ctx, job, append(args, allBase...), opts.OnProgress)
 	if err != nil && strings.Contains(err.Error(), "No conversation found") {
-		// First run for this job — start a fresh session.
-		args = append([]string{"-p", job.Prompt}, allBase...)
-		result, err = runClaude(ctx, args)
+		// The session was removed from the CLI's history — start it afresh.
+		args = append([]string{"-p", job.Prompt}, sessionArgs(job.SessionID, false)...)
+		result, err = runClaude(ctx, job, append(args, allBase...), opts.OnProgress)
+	}
+	if err != nil {
+		return result, err
 	}
-	return result, err
+	return checkStructured(ctx, job, allBase, result, opts)
 }
 
 // ClaudeAnswer resumes a conversation with the user's answer to a question.
-func ClaudeAnswer(ctx context.Context, job db.Job, mcpServers []db.MCPServer, answer string) (ExecuteResult, error) {
-	mcpArgs, cleanup, err := buildMCPArgs(mcpServers)
+func ClaudeAnswer(ctx context.Context, job db.Job, mcpServers []db.MCPServer, answer string, opts Options) (ExecuteResult, error) {
+	allBase, cleanup, err := jobArgs(job, mcpServers)
+	if err != nil {
+		return ExecuteResult{}, err
+	}
+	defer cleanup()
+
+	args := append([]string{"-p", answer}, sessionArgs(job.SessionID, true)...)
+	result, err := runClaude(ctx, job, append(args, allBase...), opts.OnProgress)
 	if err != nil {
-		return ExecuteResult{}, fmt.Errorf("building MCP config: %w", err)
+		return result, err
+	}
+	return checkStructured(ctx, job, allBase, result, opts)
+}
+
+// ClaudeFollowUp sends a follow-up message to the conversation in
+// job.SessionID. With fork set the conversation is forked into a new session
+// (reported in the result's SessionID), leaving the original untouched.
+func ClaudeFollowUp(ctx context.Context, job db.Job, mcpServers []db.MCPServer, message string, fork bool, opts Options) (ExecuteResult, error) {
+	allBase, cleanup, err := jobArgs(job, mcpServers)
+	if err != nil {
+		return ExecuteResult{}, err
 	}
 	defer cleanup()
 
+	args := append([]string{"-p", message}, sessionArgs(job.SessionID, true)...)
+	if fork {
+		args = append(args, "--fork-session")
+	}
+	return runClaude(ctx, job, append(args, allBase...), opts.OnProgress)
+}
+
+// sessionArgs returns the flags that select a conversation: --resume for an
+// existing session, --session-id to create one with a known ID. An empty ID
+// lets the CLI pick one.
+func sessionArgs(sessionID string, resume bool) []string {
+	switch {
+	case sessionID == "":
+		return nil
+	case resume:
+		return []string{"--resume", sessionID}
+	default:
+		return []string{"--session-id", sessionID}
+	}
+}
+
+// jobArgs builds the flags shared by every invocation for a job: the base
+// flags, allowed tools, MCP config, additional directories, model and system
+// prompt settings, and finally the extra arguments from the settings. The
+// returned cleanup function removes any temp files created along the way.
+func jobArgs(job db.Job, mcpServers []db.MCPServer) ([]string, func(), error) {
+	addDirs, err := job.AddDirList()
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	mcpArgs, cleanup, err := buildMCPArgs(mcpServers)
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("building MCP config: %w", err)
+	}
+
+	// Build allowed tools list.
 	tools := defaultTools
 	for _, srv := range mcpServers {
 		tools += ",mcp__" + srv.Name + "__*"
 	}
 
-	allBase := append([]string{}, baseArgs...)
-	allBase = append(allBase, "--allowedTools", tools)
-	allBase = append(allBase, mcpArgs...)
+	args := append([]string{}, baseArgs...)
+	args = append(args, "--allowedTools", tools)
+	args = append(args, mcpArgs...)
+	for _, dir := range addDirs {
+		args = append(args, "--add-dir", dir)
+	}
+	if job.Model != "" {
+		args = append(args, "--model", job.Model)
+	}
+	if job.FallbackModel != "" {
+		args = append(args, "--fallback-model", job.FallbackModel)
+	}
+	if job.MaxTurns > 0 {
+		args = append(args, "--max-turns", strconv.Itoa(job.MaxTurns))
+	}
+	args = append(args, systemPromptArgs(job, currentSettings().DefaultSystemPrompt)...)
+
+	extraArgs, err := currentSettings().ClaudeArgList()
+	if err != nil {
+		return nil, cleanup, err
+	}
+	args = append(args, extraArgs...)
+	return args, cleanup, nil
+}
+
+// systemPromptArgs returns the system prompt flags for a job. The global
+// default prompt is appended unless the job opts out; in "replace" mode the
+// job's prompt substitutes Claude's built-in system prompt entirely.
+func systemPromptArgs(job db.Job, defaultPrompt string) []string {
+	var args []string
+	var appended []string
+	if !job.SkipDefaultSystemPrompt && strings.TrimSpace(defaultPrompt) != "" {
+		appended = append(appended, strings.TrimSpace(defaultPrompt))
+	}
 
-	args := append([]string{"-p", answer, "--resume", job.ID}, allBase...)
-	return runClaude(ctx, args)
+	jobPrompt := strings.TrimSpace(job.SystemPrompt)
+	if strings.TrimSpace(job.OutputSchema) != "" {
+		jobPrompt = strings.TrimSpace(jobPrompt + "\n\n" + structured.Prompt(job.OutputSchema))
+	}
+	if job.ReportOutcome {
+		jobPrompt = strings.TrimSpace(jobPrompt + "\n\n" + OutcomePrompt)
+	}
+	if job.SystemPromptMode == "replace" && jobPrompt != "" {
+		args = append(args, "--system-prompt", jobPrompt)
+	} else if jobPrompt != "" {
+		appended = append(appended, jobPrompt)
+	}
+
+	if len(appended) > 0 {
+		args = append(args, "--append-system-prompt", strings.Join(appended, "\n\n"))
+	}
+	return args
 }
 
 // DetectQuestion scans raw JSONL lines for the last AskUserQuestion tool call
@@ -126,211 +274,260 @@ func DetectQuestion(lines []string) string {
 type cliEvent struct {
 	Type    string          `json:"type"`
 	Subtype string          `json:"subtype,omitempty"`
-	Message *cliMessage     `json:"message,omitempty"`  // present when Type == "assistant"
+	Message *cliMessage     `json:"message,omitempty"`  // present when Type == "assistant" or "user"
 	Content json.RawMessage `json:"content,omitempty"`  // present for tool result events
 	Result  string          `json:"result,omitempty"`   // present when Type == "result"
 	IsError bool            `json:"is_error,omitempty"` // true when Type == "result" and the run failed
+	Model   string          `json:"model,omitempty"`    // present when Type == "system" and Subtype == "init"
+
+	SessionID string `json:"session_id,omitempty"` // present on every event
+
+	// Usage fields, present when Type == "result".
+	DurationMs   int64     `json:"duration_ms,omitempty"`
+	NumTurns     int64     `json:"num_turns,omitempty"`
+	TotalCostUSD float64   `json:"total_cost_usd,omitempty"`
+	Usage        *cliUsage `json:"usage,omitempty"`
+}
+
+// cliUsage mirrors the token counts reported in the result event.
+type cliUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
 }
 
 // cliMessage mirrors the Anthropic API Message structure embedded in
-// assistant-type events.
+// assistant-type events, and the tool results sent back in user-type events.
 type cliMessage struct {
 	Role    string            `json:"role"`
 	Content []cliContentBlock `json:"content"`
+	Usage   *cliUsage         `json:"usage,omitempty"`
 }
 
-// cliContentBlock represents one block inside an assistant message.
+// cliContentBlock represents one block inside a message.
 type cliContentBlock struct {
-	Type  string          `json:"type"`            // "text", "tool_use", "tool_result"
-	Text  string          `json:"text,omitempty"`  // for type == "text"
-	Name  string          `json:"name,omitempty"`  // for type == "tool_use"
-	ID    string          `json:"id,omitempty"`    // for type == "tool_use"
-	Input json.RawMessage `json:"input,omitempty"` // for type == "tool_use"
+	Type      string          `json:"type"`                  // "text", "tool_use", "tool_result"
+	Text      string          `json:"text,omitempty"`        // for type == "text"
+	Name      string          `json:"name,omitempty"`        // for type == "tool_use"
+	ID        string          `json:"id,omitempty"`          // for type == "tool_use"
+	Input     json.RawMessage `json:"input,omitempty"`       // for type == "tool_use"
+	ToolUseID string          `json:"tool_use_id,omitempty"` // for type == "tool_result"
+	Content   json.RawMessage `json:"content,omitempty"`     // for type == "tool_result": a string or a list of blocks
+	IsError   bool            `json:"is_error,omitempty"`    // for type == "tool_result"
+}
+
+// resultText returns the output of a tool_result block. Images in the output
+// are noted rather than shown.
+func (b cliContentBlock) resultText() string {
+	if len(b.Content) == 0 {
+		return b.Text
+	}
+	var s string
+	if err := json.Unmarshal(b.Content, &s); err == nil {
+		return s
+	}
+	var blocks []cliContentBlock
+	if err := json.Unmarshal(b.Content, &blocks); err != nil {
+		return string(b.Content)
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			parts = append(parts, block.Text)
+		case "image":
+			parts = append(parts, "[image]")
+		}
+	}
+	return strings.Join(parts, "\n")
 }
 
 // ---------------------------------------------------------------------------
 // Transcript builder
 // ---------------------------------------------------------------------------
 
-// transcriptBuilder accumulates CLI events into a markdown transcript.
+// transcriptBuilder accumulates CLI events into transcript events.
 type transcriptBuilder struct {
-	buf        strings.Builder
-	hasContent bool
-}
-
-func (tb *transcriptBuilder) writeText(text string) {
-	text = strings.TrimSpace(text)
-	if text == "" {
-		return
-	}
-	tb.buf.WriteString(`<div style="margin:8px 0;padding:8px 12px;border-left:3px solid #22d3ee;background:#0f172a;border-radius:4px;color:#e2e8f0;font-size:13px;line-height:1.5">`)
-	tb.buf.WriteString(text)
-	tb.buf.WriteString("</div>\n\n")
-	tb.hasContent = true
+	events     []transcript.Event
+	lastResult string
+	tools      map[string]string // tool name by tool_use ID
 }
 
 // questionInput matches the AskUserQuestion tool input shape.
 type questionInput struct {
-	Questions []questionItem `json:"questions"`
+	Questions []transcript.Question `json:"questions"`
 }
 
-type questionItem struct {
-	Question string           `json:"question"`
-	Header   string           `json:"header"`
-	Options  []questionOption `json:"options"`
+func (tb *transcriptBuilder) add(e transcript.Event) {
+	e.Time = time.Now().UTC()
+	tb.events = append(tb.events, e)
 }
 
-type questionOption struct {
-	Label       string `json:"label"`
-	Description string `json:"description"`
-}
-
-func (tb *transcriptBuilder) writeToolUse(name string, rawInput json.RawMessage) {
-	// Render AskUserQuestion tool calls as a styled question card.
-	if name == "AskUserQuestion" && len(rawInput) > 0 {
-		var qi questionInput
-		if err := json.Unmarshal(rawInput, &qi); err == nil && len(qi.Questions) > 0 {
-			tb.writeQuestion(qi)
-			return
-		}
+// handleAssistant processes an assistant-type event, extracting text and
+// tool-use blocks from the message content.
+func (tb *transcriptBuilder) handleAssistant(msg *cliMessage) {
+	if msg == nil {
+		return
 	}
-
-	tb.buf.WriteString(`<details style="margin:8px 0;border:1px solid #374151;border-radius:6px;overflow:hidden">`)
-	tb.buf.WriteString(`<summary style="cursor:pointer;padding:6px 10px;background:#1e293b;color:#60a5fa;font-size:13px;font-weight:600">`)
-	tb.buf.WriteString(`Tool: ` + name)
-	tb.buf.WriteString(`</summary>`)
-
-	if len(rawInput) > 0 {
-		input := string(rawInput)
-		var parsed interface{}
-		if err := json.Unmarshal(rawInput, &parsed); err == nil {
-			if pretty, err := json.MarshalIndent(parsed, "", "  "); err == nil {
-				input = string(pretty)
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			if text := strings.TrimSpace(block.Text); text != "" {
+				tb.add(transcript.Event{Kind: transcript.KindText, Text: text})
 			}
-		}
-		tb.buf.WriteString(`<pre style="margin:0;padding:8px 10px;background:#0f172a;color:#94a3b8;font-size:12px;overflow-x:auto">`)
-		tb.buf.WriteString(input)
-		tb.buf.WriteString(`</pre>`)
-	}
-
-	tb.buf.WriteString("</details>\n\n")
-	tb.hasContent = true
-}
-
-func (tb *transcriptBuilder) writeQuestion(qi questionInput) {
-	for _, q := range qi.Questions {
-		tb.buf.WriteString(`<div style="margin:8px 0;padding:12px 16px;border:1px solid #f59e0b;border-radius:6px;background:#1c1917;color:#e2e8f0;font-size:13px;line-height:1.5">`)
-		tb.buf.WriteString(`<div style="color:#f59e0b;font-weight:700;font-size:11px;text-transform:uppercase;letter-spacing:0.05em;margin-bottom:6px">`)
-		if q.Header != "" {
-			tb.buf.WriteString(q.Header)
-		} else {
-			tb.buf.WriteString("Question")
-		}
-		tb.buf.WriteString(`</div>`)
-		tb.buf.WriteString(`<div style="margin-bottom:10px;font-size:14px">` + q.Question + `</div>`)
-		if len(q.Options) > 0 {
-			for _, opt := range q.Options {
-				tb.buf.WriteString(`<div style="margin:4px 0;padding:6px 10px;border:1px solid #374151;border-radius:4px;background:#0f172a">`)
-				tb.buf.WriteString(`<span style="color:#fbbf24;font-weight:600">` + opt.Label + `</span>`)
-				if opt.Description != "" {
-					tb.buf.WriteString(` <span style="color:#94a3b8;font-size:12px">— ` + opt.Description + `</span>`)
+		case "tool_use":
+			if tb.tools == nil {
+				tb.tools = make(map[string]string)
+			}
+			tb.tools[block.ID] = block.Name
+			// AskUserQuestion calls are recorded as questions.
+			if block.Name == "AskUserQuestion" && len(block.Input) > 0 {
+				var qi questionInput
+				if err := json.Unmarshal(block.Input, &qi); err == nil && len(qi.Questions) > 0 {
+					tb.add(transcript.Event{Kind: transcript.KindQuestion, ToolID: block.ID, Questions: qi.Questions})
+					continue
 				}
-				tb.buf.WriteString(`</div>`)
 			}
+			tb.add(transcript.Event{Kind: transcript.KindToolUse, Tool: block.Name, ToolID: block.ID, Input: block.Input})
 		}
-		tb.buf.WriteString(`</div>`)
-		tb.buf.WriteString("\n\n")
 	}
-	tb.hasContent = true
 }
 
-func (tb *transcriptBuilder) writeToolResult(text string) {
-	if text == "" {
+// handleUser processes a user-type event, which carries the results of the
+// tool calls Claude made.
+func (tb *transcriptBuilder) handleUser(msg *cliMessage) {
+	if msg == nil {
 		return
 	}
-	tb.buf.WriteString(`<details style="margin:8px 0;border:1px solid #374151;border-radius:6px;overflow:hidden">`)
-	tb.buf.WriteString(`<summary style="cursor:pointer;padding:6px 10px;background:#1e293b;color:#a78bfa;font-size:13px;font-weight:600">`)
-	tb.buf.WriteString(`Result`)
-	tb.buf.WriteString(`</summary>`)
-	tb.buf.WriteString(`<pre style="margin:0;padding:8px 10px;background:#0f172a;color:#94a3b8;font-size:12px;overflow-x:auto;white-space:pre-wrap">`)
-	tb.buf.WriteString(text)
-	tb.buf.WriteString(`</pre>`)
-	tb.buf.WriteString("</details>\n\n")
-	tb.hasContent = true
+	for _, block := range msg.Content {
+		if block.Type != "tool_result" {
+			continue
+		}
+		tb.add(transcript.ToolResult(block.ToolUseID, tb.tools[block.ToolUseID], block.resultText(), block.IsError))
+	}
 }
 
-func (tb *transcriptBuilder) writeSummary(text string) {
-	text = strings.TrimSpace(text)
-	if text == "" {
-		return
+// handleLine processes one JSONL line and returns the rendered transcript
+// fragment it produced, if any. Malformed lines are skipped.
+func (tb *transcriptBuilder) handleLine(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return ""
+	}
+	var evt cliEvent
+	if err := json.Unmarshal([]byte(line), &evt); err != nil {
+		return ""
+	}
+
+	before := len(tb.events)
+	switch evt.Type {
+	case "assistant":
+		tb.handleAssistant(evt.Message)
+	case "user":
+		tb.handleUser(evt.Message)
+	case "result":
+		tb.lastResult = evt.Result
+	case sandboxEvent:
+		tb.add(transcript.Event{Kind: transcript.KindSandbox, Text: evt.Result})
 	}
-	tb.buf.WriteString(`<hr style="border-color:#374151;margin:16px 0">`)
-	tb.buf.WriteString(`<div style="margin:8px 0;padding:10px 12px;border-left:3px solid #34d399;background:#0f172a;border-radius:4px;color:#e2e8f0;font-size:13px;line-height:1.5">`)
-	tb.buf.WriteString(`<strong style="color:#34d399">Summary</strong><br>`)
-	tb.buf.WriteString(text)
-	tb.buf.WriteString("</div>\n")
-	tb.hasContent = true
+	// "system" and other types are ignored.
+	fragment := transcript.Render(tb.events[before:])
+	if fragment == "" {
+		return ""
+	}
+	return fragment + "\n\n"
 }
 
-// handleAssistant processes an assistant-type event, extracting text and
-// tool-use blocks from the message content.
-func (tb *transcriptBuilder) handleAssistant(msg *cliMessage) {
-	if msg == nil {
-		return
+// finish appends the summary from the result event if it differs from what
+// we already captured (avoids duplication when the result just echoes the
+// last assistant text) and returns the rendered transcript.
+func (tb *transcriptBuilder) finish() string {
+	if tb.lastResult != "" {
+		trimmedResult := strings.TrimSpace(tb.lastResult)
+		if !strings.Contains(tb.text(), trimmedResult) {
+			tb.add(transcript.Event{Kind: transcript.KindSummary, Text: trimmedResult})
+		}
 	}
-	for _, block := range msg.Content {
-		switch block.Type {
-		case "text":
-			tb.writeText(block.Text)
-		case "tool_use":
-			tb.writeToolUse(block.Name, block.Input)
-		case "tool_result":
-			tb.writeToolResult(block.Text)
+	return tb.build()
+}
+
+// text returns the assistant text captured so far.
+func (tb *transcriptBuilder) text() string {
+	var texts []string
+	for _, e := range tb.events {
+		if e.Kind == transcript.KindText {
+			texts = append(texts, e.Text)
 		}
 	}
+	return strings.Join(texts, "\n\n")
 }
 
 func (tb *transcriptBuilder) build() string {
-	return strings.TrimSpace(tb.buf.String())
+	return transcript.Render(tb.events)
 }
 
 // buildTranscript parses JSONL lines from stream-json output and returns a
-// markdown transcript showing the thought process, tool calls, and a final
-// summary.
+// transcript showing the thought process, tool calls, and a final summary.
 func buildTranscript(lines []string) string {
 	tb := &transcriptBuilder{}
-	var lastResult string
+	for _, line := range lines {
+		tb.handleLine(line)
+	}
+	return tb.finish()
+}
+
+// followUpEvent is the line recorded in a run's stored stream for a follow-up
+// message, or with IsError set for a follow-up that failed. The CLI never
+// prints it; it lets Rebuild restore what only the scheduler knows.
+const followUpEvent = "follow_up"
+
+// FollowUpLine returns the stream line recording a follow-up message.
+func FollowUpLine(message string) string {
+	data, _ := json.Marshal(cliEvent{Type: followUpEvent, Result: message})
+	return string(data)
+}
+
+// FollowUpErrorLine returns the stream line recording a failed follow-up.
+func FollowUpErrorLine(err error) string {
+	data, _ := json.Marshal(cliEvent{Type: followUpEvent, Result: err.Error(), IsError: true})
+	return string(data)
+}
 
+// Rebuild parses a run's stored stream into transcript events again. The
+// stream holds the output of every invocation of the run, each starting with
+// the CLI's init event, and the lines from FollowUpLine and FollowUpErrorLine.
+// The stream carries no times, so the events are stamped with the current one.
+func Rebuild(lines []string) []transcript.Event {
+	var events []transcript.Event
+	tb := &transcriptBuilder{}
+	flush := func() {
+		tb.finish()
+		events = append(events, tb.events...)
+		tb = &transcriptBuilder{}
+	}
 	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
 		var evt cliEvent
 		if err := json.Unmarshal([]byte(line), &evt); err != nil {
 			continue
 		}
-
-		switch evt.Type {
-		case "assistant":
-			tb.handleAssistant(evt.Message)
-		case "result":
-			lastResult = evt.Result
-		// "system" and other types are ignored.
-		}
-	}
-
-	// Append summary from the result event if it differs from what we
-	// already captured (avoids duplication when the result just echoes
-	// the last assistant text).
-	if lastResult != "" {
-		trimmedResult := strings.TrimSpace(lastResult)
-		if !strings.Contains(tb.build(), trimmedResult) {
-			tb.writeSummary(lastResult)
+		switch {
+		case evt.Type == "system" && evt.Subtype == "init":
+			flush()
+		case evt.Type == followUpEvent && evt.IsError:
+			// A failed invocation's output is not part of the transcript.
+			tb = &transcriptBuilder{}
+			events = append(events, transcript.Event{Kind: transcript.KindFollowUpError, Time: time.Now().UTC(), Text: evt.Result})
+			continue
+		case evt.Type == followUpEvent:
+			flush()
+			events = append(events, transcript.Event{Kind: transcript.KindFollowUp, Time: time.Now().UTC(), Text: evt.Result})
+			continue
 		}
+		tb.handleLine(line)
 	}
-
-	return tb.build()
+	flush()
+	return events
 }
 
 // mcpConfigFile represents the JSON structure expected by --mcp-config.
@@ -415,6 +612,79 @@ func buildMCPArgs(servers []db.MCPServer) (args []string, cleanup func(), err er
 	return []string{"--mcp-config", f.Name()}, cleanup, nil
 }
 
+// extractModel returns the model named in the system init event, or empty if
+// the stream has none.
+func extractModel(lines []string) string {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var evt cliEvent
+		if err := json.Unmarshal([]byte(line), &evt); err != nil {
+			continue
+		}
+		if evt.Type == "system" && evt.Subtype == "init" && evt.Model != "" {
+			return evt.Model
+		}
+	}
+	return ""
+}
+
+// extractSession returns the session ID from the first event that carries
+// one, and the context size of the last assistant turn.
+func extractSession(lines []string) (sessionID string, contextTokens int64) {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var evt cliEvent
+		if err := json.Unmarshal([]byte(line), &evt); err != nil {
+			continue
+		}
+		if sessionID == "" {
+			sessionID = evt.SessionID
+		}
+		if evt.Type == "assistant" && evt.Message != nil && evt.Message.Usage != nil {
+			u := evt.Message.Usage
+			contextTokens = u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+		}
+	}
+	return sessionID, contextTokens
+}
+
+// extractUsage returns the usage reported by the last result event in the
+// stream, or zero usage if there is none.
+func extractUsage(lines []string) db.Usage {
+	var usage db.Usage
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var evt cliEvent
+		if err := json.Unmarshal([]byte(line), &evt); err != nil {
+			continue
+		}
+		if evt.Type != "result" {
+			continue
+		}
+		usage = db.Usage{
+			DurationMs: evt.DurationMs,
+			NumTurns:   evt.NumTurns,
+			CostUSD:    evt.TotalCostUSD,
+		}
+		if evt.Usage != nil {
+			usage.InputTokens = evt.Usage.InputTokens
+			usage.OutputTokens = evt.Usage.OutputTokens
+			usage.CacheCreationTokens = evt.Usage.CacheCreationInputTokens
+			usage.CacheReadTokens = evt.Usage.CacheReadInputTokens
+		}
+	}
+	return usage
+}
+
 // extractError inspects stream-json output lines for a human-readable error
 // message. It prefers the result event (with is_error=true) and falls back to
 // assistant message text.
@@ -446,10 +716,71 @@ func extractError(lines []string) string {
 	return fallback
 }
 
-// runClaude executes the claude CLI with stream-json output and builds a transcript.
-func runClaude(ctx context.Context, args []string) (ExecuteResult, error) {
-	cmd := exec.CommandContext(ctx, "claude", args...)
+// claudeError builds the most informative error it can for a failed CLI
+// invocation that printed lines and stderr.
+func claudeError(err error, lines []string, stderr string) error {
+	// Try to extract a human-readable error from the stream-json output.
+	if msg := extractError(lines); msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+	stderrMsg := strings.TrimSpace(stderr)
+	stdout := strings.TrimSpace(strings.Join(lines, "\n"))
+
+	var parts []string
+	if stderrMsg != "" {
+		parts = append(parts, stderrMsg)
+	}
+	if stdout != "" {
+		parts = append(parts, stdout)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, err.Error())
+	}
+	return fmt.Errorf("claude: %s", strings.Join(parts, "\n"))
+}
+
+// runClaude executes the claude CLI in the job's working directory with
+// stream-json output and builds a transcript. An empty working directory runs
+// in the current one. Each line is parsed as it arrives and any new transcript
+// fragment is passed to onProgress when it is non-nil.
+func runClaude(ctx context.Context, job db.Job, args []string, onProgress func(string)) (ExecuteResult, error) {
+	bin := claudeBinary()
+	env, err := claudeEnv(bin, job)
+	if err != nil {
+		return ExecuteResult{}, err
+	}
+
+	name, cmdArgs := bin, args
+	var sb *sandbox
+	if job.Sandbox {
+		if sb, err = newSandbox(job, env); err != nil {
+			return ExecuteResult{}, fmt.Errorf("setting up sandbox: %w", err)
+		}
+		defer sb.close()
+		if name, cmdArgs, env, err = sb.wrap(bin, args, env); err != nil {
+			return ExecuteResult{}, fmt.Errorf("setting up sandbox: %w", err)
+		}
+	}
+
+	var lim *limits
+	if hasLimits(job) {
+		if lim, err = newLimits(job); err != nil {
+			return ExecuteResult{}, fmt.Errorf("setting up resource limits: %w", err)
+		}
+		defer lim.close()
+	}
+
+	// A run that uses up its CPU time is stopped like a cancelled one.
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	cmd := exec.CommandContext(ctx, name, cmdArgs...)
+	cmd.Dir = job.WorkingDir
+	cmd.Env = env
 	hideWindow(cmd)
+	if lim != nil {
+		lim.prepare(cmd)
+	}
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -459,68 +790,77 @@ func runClaude(ctx context.Context, args []string) (ExecuteResult, error) {
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Start(); err != nil {
+	release, err := startProcessTree(cmd)
+	if err != nil {
 		return ExecuteResult{}, fmt.Errorf("starting claude: %w", err)
 	}
+	defer release()
+	if lim != nil {
+		if err := lim.start(cmd.Process.Pid, stop); err != nil {
+			stop()
+			cmd.Wait()
+			return ExecuteResult{}, fmt.Errorf("setting up resource limits: %w", err)
+		}
+	}
 
-	// Read all lines from stdout.
+	// Read lines from stdout, building the transcript incrementally.
 	var lines []string
+	tb := &transcriptBuilder{}
 	scanner := bufio.NewScanner(stdoutPipe)
 	scanner.Buffer(make([]byte, 0, 1024*1024), 10*1024*1024) // 10MB max line
 	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+		line := scanner.Text()
+		lines = append(lines, line)
+		if fragment := tb.handleLine(line); fragment != "" && onProgress != nil {
+			onProgress(fragment)
+		}
 	}
 
-	dumpDebugLines(lines)
-
-	if err := cmd.Wait(); err != nil {
-		// Try to extract a human-readable error from the stream-json output.
-		if msg := extractError(lines); msg != "" {
-			return ExecuteResult{}, fmt.Errorf("%s", msg)
+	// Record what the sandbox stopped, so it shows in the transcript and is
+	// part of the stored stream.
+	var blocked []string
+	if sb != nil {
+		if blocked = sb.blocked(tb.events); len(blocked) > 0 {
+			line := sandboxLine(blocked)
+			lines = append(lines, line)
+			if fragment := tb.handleLine(line); fragment != "" && onProgress != nil {
+				onProgress(fragment)
+			}
 		}
-		// Build the most informative error we can from what's available.
-		stderrMsg := strings.TrimSpace(stderr.String())
-		stdout := strings.TrimSpace(strings.Join(lines, "\n"))
+	}
 
-		var parts []string
-		if stderrMsg != "" {
-			parts = append(parts, stderrMsg)
-		}
-		if stdout != "" {
-			parts = append(parts, stdout)
+	// Metadata is returned even when the CLI fails, so callers can record the
+	// session and cost of a failed run.
+	sessionID, contextTokens := extractSession(lines)
+	result := ExecuteResult{
+		RawLines:      lines,
+		Model:         extractModel(lines),
+		Usage:         extractUsage(lines),
+		SessionID:     sessionID,
+		Summary:       tb.lastResult,
+		ContextTokens: contextTokens,
+	}
+
+	if err := cmd.Wait(); err != nil {
+		err = claudeError(err, lines, stderr.String())
+		if len(blocked) > 0 {
+			err = fmt.Errorf("%w\n\nThe sandbox blocked:\n%s", err, strings.Join(blocked, "\n"))
 		}
-		if len(parts) == 0 {
-			parts = append(parts, err.Error())
+		if lim != nil {
+			err = limitError(job, lim.exceeded(cmd.ProcessState), err)
 		}
-		return ExecuteResult{}, fmt.Errorf("claude: %s", strings.Join(parts, "\n"))
+		return result, err
 	}
 
-	transcript := buildTranscript(lines)
-	if transcript == "" {
+	result.Transcript = tb.finish()
+	result.Events = tb.events
+	if result.Transcript == "" {
 		raw := strings.Join(lines, "\n")
 		if raw == "" {
-			return ExecuteResult{}, fmt.Errorf("empty response from claude")
+			return result, fmt.Errorf("empty response from claude")
 		}
-		return ExecuteResult{Transcript: raw, RawLines: lines}, nil
-	}
-
-	return ExecuteResult{Transcript: transcript, RawLines: lines}, nil
-}
-
-// dumpDebugLines writes raw JSONL lines to a timestamped file in DebugDir.
-func dumpDebugLines(lines []string) {
-	if DebugDir == "" || len(lines) == 0 {
-		return
-	}
-	if err := os.MkdirAll(DebugDir, 0o755); err != nil {
-		log.Printf("debug: failed to create dir %s: %v", DebugDir, err)
-		return
-	}
-	name := fmt.Sprintf("run-%s.jsonl", time.Now().UTC().Format("20060102-150405"))
-	path := filepath.Join(DebugDir, name)
-	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
-		log.Printf("debug: failed to write %s: %v", path, err)
-	} else {
-		log.Printf("debug: wrote %d lines to %s", len(lines), path)
+		// Lines that are not stream-json are shown as printed, not as HTML.
+		result.Transcript = transcript.RenderOutput(raw)
 	}
+	return result, nil
 }
@@ -0,0 +1,192 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/fakeclaude"
+	"claude-schedule/internal/transcript"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	SandboxMain()
+	fakeclaude.Main()
+	os.Exit(m.Run())
+}
+
+// withFake runs the CLI as a fake playing steps for the duration of a test.
+func withFake(t *testing.T, steps ...fakeclaude.Step) *fakeclaude.Fake {
+	t.Helper()
+	fake := fakeclaude.New(t, steps...)
+	withSettings(t, fake.Settings())
+	return fake
+}
+
+func TestClaudeExecute_ReplaysRecordedRun(t *testing.T) {
+	fake := withFake(t, fakeclaude.Replay("tools"))
+	dir := t.TempDir()
+
+	job := db.Job{Prompt: "check the repo", SessionID: "sess-1", WorkingDir: dir, Model: "claude-opus-4-1"}
+	result, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.NoError(t, err)
+
+	require.Contains(t, result.Transcript, "Tool: Bash")
+	require.Contains(t, result.Transcript, " M README.md</pre>")
+	require.NotContains(t, result.Transcript, "Result: Bash")
+	require.Contains(t, result.Transcript, "The README has uncommitted changes.")
+	require.Equal(t, "The README has uncommitted changes.", result.Summary)
+	require.Equal(t, "claude-opus-4-1", result.Model)
+	require.Equal(t, "sess-1", result.SessionID)
+	require.Equal(t, int64(2), result.Usage.NumTurns)
+	require.Equal(t, int64(6+140+13500), result.ContextTokens)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 1)
+	require.Equal(t, "check the repo", calls[0].Prompt())
+	require.Equal(t, "sess-1", calls[0].Flag("--session-id"))
+	require.False(t, calls[0].Has("--resume"))
+	require.Equal(t, dir, calls[0].Dir)
+}
+
+func TestClaudeExecute_ResumesExistingSession(t *testing.T) {
+	fake := withFake(t)
+	fake.AddSession("sess-1")
+
+	job := db.Job{Prompt: "again", SessionID: "sess-1", SessionRuns: 3}
+	result, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "sess-1", result.SessionID)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 1)
+	require.Equal(t, "sess-1", calls[0].Flag("--resume"))
+}
+
+func TestClaudeExecute_StartsAfreshWhenSessionIsGone(t *testing.T) {
+	fake := withFake(t, fakeclaude.Reply("fresh start"))
+
+	job := db.Job{Prompt: "again", SessionID: "sess-1", SessionRuns: 3}
+	result, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "fresh start", result.Summary)
+	require.Equal(t, "sess-1", result.SessionID)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 2)
+	require.Equal(t, "sess-1", calls[0].Flag("--resume"))
+	require.Contains(t, calls[0].Error, "No conversation found")
+	require.Equal(t, "sess-1", calls[1].Flag("--session-id"))
+}
+
+func TestClaudeExecute_ReportsErrorResult(t *testing.T) {
+	withFake(t, fakeclaude.Fail("Invalid API key · Please run /login"))
+
+	result, err := ClaudeExecute(context.Background(), db.Job{Prompt: "x", SessionID: "sess-1"}, nil, Options{})
+	require.EqualError(t, err, "Invalid API key · Please run /login")
+	require.Equal(t, "sess-1", result.SessionID)
+}
+
+func TestClaudeExecute_NoTools(t *testing.T) {
+	fake := withFake(t, fakeclaude.Reply("PASS"), fakeclaude.Reply("done"))
+
+	_, err := ClaudeExecute(context.Background(), db.Job{Prompt: "judge this"}, nil, Options{NoTools: true})
+	require.NoError(t, err)
+	_, err = ClaudeExecute(context.Background(), db.Job{Prompt: "do this"}, nil, Options{})
+	require.NoError(t, err)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 2)
+	require.True(t, calls[0].Has("--tools"))
+	require.Equal(t, "", calls[0].Flag("--tools"))
+	require.False(t, calls[1].Has("--tools"))
+}
+
+func TestClaudeExecute_EscapesOutputThatIsNotJSON(t *testing.T) {
+	withFake(t, fakeclaude.Step{Lines: []string{
+		"<img src=x onerror=alert(1)>",
+		"",
+		"[x](javascript:alert(1)) <script>alert(2)</script>",
+	}})
+
+	result, err := ClaudeExecute(context.Background(), db.Job{Prompt: "x"}, nil, Options{})
+	require.NoError(t, err)
+	require.Equal(t, transcript.RenderOutput("<img src=x onerror=alert(1)>\n\n[x](javascript:alert(1)) <script>alert(2)</script>"), result.Transcript)
+	require.NotContains(t, result.Transcript, "<img")
+	require.NotContains(t, result.Transcript, "<script")
+	require.NotContains(t, result.Transcript, "\n", "the output stays one HTML block")
+}
+
+func TestClaudeAnswer_ResumesWithAnswer(t *testing.T) {
+	fake := withFake(t,
+		fakeclaude.Question("Which branch should I deploy?", "main", "develop"),
+		fakeclaude.Reply("Deployed main."),
+	)
+
+	job := db.Job{Prompt: "deploy", SessionID: "sess-1"}
+	result, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.NoError(t, err)
+	require.Contains(t, DetectQuestion(result.RawLines), "Which branch should I deploy?")
+
+	job.SessionRuns = 1
+	result, err = ClaudeAnswer(context.Background(), job, nil, "main", Options{})
+	require.NoError(t, err)
+	require.Equal(t, "Deployed main.", result.Summary)
+	require.Empty(t, DetectQuestion(result.RawLines))
+
+	calls := fake.Calls()
+	require.Len(t, calls, 2)
+	require.Equal(t, "main", calls[1].Prompt())
+	require.Equal(t, "sess-1", calls[1].Flag("--resume"))
+}
+
+func TestClaudeFollowUp_ForksSession(t *testing.T) {
+	fake := withFake(t)
+	fake.AddSession("sess-1")
+
+	result, err := ClaudeFollowUp(context.Background(), db.Job{SessionID: "sess-1"}, nil, "why?", true, Options{})
+	require.NoError(t, err)
+	require.NotEmpty(t, result.SessionID)
+	require.NotEqual(t, "sess-1", result.SessionID)
+	require.True(t, fake.Calls()[0].Has("--fork-session"))
+}
+
+func TestRunClaude_StreamsProgress(t *testing.T) {
+	withFake(t, fakeclaude.Replay("tools").Slow(20*time.Millisecond))
+
+	var mu sync.Mutex
+	var fragments []string
+	var times []time.Time
+	onProgress := func(fragment string) {
+		mu.Lock()
+		defer mu.Unlock()
+		fragments = append(fragments, fragment)
+		times = append(times, time.Now())
+	}
+
+	_, err := ClaudeExecute(context.Background(), db.Job{Prompt: "x"}, nil, Options{OnProgress: onProgress})
+	require.NoError(t, err)
+
+	require.Len(t, fragments, 4)
+	require.Contains(t, fragments[0], "Let me look at the repository.")
+	require.Contains(t, fragments[1], "Tool: Bash")
+	require.Contains(t, fragments[2], "Result: Bash")
+	// Fragments arrive as the CLI prints them, not all at the end.
+	require.GreaterOrEqual(t, times[3].Sub(times[0]), 20*time.Millisecond)
+}
+
+func TestRunClaude_CancelStopsSlowRun(t *testing.T) {
+	withFake(t, fakeclaude.Replay("tools").Slow(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ClaudeExecute(ctx, db.Job{Prompt: "x"}, nil, Options{})
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 3*time.Second)
+}
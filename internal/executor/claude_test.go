@@ -2,7 +2,14 @@ package executor
 
 import (
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/structured"
+	"claude-schedule/internal/transcript"
 
 	"github.com/stretchr/testify/require"
 )
@@ -79,6 +86,40 @@ func TestBuildTranscript_ToolUseWithResult(t *testing.T) {
 	require.Contains(t, got, "Here are the files.")
 }
 
+func TestBuildTranscript_UserToolResults(t *testing.T) {
+	user := func(blocks ...cliContentBlock) string {
+		b, _ := json.Marshal(cliEvent{Type: "user", Message: &cliMessage{Role: "user", Content: blocks}})
+		return string(b)
+	}
+	lines := []string{
+		assistantLine(
+			cliContentBlock{Type: "tool_use", Name: "Bash", ID: "tool-1", Input: json.RawMessage(`{"command":"make"}`)},
+			cliContentBlock{Type: "tool_use", Name: "Read", ID: "tool-2", Input: json.RawMessage(`{"file":"shot.png"}`)},
+		),
+		user(cliContentBlock{Type: "tool_result", ToolUseID: "tool-1", Content: json.RawMessage(`"make: *** No rule to make target"`), IsError: true}),
+		user(cliContentBlock{Type: "tool_result", ToolUseID: "tool-2", Content: json.RawMessage(`[{"type":"text","text":"A screenshot"},{"type":"image","source":{}}]`)}),
+		resultLine("Build failed."),
+	}
+
+	tb := &transcriptBuilder{}
+	for _, line := range lines {
+		tb.handleLine(line)
+	}
+	require.Len(t, tb.events, 4)
+	require.Equal(t, transcript.ToolResult("tool-1", "Bash", "make: *** No rule to make target", true), withoutTime(tb.events[2]))
+	require.Equal(t, transcript.ToolResult("tool-2", "Read", "A screenshot\n[image]", false), withoutTime(tb.events[3]))
+
+	got := tb.finish()
+	require.Contains(t, got, "make: *** No rule to make target</pre>")
+	require.Contains(t, got, ">error</span>")
+	require.NotContains(t, got, "Result: Bash")
+}
+
+func withoutTime(e transcript.Event) transcript.Event {
+	e.Time = time.Time{}
+	return e
+}
+
 func TestBuildTranscript_MixedTextAndTool(t *testing.T) {
 	lines := []string{
 		systemLine(),
@@ -215,3 +256,234 @@ func TestBuildMCPArgs_NoServers(t *testing.T) {
 	require.Nil(t, args)
 	cleanup() // noop, should not panic
 }
+
+func TestJobArgs_AddDirs(t *testing.T) {
+	job := db.Job{AddDirs: `["/src/a","/src/b"]`}
+	args, cleanup, err := jobArgs(job, nil)
+	require.NoError(t, err)
+	defer cleanup()
+
+	require.Contains(t, strings.Join(args, " "), "--add-dir /src/a --add-dir /src/b")
+}
+
+func TestJobArgs_InvalidAddDirs(t *testing.T) {
+	_, cleanup, err := jobArgs(db.Job{AddDirs: "{"}, nil)
+	require.Error(t, err)
+	cleanup()
+}
+
+func TestJobArgs_ModelFlags(t *testing.T) {
+	job := db.Job{Model: "opus", FallbackModel: "sonnet", MaxTurns: 5}
+	args, cleanup, err := jobArgs(job, nil)
+	require.NoError(t, err)
+	defer cleanup()
+
+	joined := strings.Join(args, " ")
+	require.Contains(t, joined, "--model opus")
+	require.Contains(t, joined, "--fallback-model sonnet")
+	require.Contains(t, joined, "--max-turns 5")
+}
+
+func TestJobArgs_OmitsUnsetModelFlags(t *testing.T) {
+	args, cleanup, err := jobArgs(db.Job{}, nil)
+	require.NoError(t, err)
+	defer cleanup()
+
+	require.NotContains(t, args, "--model")
+	require.NotContains(t, args, "--fallback-model")
+	require.NotContains(t, args, "--max-turns")
+}
+
+func TestExtractModel(t *testing.T) {
+	init, _ := json.Marshal(cliEvent{Type: "system", Subtype: "init", Model: "claude-haiku-4-5"})
+	lines := []string{
+		string(init),
+		assistantLine(cliContentBlock{Type: "text", Text: "hi"}),
+		resultLine("hi"),
+	}
+	require.Equal(t, "claude-haiku-4-5", extractModel(lines))
+	require.Equal(t, "", extractModel([]string{systemLine()}))
+}
+
+func TestSystemPromptArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		job      db.Job
+		def      string
+		expected []string
+	}{
+		{
+			name:     "no prompts",
+			job:      db.Job{},
+			expected: nil,
+		},
+		{
+			name:     "default only",
+			job:      db.Job{},
+			def:      "Use the web.",
+			expected: []string{"--append-system-prompt", "Use the web."},
+		},
+		{
+			name:     "default and job prompt are combined",
+			job:      db.Job{SystemPrompt: "Be terse.", SystemPromptMode: "append"},
+			def:      "Use the web.",
+			expected: []string{"--append-system-prompt", "Use the web.\n\nBe terse."},
+		},
+		{
+			name:     "job opts out of default",
+			job:      db.Job{SystemPrompt: "Work offline.", SkipDefaultSystemPrompt: true},
+			def:      "Use the web.",
+			expected: []string{"--append-system-prompt", "Work offline."},
+		},
+		{
+			name:     "replace mode",
+			job:      db.Job{SystemPrompt: "You are a linter.", SystemPromptMode: "replace", SkipDefaultSystemPrompt: true},
+			def:      "Use the web.",
+			expected: []string{"--system-prompt", "You are a linter."},
+		},
+		{
+			name: "replace mode keeps default unless skipped",
+			job:  db.Job{SystemPrompt: "You are a linter.", SystemPromptMode: "replace"},
+			def:  "Use the web.",
+			expected: []string{
+				"--system-prompt", "You are a linter.",
+				"--append-system-prompt", "Use the web.",
+			},
+		},
+		{
+			name:     "output schema",
+			job:      db.Job{OutputSchema: `{"type": "object"}`},
+			def:      "Use the web.",
+			expected: []string{"--append-system-prompt", "Use the web.\n\n" + structured.Prompt(`{"type": "object"}`)},
+		},
+		{
+			name: "output schema in replace mode",
+			job:  db.Job{SystemPrompt: "You are a linter.", SystemPromptMode: "replace", SkipDefaultSystemPrompt: true, OutputSchema: `{"type": "array"}`},
+			expected: []string{
+				"--system-prompt", "You are a linter.\n\n" + structured.Prompt(`{"type": "array"}`),
+			},
+		},
+		{
+			name:     "outcome",
+			job:      db.Job{SystemPrompt: "Check the pricing page.", ReportOutcome: true},
+			expected: []string{"--append-system-prompt", "Check the pricing page.\n\n" + OutcomePrompt},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, systemPromptArgs(tc.job, tc.def))
+		})
+	}
+}
+
+func TestJobArgs_UsesDefaultSystemPromptSetting(t *testing.T) {
+	SetSettings(db.Settings{DefaultSystemPrompt: "Global instruction."})
+	t.Cleanup(func() { SetSettings(db.Settings{}) })
+
+	args, cleanup, err := jobArgs(db.Job{}, nil)
+	require.NoError(t, err)
+	defer cleanup()
+	require.Contains(t, strings.Join(args, " "), "--append-system-prompt Global instruction.")
+}
+
+func TestTranscriptBuilder_HandleLineReturnsFragments(t *testing.T) {
+	lines := []string{
+		systemLine(),
+		assistantLine(cliContentBlock{Type: "text", Text: "Let me look into that."}),
+		assistantLine(cliContentBlock{Type: "tool_use", Name: "Bash", Input: json.RawMessage(`{"command":"ls"}`)}),
+		resultLine("The answer is 42."),
+	}
+
+	tb := &transcriptBuilder{}
+	var fragments []string
+	for _, line := range lines {
+		if f := tb.handleLine(line); f != "" {
+			fragments = append(fragments, f)
+		}
+	}
+
+	require.Len(t, fragments, 2)
+	require.Contains(t, fragments[0], "Let me look into that.")
+	require.Contains(t, fragments[1], "Tool: Bash")
+	// The summary is only added once the stream is complete.
+	require.Equal(t, buildTranscript(lines), tb.finish())
+	require.Contains(t, tb.build(), "The answer is 42.")
+}
+
+func TestRebuild(t *testing.T) {
+	lines := []string{
+		systemLine(),
+		assistantLine(cliContentBlock{Type: "text", Text: "Checking."}),
+		resultLine("All good."),
+		FollowUpLine("Why?"),
+		systemLine(),
+		assistantLine(cliContentBlock{Type: "text", Text: "Because."}),
+		resultLine("Because."),
+		FollowUpLine("Again?"),
+		systemLine(),
+		assistantLine(cliContentBlock{Type: "text", Text: "Partial"}),
+		FollowUpErrorLine(errors.New("claude exited")),
+	}
+
+	var kinds, texts []string
+	for _, e := range Rebuild(lines) {
+		kinds = append(kinds, e.Kind)
+		texts = append(texts, e.Text)
+	}
+	// Each invocation gets its own summary, and a failed one only its error.
+	require.Equal(t, []string{
+		transcript.KindText, transcript.KindSummary,
+		transcript.KindFollowUp, transcript.KindText,
+		transcript.KindFollowUp, transcript.KindFollowUpError,
+	}, kinds)
+	require.Equal(t, []string{"Checking.", "All good.", "Why?", "Because.", "Again?", "claude exited"}, texts)
+	require.Empty(t, Rebuild(nil))
+}
+
+func TestExtractUsage(t *testing.T) {
+	result := `{"type":"result","subtype":"success","result":"done","duration_ms":4200,"num_turns":3,` +
+		`"total_cost_usd":0.0123,"usage":{"input_tokens":12,"output_tokens":345,` +
+		`"cache_creation_input_tokens":67,"cache_read_input_tokens":890}}`
+	lines := []string{
+		systemLine(),
+		assistantLine(cliContentBlock{Type: "text", Text: "done"}),
+		result,
+	}
+
+	got := extractUsage(lines)
+	require.Equal(t, int64(4200), got.DurationMs)
+	require.Equal(t, int64(3), got.NumTurns)
+	require.Equal(t, int64(12), got.InputTokens)
+	require.Equal(t, int64(345), got.OutputTokens)
+	require.Equal(t, int64(67), got.CacheCreationTokens)
+	require.Equal(t, int64(890), got.CacheReadTokens)
+	require.InDelta(t, 0.0123, got.CostUSD, 1e-9)
+}
+
+func TestExtractUsage_NoResult(t *testing.T) {
+	got := extractUsage([]string{systemLine()})
+	require.Zero(t, got)
+}
+
+func TestSessionArgs(t *testing.T) {
+	require.Equal(t, []string{"--session-id", "abc"}, sessionArgs("abc", false))
+	require.Equal(t, []string{"--resume", "abc"}, sessionArgs("abc", true))
+}
+
+func TestExtractSession(t *testing.T) {
+	first := `{"type":"assistant","message":{"content":[{"type":"text","text":"a"}],` +
+		`"usage":{"input_tokens":1,"cache_creation_input_tokens":10,"cache_read_input_tokens":100}}}`
+	last := `{"type":"assistant","message":{"content":[{"type":"text","text":"b"}],` +
+		`"usage":{"input_tokens":2,"output_tokens":50,"cache_creation_input_tokens":20,"cache_read_input_tokens":200}}}`
+	lines := []string{
+		`{"type":"system","subtype":"init","session_id":"sess-1"}`,
+		first,
+		last,
+		resultLine("b"),
+	}
+
+	sessionID, contextTokens := extractSession(lines)
+	require.Equal(t, "sess-1", sessionID)
+	require.Equal(t, int64(222), contextTokens)
+}
@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"claude-schedule/internal/db"
+)
+
+// claudeCandidates returns common install locations of the claude binary,
+// most likely first. Apps started from a desktop launcher often have a PATH
+// that misses all of them.
+func claudeCandidates() []string {
+	home, _ := os.UserHomeDir()
+	if runtime.GOOS == "windows" {
+		return []string{
+			filepath.Join(home, ".local", "bin", "claude.exe"),
+			filepath.Join(os.Getenv("APPDATA"), "npm", "claude.cmd"),
+		}
+	}
+
+	candidates := []string{
+		filepath.Join(home, ".local", "bin", "claude"),
+		filepath.Join(home, ".claude", "local", "claude"),
+		"/opt/homebrew/bin/claude",
+		"/usr/local/bin/claude",
+		filepath.Join(home, ".npm-global", "bin", "claude"),
+		filepath.Join(home, ".bun", "bin", "claude"),
+	}
+	// nvm keeps one global install per node version; prefer the newest.
+	nvm, _ := filepath.Glob(filepath.Join(home, ".nvm", "versions", "node", "*", "bin", "claude"))
+	slices.SortStableFunc(nvm, func(a, b string) int {
+		return slices.Compare(nodeVersion(b), nodeVersion(a))
+	})
+	return append(candidates, nvm...)
+}
+
+// nodeVersion returns the numbers of the node version an nvm install at path
+// is for, such as [22 11 0] for .../v22.11.0/bin/claude. A directory that is
+// not named for a version yields nil, which sorts before any version.
+func nodeVersion(path string) []int {
+	name := filepath.Base(filepath.Dir(filepath.Dir(path)))
+	parts := strings.Split(strings.TrimPrefix(name, "v"), ".")
+	version := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil
+		}
+		version = append(version, n)
+	}
+	return version
+}
+
+// DetectClaudePath looks for the claude binary on PATH and then in the
+// install locations used by the native installer, npm, nvm, Homebrew and bun.
+func DetectClaudePath() (string, error) {
+	if path, err := exec.LookPath("claude"); err == nil {
+		return filepath.Abs(path)
+	}
+	for _, path := range claudeCandidates() {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("claude CLI not found on PATH or in common install locations")
+}
+
+// claudeBinary returns the binary to run: the configured path, otherwise the
+// detected one, otherwise plain "claude" so exec reports the lookup failure.
+func claudeBinary() string {
+	if path := currentSettings().ClaudePath; path != "" {
+		return path
+	}
+	if path, err := DetectClaudePath(); err == nil {
+		return path
+	}
+	return "claude"
+}
+
+// claudeEnv returns the environment for a CLI invocation of bin for job: the
+// app's environment, then the global CLI overrides, then the job's own.
+func claudeEnv(bin string, job db.Job) ([]string, error) {
+	globalEnv, err := currentSettings().ClaudeEnvMap()
+	if err != nil {
+		return nil, err
+	}
+	jobEnv, err := job.EnvMap()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := []map[string]string{globalEnv, jobEnv}
+	if filepath.IsAbs(bin) {
+		// npm-installed CLIs run under node, which nvm and Homebrew keep next
+		// to the claude binary, so make that directory reachable too.
+		path := filepath.Dir(bin) + string(os.PathListSeparator) + os.Getenv("PATH")
+		overrides = append([]map[string]string{{"PATH": path}}, overrides...)
+	}
+	return childEnv(overrides...), nil
+}
+
+// childEnv returns the app's environment with overrides applied in order.
+// Later values win: exec uses the last entry for a repeated key.
+func childEnv(overrides ...map[string]string) []string {
+	env := os.Environ()
+	for _, m := range overrides {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			env = append(env, k+"="+m[k])
+		}
+	}
+	return env
+}
+
+// envValue returns the value exec would use for key in env.
+func envValue(env []string, key string) string {
+	var value string
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			value = v
+		}
+	}
+	return value
+}
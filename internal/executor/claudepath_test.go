@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChildEnv(t *testing.T) {
+	t.Setenv("CS_TEST_VAR", "app")
+
+	env := childEnv(map[string]string{"CS_TEST_VAR": "global", "CS_OTHER": "x"}, map[string]string{"CS_TEST_VAR": "job"})
+	require.Equal(t, "job", envValue(env, "CS_TEST_VAR"))
+	require.Equal(t, "x", envValue(env, "CS_OTHER"))
+}
+
+func TestClaudeEnv(t *testing.T) {
+	withSettings(t, db.Settings{ClaudeEnv: `{"CS_GLOBAL":"g","CS_SHARED":"global"}`})
+
+	bin := filepath.Join(t.TempDir(), "claude")
+	env, err := claudeEnv(bin, db.Job{Env: `{"CS_SHARED":"job"}`})
+	require.NoError(t, err)
+	require.Equal(t, "g", envValue(env, "CS_GLOBAL"))
+	require.Equal(t, "job", envValue(env, "CS_SHARED"))
+	require.True(t, strings.HasPrefix(envValue(env, "PATH"), filepath.Dir(bin)+string(os.PathListSeparator)))
+
+	_, err = claudeEnv(bin, db.Job{Env: `not json`})
+	require.Error(t, err)
+}
+
+func TestClaudeBinaryPrefersSetting(t *testing.T) {
+	withSettings(t, db.Settings{ClaudePath: "/opt/custom/claude"})
+	require.Equal(t, "/opt/custom/claude", claudeBinary())
+}
+
+func TestDetectClaudePath_CommonLocation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses Unix install locations")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PATH", t.TempDir())
+
+	bin := filepath.Join(home, ".local", "bin", "claude")
+	require.NoError(t, os.MkdirAll(filepath.Dir(bin), 0o755))
+	require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755))
+
+	got, err := DetectClaudePath()
+	require.NoError(t, err)
+	require.Equal(t, bin, got)
+}
+
+func TestClaudeCandidates_NewestNvmVersionFirst(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses Unix install locations")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	for _, version := range []string{"v9.11.2", "v22.11.0", "v22.9.0", "system"} {
+		bin := filepath.Join(home, ".nvm", "versions", "node", version, "bin", "claude")
+		require.NoError(t, os.MkdirAll(filepath.Dir(bin), 0o755))
+		require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755))
+	}
+
+	nvm := func(version string) string {
+		return filepath.Join(home, ".nvm", "versions", "node", version, "bin", "claude")
+	}
+	candidates := claudeCandidates()
+	require.Equal(t, []string{nvm("v22.11.0"), nvm("v22.9.0"), nvm("v9.11.2"), nvm("system")}, candidates[len(candidates)-4:])
+}
+
+func TestJobArgs_AppendsExtraArgs(t *testing.T) {
+	withSettings(t, db.Settings{ClaudeArgs: `["--permission-mode","plan"]`})
+
+	args, cleanup, err := jobArgs(db.Job{}, nil)
+	defer cleanup()
+	require.NoError(t, err)
+	require.Equal(t, []string{"--permission-mode", "plan"}, args[len(args)-2:])
+}
+
+func TestRunClaude_UsesConfiguredBinaryAndEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the CLI")
+	}
+	bin := filepath.Join(t.TempDir(), "my-claude")
+	script := "#!/bin/sh\n" +
+		`echo "{\"type\":\"result\",\"subtype\":\"success\",\"result\":\"$CS_GLOBAL $CS_JOB\"}"` + "\n"
+	require.NoError(t, os.WriteFile(bin, []byte(script), 0o755))
+	withSettings(t, db.Settings{ClaudePath: bin, ClaudeEnv: `{"CS_GLOBAL":"from-settings"}`})
+
+	result, err := runClaude(context.Background(), db.Job{Env: `{"CS_JOB":"from-job"}`}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "from-settings from-job", result.Summary)
+}
@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"claude-schedule/internal/db"
+)
+
+// Names of the built-in backends.
+const (
+	BackendClaude = "claude" // the Claude Code CLI
+	BackendAPI    = "api"    // the Anthropic Messages API
+	BackendShell  = "shell"  // a plain shell command
+)
+
+// Executor is a backend that runs jobs. Every backend reports its results in
+// the same ExecuteResult shape so the scheduler can treat them alike.
+type Executor interface {
+	// Execute runs the job's prompt.
+	Execute(ctx context.Context, job db.Job, mcpServers []db.MCPServer, opts Options) (ExecuteResult, error)
+	// Answer resumes job.SessionID with the user's answer to a question.
+	Answer(ctx context.Context, job db.Job, mcpServers []db.MCPServer, answer string, opts Options) (ExecuteResult, error)
+	// FollowUp sends a follow-up message to job.SessionID, forking it first
+	// when fork is set.
+	FollowUp(ctx context.Context, job db.Job, mcpServers []db.MCPServer, message string, fork bool, opts Options) (ExecuteResult, error)
+	// Sessions reports whether the backend keeps conversations, which makes
+	// a job's session policy, answers and follow-ups meaningful.
+	Sessions() bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Executor{}
+)
+
+func init() {
+	Register(BackendClaude, claudeExecutor{})
+	Register(BackendAPI, &APIExecutor{})
+	Register(BackendShell, ShellExecutor{})
+}
+
+// Register makes a backend available under name, replacing any backend
+// already registered with that name.
+func Register(name string, e Executor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = e
+}
+
+// Lookup returns the backend registered under name. An empty name selects
+// the Claude CLI.
+func Lookup(name string) (Executor, error) {
+	if name == "" {
+		name = BackendClaude
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	e, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown executor: %s", name)
+	}
+	return e, nil
+}
+
+// Names returns the names of all registered backends, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UsesSessions reports whether the named backend keeps conversations.
+// Unknown backends do not.
+func UsesSessions(name string) bool {
+	e, err := Lookup(name)
+	return err == nil && e.Sessions()
+}
+
+// Execute runs a job with the backend selected by job.Executor.
+func Execute(ctx context.Context, job db.Job, mcpServers []db.MCPServer, opts Options) (ExecuteResult, error) {
+	e, err := Lookup(job.Executor)
+	if err != nil {
+		return ExecuteResult{}, err
+	}
+	return e.Execute(ctx, job, mcpServers, opts)
+}
+
+// Answer sends an answer with the backend selected by job.Executor.
+func Answer(ctx context.Context, job db.Job, mcpServers []db.MCPServer, answer string, opts Options) (ExecuteResult, error) {
+	e, err := Lookup(job.Executor)
+	if err != nil {
+		return ExecuteResult{}, err
+	}
+	return e.Answer(ctx, job, mcpServers, answer, opts)
+}
+
+// FollowUp sends a follow-up message with the backend selected by
+// job.Executor.
+func FollowUp(ctx context.Context, job db.Job, mcpServers []db.MCPServer, message string, fork bool, opts Options) (ExecuteResult, error) {
+	e, err := Lookup(job.Executor)
+	if err != nil {
+		return ExecuteResult{}, err
+	}
+	return e.FollowUp(ctx, job, mcpServers, message, fork, opts)
+}
+
+// claudeExecutor runs jobs with the Claude Code CLI.
+type claudeExecutor struct{}
+
+func (claudeExecutor) Execute(ctx context.Context, job db.Job, mcpServers []db.MCPServer, opts Options) (ExecuteResult, error) {
+	return ClaudeExecute(ctx, job, mcpServers, opts)
+}
+
+func (claudeExecutor) Answer(ctx context.Context, job db.Job, mcpServers []db.MCPServer, answer string, opts Options) (ExecuteResult, error) {
+	return ClaudeAnswer(ctx, job, mcpServers, answer, opts)
+}
+
+func (claudeExecutor) FollowUp(ctx context.Context, job db.Job, mcpServers []db.MCPServer, message string, fork bool, opts Options) (ExecuteResult, error) {
+	return ClaudeFollowUp(ctx, job, mcpServers, message, fork, opts)
+}
+
+func (claudeExecutor) Sessions() bool { return true }
+
+// errNoSessions is returned by backends without conversations when asked to
+// continue one.
+func errNoSessions(backend string) error {
+	return fmt.Errorf("the %s executor does not keep conversations", backend)
+}
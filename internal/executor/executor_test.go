@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stubExecutor records the prompt it was asked to run.
+type stubExecutor struct {
+	prompts []string
+}
+
+func (s *stubExecutor) Execute(_ context.Context, job db.Job, _ []db.MCPServer, _ Options) (ExecuteResult, error) {
+	s.prompts = append(s.prompts, job.Prompt)
+	return ExecuteResult{Transcript: "stub"}, nil
+}
+
+func (s *stubExecutor) Answer(context.Context, db.Job, []db.MCPServer, string, Options) (ExecuteResult, error) {
+	return ExecuteResult{}, errNoSessions("stub")
+}
+
+func (s *stubExecutor) FollowUp(context.Context, db.Job, []db.MCPServer, string, bool, Options) (ExecuteResult, error) {
+	return ExecuteResult{}, errNoSessions("stub")
+}
+
+func (s *stubExecutor) Sessions() bool { return false }
+
+func registerStub(t *testing.T, name string) *stubExecutor {
+	t.Helper()
+	stub := &stubExecutor{}
+	Register(name, stub)
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, name)
+		registryMu.Unlock()
+	})
+	return stub
+}
+
+func TestLookup(t *testing.T) {
+	e, err := Lookup("")
+	require.NoError(t, err)
+	require.IsType(t, claudeExecutor{}, e)
+
+	_, err = Lookup("nope")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown executor")
+}
+
+func TestNames(t *testing.T) {
+	require.Equal(t, []string{BackendAPI, BackendClaude, BackendShell}, Names())
+}
+
+func TestUsesSessions(t *testing.T) {
+	require.True(t, UsesSessions(""))
+	require.True(t, UsesSessions(BackendClaude))
+	require.False(t, UsesSessions(BackendShell))
+	require.False(t, UsesSessions(BackendAPI))
+	require.False(t, UsesSessions("nope"))
+}
+
+func TestExecuteDispatchesByJobExecutor(t *testing.T) {
+	stub := registerStub(t, "stub")
+
+	result, err := Execute(context.Background(), db.Job{Executor: "stub", Prompt: "hello"}, nil, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "stub", result.Transcript)
+	require.Equal(t, []string{"hello"}, stub.prompts)
+
+	_, err = Answer(context.Background(), db.Job{Executor: "stub"}, nil, "yes", Options{})
+	require.Error(t, err)
+
+	_, err = Execute(context.Background(), db.Job{Executor: "missing"}, nil, Options{})
+	require.Error(t, err)
+}
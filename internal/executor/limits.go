@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"fmt"
+	"time"
+
+	"claude-schedule/internal/db"
+)
+
+// limitPollInterval is how often the CPU time or memory used by a run with
+// limits is checked where the system does not enforce them itself.
+var limitPollInterval = time.Second
+
+// LimitError reports that a run failed because it hit one of its job's
+// resource limits.
+type LimitError struct {
+	Reason string // db.FailureCPULimit, db.FailureMemoryLimit or db.FailureProcessLimit
+	Limit  int    // the limit, in the unit of the matching db.Job field
+	Err    error  // how the run failed
+}
+
+func (e *LimitError) Error() string {
+	var hit string
+	switch e.Reason {
+	case db.FailureCPULimit:
+		hit = fmt.Sprintf("used up its CPU time limit of %s", time.Duration(e.Limit)*time.Second)
+	case db.FailureMemoryLimit:
+		hit = fmt.Sprintf("ran out of memory at its limit of %d MB", e.Limit)
+	case db.FailureProcessLimit:
+		hit = fmt.Sprintf("reached its limit of %d processes", e.Limit)
+	}
+	return fmt.Sprintf("the job %s\n\n%v", hit, e.Err)
+}
+
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// hasLimits reports whether job sets any resource limit.
+func hasLimits(job db.Job) bool {
+	return job.CPULimit > 0 || job.MemoryLimit > 0 || job.ProcessLimit > 0
+}
+
+// limitError wraps err, the failure of a run of job, in a LimitError when
+// reason names the limit that was hit.
+func limitError(job db.Job, reason string, err error) error {
+	limit := map[string]int{
+		db.FailureCPULimit:     job.CPULimit,
+		db.FailureMemoryLimit:  job.MemoryLimit,
+		db.FailureProcessLimit: job.ProcessLimit,
+	}
+	if _, ok := limit[reason]; !ok {
+		return err
+	}
+	return &LimitError{Reason: reason, Limit: limit[reason], Err: err}
+}
@@ -0,0 +1,326 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"claude-schedule/internal/db"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupFS is where the cgroup v2 hierarchy is mounted.
+const cgroupFS = "/sys/fs/cgroup"
+
+var (
+	cgroupOnce   sync.Once
+	cgroupParent string
+	cgroupErr    error
+)
+
+// runCgroupParent returns the cgroup that holds a child cgroup per run with
+// resource limits, setting it up on first use.
+func runCgroupParent() (string, error) {
+	cgroupOnce.Do(func() { cgroupParent, cgroupErr = setupCgroups() })
+	return cgroupParent, cgroupErr
+}
+
+// setupCgroups prepares the app's own cgroup to hold the cgroups of runs.
+// cgroup v2 only lets a cgroup without processes hand controllers to its
+// children, so the app moves into a child of its own. It only does so in a
+// cgroup delegated to it, as systemd marks a scope it may manage itself, and
+// it must be alone there.
+func setupCgroups() (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupFS, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not mounted at %s", cgroupFS)
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	var own string
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			own = filepath.Join(cgroupFS, path)
+		}
+	}
+	if own == "" {
+		return "", fmt.Errorf("the app is not in a cgroup v2 cgroup")
+	}
+	if !delegated(own) {
+		return "", fmt.Errorf("the app's cgroup %s is not delegated to it", own)
+	}
+
+	controllers, err := os.ReadFile(filepath.Join(own, "cgroup.controllers"))
+	if err != nil {
+		return "", err
+	}
+	for _, name := range []string{"memory", "pids"} {
+		if !slices.Contains(strings.Fields(string(controllers)), name) {
+			return "", fmt.Errorf("the %s controller is not available in %s", name, own)
+		}
+	}
+	procs, err := os.ReadFile(filepath.Join(own, "cgroup.procs"))
+	if err != nil {
+		return "", err
+	}
+	if pids := strings.Fields(string(procs)); len(pids) != 1 || pids[0] != strconv.Itoa(os.Getpid()) {
+		return "", fmt.Errorf("other processes share the app's cgroup %s", own)
+	}
+
+	app := filepath.Join(own, "app")
+	if err := os.Mkdir(app, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(app, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+		return "", err
+	}
+	log.Printf("executor: moved the app into %s to hold the cgroups of runs with resource limits", app)
+	if err := os.WriteFile(filepath.Join(own, "cgroup.subtree_control"), []byte("+memory +pids"), 0); err != nil {
+		return "", err
+	}
+	return own, nil
+}
+
+// delegated reports whether systemd delegated the cgroup at path to the
+// processes in it.
+func delegated(path string) bool {
+	for _, attr := range []string{"trusted.delegate", "user.delegate"} {
+		value := make([]byte, 8)
+		if n, err := unix.Getxattr(path, attr, value); err == nil && string(value[:n]) == "1" {
+			return true
+		}
+	}
+	return false
+}
+
+// limits enforces a job's resource limits on the process tree of one run. It
+// puts the tree in a cgroup of its own when cgroup v2 can be used. Otherwise
+// the CLI starts with a CPU time rlimit, which its children inherit, and the
+// memory the tree holds is watched. An rlimit applies to each process alone,
+// and only tells that the CPU limit was hit when the CLI itself hit it; no
+// rlimit caps memory the way a cgroup does, since Node reserves far more
+// address space than it uses. The number of processes of a tree can only be
+// capped with a cgroup.
+type limits struct {
+	job    db.Job
+	cgroup string   // the run's cgroup, empty when rlimits stand in for it
+	dir    *os.File // the open cgroup, which the command starts in
+	cpuHit atomic.Bool
+	memHit atomic.Bool
+	done   chan struct{}
+}
+
+// newLimits prepares the limits of a run of job.
+func newLimits(job db.Job) (*limits, error) {
+	l := &limits{job: job, done: make(chan struct{})}
+	parent, err := runCgroupParent()
+	if err != nil {
+		if job.ProcessLimit > 0 {
+			return nil, fmt.Errorf("a process limit needs cgroup v2: %w", err)
+		}
+		return l, nil
+	}
+
+	if l.cgroup, err = os.MkdirTemp(parent, "run-"); err != nil {
+		return nil, err
+	}
+	set := func(file, value string) error {
+		return os.WriteFile(filepath.Join(l.cgroup, file), []byte(value), 0)
+	}
+	if job.MemoryLimit > 0 {
+		if err := set("memory.max", strconv.FormatInt(int64(job.MemoryLimit)<<20, 10)); err != nil {
+			l.close()
+			return nil, err
+		}
+		// Swapping would only stretch out running out of memory. When it
+		// happens the whole tree is killed rather than whichever process
+		// the kernel picks.
+		set("memory.swap.max", "0")
+		set("memory.oom.group", "1")
+	}
+	if job.ProcessLimit > 0 {
+		if err := set("pids.max", strconv.Itoa(job.ProcessLimit)); err != nil {
+			l.close()
+			return nil, err
+		}
+	}
+	if l.dir, err = os.Open(l.cgroup); err != nil {
+		l.close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// prepare makes cmd start in the run's cgroup or, without one, under its CPU
+// time rlimit.
+func (l *limits) prepare(cmd *exec.Cmd) {
+	if l.dir == nil {
+		if l.job.CPULimit > 0 {
+			// A shell sets the rlimit and then becomes the command, so it is
+			// in place before the command runs: SIGXCPU at the limit, SIGKILL
+			// a little after.
+			script := fmt.Sprintf(`ulimit -S -t %d && ulimit -H -t %d && exec "$@"`, l.job.CPULimit, l.job.CPULimit+5)
+			cmd.Args = append([]string{"sh", "-c", script, "sh", cmd.Path}, cmd.Args[1:]...)
+			cmd.Path = "/bin/sh"
+		}
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(l.dir.Fd())
+}
+
+// start watches the run of the started process pid for the limits that
+// neither its cgroup nor its rlimits enforce: the CPU time of a cgroup and
+// the memory of a tree without one. stop is called to end a run that used up
+// either.
+func (l *limits) start(pid int, stop func()) error {
+	cpu := l.cgroup != "" && l.job.CPULimit > 0
+	mem := l.cgroup == "" && l.job.MemoryLimit > 0
+	if !cpu && !mem {
+		return nil
+	}
+	go func() {
+		ticker := time.NewTicker(limitPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+			}
+			if cpu {
+				used, ok := l.stat("cpu.stat", "usage_usec")
+				if ok && time.Duration(used)*time.Microsecond >= time.Duration(l.job.CPULimit)*time.Second {
+					l.cpuHit.Store(true)
+					stop()
+					return
+				}
+			}
+			if mem && treeMemory(pid) >= int64(l.job.MemoryLimit)<<20 {
+				l.memHit.Store(true)
+				stop()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// treeMemory returns the resident memory, in bytes, of the process pid and
+// its descendants.
+func treeMemory(pid int) int64 {
+	var total int64
+	pending := []int{pid}
+	for len(pending) > 0 {
+		p := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if statm, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", p)); err == nil {
+			if fields := strings.Fields(string(statm)); len(fields) > 1 {
+				pages, _ := strconv.ParseInt(fields[1], 10, 64)
+				total += pages * int64(os.Getpagesize())
+			}
+		}
+		// Any thread may have started children.
+		tasks, _ := filepath.Glob(fmt.Sprintf("/proc/%d/task/*/children", p))
+		for _, task := range tasks {
+			data, err := os.ReadFile(task)
+			if err != nil {
+				continue
+			}
+			for _, field := range strings.Fields(string(data)) {
+				if child, err := strconv.Atoi(field); err == nil {
+					pending = append(pending, child)
+				}
+			}
+		}
+	}
+	return total
+}
+
+// exceeded returns the db.Failure constant of the limit the run hit, or ""
+// if it hit none that can be told. state is how the CLI exited.
+func (l *limits) exceeded(state *os.ProcessState) string {
+	if l.cgroup == "" {
+		if l.memHit.Load() {
+			return db.FailureMemoryLimit
+		}
+		if l.job.CPULimit == 0 || state == nil {
+			return ""
+		}
+		// SIGXCPU, or SIGKILL for a CLI that ignored it.
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() && ws.Signal() == syscall.SIGXCPU ||
+			state.UserTime()+state.SystemTime() >= time.Duration(l.job.CPULimit)*time.Second {
+			return db.FailureCPULimit
+		}
+		return ""
+	}
+	if l.cpuHit.Load() {
+		return db.FailureCPULimit
+	}
+	if n, _ := l.stat("memory.events", "oom_kill"); n > 0 && l.job.MemoryLimit > 0 {
+		return db.FailureMemoryLimit
+	}
+	if n, _ := l.stat("pids.events", "max"); n > 0 {
+		return db.FailureProcessLimit
+	}
+	return ""
+}
+
+// stat reads the value of key from a flat-keyed file of the run's cgroup.
+func (l *limits) stat(file, key string) (int64, bool) {
+	data, err := os.ReadFile(filepath.Join(l.cgroup, file))
+	if err != nil {
+		return 0, false
+	}
+	return cgroupValue(data, key)
+}
+
+// cgroupValue returns the value of key in the contents of a flat-keyed cgroup
+// file such as memory.events.
+func cgroupValue(data []byte, key string) (int64, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), " ")
+		if ok && name == key {
+			n, err := strconv.ParseInt(value, 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// close stops watching the run and removes its cgroup, killing whatever is
+// still running in it.
+func (l *limits) close() {
+	close(l.done)
+	if l.dir != nil {
+		l.dir.Close()
+	}
+	if l.cgroup == "" {
+		return
+	}
+	os.WriteFile(filepath.Join(l.cgroup, "cgroup.kill"), []byte("1"), 0)
+	// The cgroup can only be removed once the killed processes are gone.
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if err := os.Remove(l.cgroup); err == nil || time.Now().After(deadline) {
+			return
+		}
+	}
+}
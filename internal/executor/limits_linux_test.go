@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+// scriptClaude makes script the claude binary.
+func scriptClaude(t *testing.T, script string) {
+	t.Helper()
+	claude := filepath.Join(t.TempDir(), "claude")
+	require.NoError(t, os.WriteFile(claude, []byte(script), 0o755))
+	withSettings(t, db.Settings{ClaudePath: claude})
+}
+
+func TestCgroupValue(t *testing.T) {
+	events := []byte("low 0\nhigh 3\nmax 12\noom 1\noom_kill 1\n")
+	n, ok := cgroupValue(events, "oom_kill")
+	require.True(t, ok)
+	require.EqualValues(t, 1, n)
+	n, ok = cgroupValue(events, "max")
+	require.True(t, ok)
+	require.EqualValues(t, 12, n)
+	_, ok = cgroupValue(events, "oom_group_kill")
+	require.False(t, ok)
+}
+
+func TestClaudeExecute_CPULimit(t *testing.T) {
+	prev := limitPollInterval
+	limitPollInterval = 50 * time.Millisecond
+	t.Cleanup(func() { limitPollInterval = prev })
+	scriptClaude(t, "#!/bin/sh\nwhile :; do :; done\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	job := db.Job{Prompt: "x", WorkingDir: t.TempDir(), CPULimit: 1}
+	_, err := ClaudeExecute(ctx, job, nil, Options{})
+
+	var limitErr *LimitError
+	require.True(t, errors.As(err, &limitErr), "got %v", err)
+	require.Equal(t, db.FailureCPULimit, limitErr.Reason)
+	require.ErrorContains(t, err, "CPU time limit of 1s")
+	require.NoError(t, ctx.Err(), "the run should stop at the limit")
+}
+
+func TestClaudeExecute_MemoryLimitWithoutCgroups(t *testing.T) {
+	if _, err := runCgroupParent(); err == nil {
+		t.Skip("cgroup v2 is available")
+	}
+	prev := limitPollInterval
+	limitPollInterval = 50 * time.Millisecond
+	t.Cleanup(func() { limitPollInterval = prev })
+	// tail holds the one endless line in memory.
+	scriptClaude(t, "#!/bin/sh\nhead -c 2000000000 /dev/zero | tail -n 1\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	job := db.Job{Prompt: "x", WorkingDir: t.TempDir(), MemoryLimit: 32}
+	_, err := ClaudeExecute(ctx, job, nil, Options{})
+
+	var limitErr *LimitError
+	require.True(t, errors.As(err, &limitErr), "got %v", err)
+	require.Equal(t, db.FailureMemoryLimit, limitErr.Reason)
+	require.NoError(t, ctx.Err(), "the run should stop at the limit")
+}
+
+func TestClaudeExecute_FailureWithinLimits(t *testing.T) {
+	scriptClaude(t, "#!/bin/sh\necho broken >&2\nexit 1\n")
+
+	job := db.Job{Prompt: "x", WorkingDir: t.TempDir(), CPULimit: 60, MemoryLimit: 1024}
+	_, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.ErrorContains(t, err, "broken")
+	var limitErr *LimitError
+	require.False(t, errors.As(err, &limitErr))
+}
+
+func TestClaudeExecute_ProcessLimitNeedsCgroups(t *testing.T) {
+	if _, err := runCgroupParent(); err == nil {
+		t.Skip("cgroup v2 is available")
+	}
+	scriptClaude(t, "#!/bin/sh\nexit 0\n")
+
+	job := db.Job{Prompt: "x", WorkingDir: t.TempDir(), ProcessLimit: 50}
+	_, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.ErrorContains(t, err, "a process limit needs cgroup v2")
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"claude-schedule/internal/db"
+)
+
+// limits would enforce a job's resource limits, which are only supported on
+// Linux.
+type limits struct{}
+
+func newLimits(job db.Job) (*limits, error) {
+	return nil, fmt.Errorf("resource limits need Linux")
+}
+
+func (l *limits) prepare(cmd *exec.Cmd) {}
+
+func (l *limits) start(pid int, stop func()) error { return nil }
+
+func (l *limits) exceeded(state *os.ProcessState) string { return "" }
+
+func (l *limits) close() {}
@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"regexp"
+	"strings"
+
+	"claude-schedule/internal/db"
+)
+
+// OutcomePrompt asks for the marker DetectOutcome looks for. It is added to
+// the system prompt of jobs with ReportOutcome set, and only those jobs are
+// checked for the marker; shell jobs can print it themselves.
+const OutcomePrompt = `When you are done, say what came of this run on a line of its own in your final reply:
+"OUTCOME: changed" if you found or made a change worth telling the user about,
+"OUTCOME: unchanged" if you checked and found nothing new, or
+"OUTCOME: no-op" if there was nothing to do.`
+
+// outcomeMarker matches an outcome line, allowing for Markdown emphasis or
+// code around it.
+var outcomeMarker = regexp.MustCompile("(?im)^[\\s*_`>]*outcome[*_`]*\\s*:\\s*[*_`]*(changed|unchanged|no[-_ ]?op)\\b")
+
+// DetectOutcome returns the outcome a run's final result reports with an
+// "OUTCOME: ..." line, as one of the db.Outcome constants, or "" if it reports
+// none. The last marker wins.
+func DetectOutcome(summary string) string {
+	matches := outcomeMarker.FindAllStringSubmatch(summary, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	switch word := strings.ToLower(matches[len(matches)-1][1]); word {
+	case "changed":
+		return db.OutcomeChanged
+	case "unchanged":
+		return db.OutcomeUnchanged
+	default:
+		return db.OutcomeNoOp
+	}
+}
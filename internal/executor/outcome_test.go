@@ -0,0 +1,34 @@
+package executor
+
+import (
+	"testing"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectOutcome(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string
+		want    string
+	}{
+		{"none", "The pricing page still lists three plans.", ""},
+		{"changed", "A fourth plan was added.\n\nOUTCOME: changed", db.OutcomeChanged},
+		{"unchanged", "Nothing new.\noutcome: Unchanged", db.OutcomeUnchanged},
+		{"no-op", "The feed was empty.\n\nOUTCOME: no-op", db.OutcomeNoOp},
+		{"noop", "OUTCOME: noop", db.OutcomeNoOp},
+		{"emphasis", "Done.\n\n**OUTCOME: changed**", db.OutcomeChanged},
+		{"code", "Done.\n`OUTCOME: unchanged`", db.OutcomeUnchanged},
+		{"before a JSON result", "OUTCOME: changed\n\n```json\n{\"plans\": 4}\n```", db.OutcomeChanged},
+		{"last wins", "I'll end with OUTCOME: changed if so.\nOUTCOME: unchanged\nOUTCOME: changed", db.OutcomeChanged},
+		{"mid-sentence", "I was told to report an outcome: changed or not.", ""},
+		{"unknown word", "OUTCOME: maybe", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, DetectOutcome(tt.summary))
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package executor
+
+import "time"
+
+// killGrace is how long the processes of a cancelled command get to exit
+// after being asked to before they are killed.
+var killGrace = 5 * time.Second
@@ -2,7 +2,85 @@
 
 package executor
 
-import "os/exec"
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
 
 // hideWindow is a no-op on non-Windows platforms.
 func hideWindow(_ *exec.Cmd) {}
+
+// startProcessTree starts cmd in a process group of its own. Cancelling the
+// command's context then sends SIGTERM to the whole group, so the MCP servers
+// and commands it started stop with it, and SIGKILL to whatever is left after
+// killGrace. A command run by bwrap is outside the group and would be killed
+// at once if bwrap were stopped, so SIGTERM goes to it alone; the SIGKILL to
+// bwrap then ends the whole sandbox. Call release once the command has been
+// waited for.
+func startProcessTree(cmd *exec.Cmd) (release func(), err error) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	// The group is killed by a timer or, if that has not fired yet, by
+	// release. Once release has run the group ID may belong to someone else.
+	var mu sync.Mutex
+	var released, cancelled bool
+	var timer *time.Timer
+	kill := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !released {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+	release = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if released {
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		// Whatever a cancelled command left running goes with it.
+		if cancelled {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		released = true
+	}
+
+	cmd.Cancel = func() error {
+		mu.Lock()
+		cancelled = true
+		timer = time.AfterFunc(killGrace, kill)
+		mu.Unlock()
+		target := -cmd.Process.Pid
+		if pid := sandboxedPid(cmd.Process.Pid); pid != 0 {
+			target = pid
+		}
+		err := syscall.Kill(target, syscall.SIGTERM)
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	// Wait gives up on output still held open by stray processes a little
+	// after they should have been killed.
+	cmd.WaitDelay = killGrace + time.Second
+	if err := cmd.Start(); err != nil {
+		return func() {}, err
+	}
+	return release, nil
+}
+
+// shellCommand returns the program and arguments that run script in the
+// system shell.
+func shellCommand(script string) (string, []string) {
+	return "sh", []string{"-c", script}
+}
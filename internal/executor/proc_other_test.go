@@ -0,0 +1,133 @@
+//go:build !windows
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+// alive reports whether the process pid is still running. Zombies no one has
+// reaped yet count as gone.
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	_, rest, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(rest, "Z")
+}
+
+func TestClaudeExecute_CancelKillsProcessTree(t *testing.T) {
+	prev := killGrace
+	killGrace = 200 * time.Millisecond
+	t.Cleanup(func() { killGrace = prev })
+
+	// A CLI that starts a child ignoring SIGTERM and another running a
+	// grandchild, then waits for them.
+	dir := t.TempDir()
+	script := `#!/bin/sh
+cd "$(dirname "$0")"
+trap 'touch terminated; exit 143' TERM
+sh -c 'trap "" TERM; echo $$ > stubborn; while :; do sleep 1; done' &
+sh -c 'sleep 300 & echo $! > grandchild; wait' &
+echo '{"type":"system","subtype":"init","session_id":"s"}'
+wait
+`
+	claude := filepath.Join(dir, "claude")
+	require.NoError(t, os.WriteFile(claude, []byte(script), 0o755))
+	withSettings(t, db.Settings{ClaudePath: claude})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := ClaudeExecute(ctx, db.Job{Prompt: "x", WorkingDir: t.TempDir()}, nil, Options{})
+	require.Error(t, err)
+
+	var pids []int
+	for _, name := range []string{"stubborn", "grandchild"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		require.NoError(t, err)
+		pids = append(pids, pid)
+	}
+	require.Eventually(t, func() bool {
+		for _, pid := range pids {
+			if alive(pid) {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 20*time.Millisecond)
+	require.FileExists(t, filepath.Join(dir, "terminated"))
+}
+
+func TestStartProcessTree_SignalsSandboxedCommand(t *testing.T) {
+	// Stands in for bwrap: runs the command in a session of its own and waits
+	// for it.
+	dir := t.TempDir()
+	bwrap := filepath.Join(dir, "bwrap")
+	require.NoError(t, os.WriteFile(bwrap, []byte("#!/bin/sh\nsetsid \"$@\" &\nwait\n"), 0o755))
+	command := `trap 'touch terminated; exit 0' TERM; touch ready; while :; do sleep 0.1; done`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bwrap, "sh", "-c", command)
+	cmd.Dir = dir
+	release, err := startProcessTree(cmd)
+	require.NoError(t, err)
+	defer release()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "ready"))
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+
+	start := time.Now()
+	cancel()
+	cmd.Wait()
+	require.FileExists(t, filepath.Join(dir, "terminated"), "the sandboxed command is asked to exit")
+	require.Less(t, time.Since(start), killGrace, "it exits without being killed")
+}
+
+func TestStartProcessTree_ReleaseKillsWhatCancelLeft(t *testing.T) {
+	// The command exits on SIGTERM and leaves a child ignoring it, which
+	// holds none of its output open.
+	dir := t.TempDir()
+	command := `trap 'exit 0' TERM; sh -c 'trap "" TERM; echo $$ > stubborn; while :; do sleep 0.1; done' >/dev/null 2>&1 & while :; do sleep 0.1; done`
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	release, err := startProcessTree(cmd)
+	require.NoError(t, err)
+	var pid int
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(filepath.Join(dir, "stubborn"))
+		pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil && pid != 0
+	}, 5*time.Second, 20*time.Millisecond)
+
+	start := time.Now()
+	cancel()
+	cmd.Wait()
+	require.True(t, alive(pid))
+	release()
+	require.Eventually(t, func() bool { return !alive(pid) }, time.Second, 10*time.Millisecond)
+	require.Less(t, time.Since(start), killGrace, "it is killed without waiting for the timer")
+	release()
+}
@@ -2,7 +2,12 @@ package executor
 
 import (
 	"os/exec"
+	"sync"
 	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
 )
 
 // hideWindow configures the command to run without a visible console window.
@@ -11,3 +16,154 @@ func hideWindow(cmd *exec.Cmd) {
 		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
 	}
 }
+
+// startProcessTree starts cmd in a job object of its own. The command starts
+// suspended and only runs once it is in the job, so every process it starts
+// is in the job too. Cancelling the command's context sends CTRL_BREAK to its
+// console process group and terminates every process in the job after
+// killGrace, so the MCP servers and commands it started stop with it. Call
+// release once the command has been waited for.
+func startProcessTree(cmd *exec.Cmd) (release func(), err error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		// Fall back to stopping just the command.
+		return func() {}, cmd.Start()
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED | windows.CREATE_NEW_PROCESS_GROUP
+
+	// The job handle is closed by release, which may come before the timer
+	// that terminates the job fires.
+	var mu sync.Mutex
+	var closed, cancelled bool
+	var timer *time.Timer
+	terminate := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			windows.TerminateJobObject(job, 1)
+		}
+	}
+	release = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		// Whatever a cancelled command left running goes with it.
+		if cancelled && !closed {
+			windows.TerminateJobObject(job, 1)
+		}
+		closed = true
+		windows.CloseHandle(job)
+	}
+
+	cmd.Cancel = func() error {
+		mu.Lock()
+		cancelled = true
+		mu.Unlock()
+		if err := interruptConsole(cmd.Process.Pid); err != nil {
+			// It cannot be asked to exit, so it is stopped at once.
+			terminate()
+			return nil
+		}
+		mu.Lock()
+		timer = time.AfterFunc(killGrace, terminate)
+		mu.Unlock()
+		return nil
+	}
+	// Wait gives up on output still held open by stray processes a little
+	// after they should have been terminated.
+	cmd.WaitDelay = killGrace + time.Second
+
+	if err := cmd.Start(); err != nil {
+		release()
+		return func() {}, err
+	}
+	if err := assignAndResume(job, cmd.Process.Pid); err != nil {
+		// A process that cannot be tracked is not let run.
+		cmd.Process.Kill()
+		cmd.Wait()
+		release()
+		return func() {}, err
+	}
+	return release, nil
+}
+
+// assignAndResume puts the suspended process pid in job and then lets it run.
+func assignAndResume(job windows.Handle, pid int) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(process)
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		return err
+	}
+	return resumeThreads(pid)
+}
+
+// resumeThreads resumes the threads of process pid. A process created
+// suspended has only its main thread.
+func resumeThreads(pid int) error {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(snapshot)
+
+	var entry windows.ThreadEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = windows.Thread32First(snapshot, &entry); err == nil; err = windows.Thread32Next(snapshot, &entry) {
+		if entry.OwnerProcessID != uint32(pid) {
+			continue
+		}
+		thread, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, entry.ThreadID)
+		if err != nil {
+			return err
+		}
+		_, err = windows.ResumeThread(thread)
+		windows.CloseHandle(thread)
+		if err != nil {
+			return err
+		}
+	}
+	if err == windows.ERROR_NO_MORE_FILES {
+		return nil
+	}
+	return err
+}
+
+var (
+	kernel32          = windows.NewLazySystemDLL("kernel32.dll")
+	procAttachConsole = kernel32.NewProc("AttachConsole")
+	procFreeConsole   = kernel32.NewProc("FreeConsole")
+
+	// consoleMu serializes interruptConsole, which attaches the app to
+	// another process's console for the moment.
+	consoleMu sync.Mutex
+)
+
+// interruptConsole sends CTRL_BREAK to the process group of pid, the way
+// Ctrl+Break in a terminal asks a program to exit. The command runs in a
+// hidden console of its own, and only a process attached to a console can
+// signal it, so the app attaches to it for the moment. This fails when the
+// app already has a console of its own.
+func interruptConsole(pid int) error {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	if r, _, err := procAttachConsole.Call(uintptr(pid)); r == 0 {
+		return err
+	}
+	defer procFreeConsole.Call()
+	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(pid))
+}
+
+// shellCommand returns the program and arguments that run script in the
+// system shell.
+func shellCommand(script string) (string, []string) {
+	return "cmd", []string{"/C", script}
+}
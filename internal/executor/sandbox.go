@@ -0,0 +1,531 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/transcript"
+)
+
+// A sandboxed run sees the whole file system read-only, home included, except
+// for the job's directories, the CLI's session state and a scratch directory
+// that TMPDIR points at; the CLI's state file is a copy of its own. With a
+// restricted network it has no network of its own: the app re-executes itself
+// inside the sandbox as a forwarder (see SandboxMain) that relays the CLI's
+// proxy connections over a Unix socket to a proxy in the app, which only lets
+// allowed hosts through.
+
+// sandboxProxyEnv names the variable that makes SandboxMain act as the
+// forwarder; it holds the path of the proxy's socket.
+const sandboxProxyEnv = "CLAUDE_SCHEDULE_SANDBOX_PROXY"
+
+// sandboxEvent is the line recorded in a run's stream for what the sandbox
+// blocked. The CLI never prints it; see sandboxLine.
+const sandboxEvent = "sandbox"
+
+// sandboxAPIHosts are reachable from every restricted sandbox, so the CLI can
+// talk to the API and refresh its login.
+var sandboxAPIHosts = []string{"anthropic.com", "*.anthropic.com", "claude.ai", "*.claude.ai"}
+
+// maxSandboxReports caps how many blocked actions a run reports.
+const maxSandboxReports = 20
+
+// sandboxMarkers are messages tools print when the sandbox stops them. The
+// network ones only count with a restricted network.
+var sandboxMarkers = []struct {
+	text       string
+	restricted bool
+}{
+	{"Read-only file system", false},
+	{"Could not resolve host", true},
+	{"Temporary failure in name resolution", true},
+	{"Network is unreachable", true},
+	{"getaddrinfo ENOTFOUND", true},
+	{"getaddrinfo EAI_AGAIN", true},
+}
+
+// sandbox is the bubblewrap sandbox of one CLI invocation.
+type sandbox struct {
+	job     db.Job
+	scratch string        // writable directory TMPDIR points at
+	proxy   *sandboxProxy // nil unless the network is restricted
+}
+
+// newSandbox prepares the sandbox for an invocation of job with the
+// environment env. Close it once the invocation has ended.
+func newSandbox(job db.Job, env []string) (*sandbox, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("sandboxed runs need Linux")
+	}
+	if _, err := exec.LookPath("bwrap"); err != nil {
+		return nil, fmt.Errorf("bubblewrap (bwrap) is not installed")
+	}
+	scratch, err := os.MkdirTemp("", "claude-sandbox-*")
+	if err != nil {
+		return nil, err
+	}
+	sb := &sandbox{job: job, scratch: scratch}
+	if job.SandboxNetwork == "restricted" {
+		hosts, err := job.SandboxHostList()
+		if err != nil {
+			sb.close()
+			return nil, err
+		}
+		allowed := append(append([]string{}, sandboxAPIHosts...), hosts...)
+		if u, err := url.Parse(envValue(env, "ANTHROPIC_BASE_URL")); err == nil && u.Hostname() != "" {
+			allowed = append(allowed, u.Hostname())
+		}
+		if sb.proxy, err = startSandboxProxy(filepath.Join(scratch, "proxy.sock"), allowed); err != nil {
+			sb.close()
+			return nil, fmt.Errorf("starting sandbox proxy: %w", err)
+		}
+	}
+	return sb, nil
+}
+
+// close stops the proxy and removes the scratch directory.
+func (sb *sandbox) close() {
+	if sb.proxy != nil {
+		sb.proxy.close()
+	}
+	os.RemoveAll(sb.scratch)
+}
+
+// wrap returns the program, arguments and environment that run bin with args
+// inside the sandbox.
+func (sb *sandbox) wrap(bin string, args, env []string) (string, []string, []string, error) {
+	argv := []string{
+		"--die-with-parent", "--new-session",
+		"--unshare-pid", "--unshare-ipc", "--unshare-uts", "--unshare-cgroup-try",
+	}
+	if sb.proxy != nil {
+		argv = append(argv, "--unshare-net")
+	}
+	argv = append(argv, "--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc")
+	for _, dir := range sb.writable(env) {
+		argv = append(argv, "--bind", dir, dir)
+	}
+	copied, state, err := sb.stateCopy(env)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if state != "" {
+		argv = append(argv, "--bind", copied, state)
+	}
+	argv = append(argv, "--chdir", sb.job.WorkingDir, "--setenv", "TMPDIR", sb.scratch, "--")
+
+	if sb.proxy != nil {
+		self, err := os.Executable()
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("locating the app for the sandbox proxy: %w", err)
+		}
+		argv = append(argv, self)
+		env = append(env, sandboxProxyEnv+"="+sb.proxy.socket)
+	}
+	argv = append(argv, bin)
+	return "bwrap", append(argv, args...), env, nil
+}
+
+// sandboxedPid returns, when pid is bwrap, the process it runs in its
+// sandbox: the first of its descendants that is not bwrap itself. It returns
+// 0 if pid is not bwrap or has started nothing yet. bwrap starts the process
+// in a session of its own and does not pass signals on, so a cancelled run
+// asks it to exit directly.
+func sandboxedPid(pid int) int {
+	if processName(pid) != "bwrap" {
+		return 0
+	}
+	// bwrap's own processes: the one started and, with a PID namespace, the
+	// one that is PID 1 inside it.
+	for range 3 {
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/task/%d/children", pid, pid))
+		if err != nil {
+			return 0
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return 0
+		}
+		if pid, err = strconv.Atoi(fields[0]); err != nil {
+			return 0
+		}
+		if name := processName(pid); name != "bwrap" && name != "" {
+			return pid
+		}
+	}
+	return 0
+}
+
+// processName returns the name of the program process pid runs, or "" if
+// it cannot be read.
+func processName(pid int) string {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
+
+// cliStateEntries are the entries of the CLI's config directory that it
+// writes on every run: session transcripts, which a resumed session reads
+// back, and the login, which it refreshes, among them. The rest of the
+// directory, such as settings.json with its hooks and permissions, stays
+// read-only, so a sandboxed run cannot change what later runs do.
+var cliStateEntries = []string{
+	"projects", "todos", "shell-snapshots", "statsig", "session-env", "file-history",
+	".credentials.json",
+}
+
+// writable returns the paths the sandbox may write to: the scratch
+// directory, the job's directories, the git directory of a worktree and the
+// CLI's run state. Paths that do not exist are left out, except the working
+// directory, whose absence bwrap reports.
+func (sb *sandbox) writable(env []string) []string {
+	paths := []string{sb.scratch, sb.job.WorkingDir}
+	if gitDir := worktreeGitDir(sb.job.WorkingDir); gitDir != "" {
+		paths = append(paths, gitDir)
+	}
+	var optional []string
+	addDirs, _ := sb.job.AddDirList()
+	optional = append(optional, addDirs...)
+	if configDir, _ := cliConfigPaths(env); configDir != "" {
+		for _, name := range cliStateEntries {
+			optional = append(optional, filepath.Join(configDir, name))
+		}
+	}
+	for _, path := range optional {
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// stateCopy copies the CLI's state file, which also holds per-project
+// permissions and MCP servers, into the scratch directory. It returns the
+// copy and the path to bind it over, or "" for both if there is no state
+// file. The CLI updates the copy, which goes with the sandbox.
+func (sb *sandbox) stateCopy(env []string) (string, string, error) {
+	_, state := cliConfigPaths(env)
+	if state == "" {
+		return "", "", nil
+	}
+	data, err := os.ReadFile(state)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("copying the CLI's state: %w", err)
+	}
+	copied := filepath.Join(sb.scratch, "claude.json")
+	if err := os.WriteFile(copied, data, 0o600); err != nil {
+		return "", "", fmt.Errorf("copying the CLI's state: %w", err)
+	}
+	return copied, state, nil
+}
+
+// cliConfigPaths returns the CLI's config directory and state file under env:
+// CLAUDE_CONFIG_DIR and the .claude.json in it, or ~/.claude and
+// ~/.claude.json. Both are "" if the home directory is unknown.
+func cliConfigPaths(env []string) (configDir, state string) {
+	if dir := envValue(env, "CLAUDE_CONFIG_DIR"); dir != "" {
+		return dir, filepath.Join(dir, ".claude.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", ""
+	}
+	return filepath.Join(home, ".claude"), filepath.Join(home, ".claude.json")
+}
+
+// worktreeGitDir returns the repository's git directory when dir is a linked
+// worktree, whose own .git is a file pointing into it, and "" otherwise.
+func worktreeGitDir(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, ".git"))
+	if err != nil {
+		return ""
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return ""
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	common, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return ""
+	}
+	path := strings.TrimSpace(string(common))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(gitDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// blocked returns what the sandbox stopped during an invocation: hosts the
+// proxy refused and tool output that reports a read-only file system or, with
+// a restricted network, a failed connection.
+func (sb *sandbox) blocked(events []transcript.Event) []string {
+	var reports []string
+	if sb.proxy != nil {
+		for _, host := range sb.proxy.refused() {
+			reports = append(reports, "network: "+host+" is not an allowed host")
+		}
+	}
+	seen := make(map[string]bool)
+	for _, e := range events {
+		if e.Kind != transcript.KindToolResult {
+			continue
+		}
+		for _, line := range strings.Split(e.Text, "\n") {
+			if !sb.matches(line) {
+				continue
+			}
+			report := e.Tool + ": " + clip(strings.TrimSpace(line), 200)
+			if !seen[report] {
+				seen[report] = true
+				reports = append(reports, report)
+			}
+		}
+	}
+	if len(reports) > maxSandboxReports {
+		reports = append(reports[:maxSandboxReports], fmt.Sprintf("… and %d more", len(reports)-maxSandboxReports))
+	}
+	return reports
+}
+
+// matches reports whether a line of tool output shows the sandbox at work.
+func (sb *sandbox) matches(line string) bool {
+	for _, m := range sandboxMarkers {
+		if (!m.restricted || sb.proxy != nil) && strings.Contains(line, m.text) {
+			return true
+		}
+	}
+	return false
+}
+
+// sandboxLine returns the stream line recording what a sandbox blocked.
+func sandboxLine(reports []string) string {
+	data, _ := json.Marshal(cliEvent{Type: sandboxEvent, Result: strings.Join(reports, "\n")})
+	return string(data)
+}
+
+// clip shortens s to at most n bytes without splitting a character.
+func clip(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
+
+// sandboxProxy is the HTTP proxy a restricted sandbox reaches the network
+// through. It serves on a Unix socket inside the sandbox's scratch directory.
+type sandboxProxy struct {
+	socket  string
+	allowed []string
+	server  *http.Server
+
+	mu      sync.Mutex
+	refusal []string // hosts refused, in order, without repeats
+}
+
+// startSandboxProxy serves a proxy on socket that only connects to hosts
+// matching allowed; see hostAllowed.
+func startSandboxProxy(socket string, allowed []string) (*sandboxProxy, error) {
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	p := &sandboxProxy{socket: socket, allowed: allowed}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 30 * time.Second}
+	go p.server.Serve(ln)
+	return p, nil
+}
+
+func (p *sandboxProxy) close() {
+	p.server.Close()
+}
+
+// refused returns the hosts the proxy refused so far.
+func (p *sandboxProxy) refused() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string{}, p.refusal...)
+}
+
+// allow reports whether host may be reached and records it if not.
+func (p *sandboxProxy) allow(host string) bool {
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	if hostAllowed(name, p.allowed) {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, h := range p.refusal {
+		if h == host {
+			return false
+		}
+	}
+	p.refusal = append(p.refusal, host)
+	return false
+}
+
+// ServeHTTP tunnels CONNECT requests, which carry HTTPS, and forwards plain
+// HTTP requests, to allowed hosts.
+func (p *sandboxProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "not a proxy request", http.StatusBadRequest)
+		return
+	}
+	if !p.allow(r.URL.Host) {
+		http.Error(w, "blocked by the job's sandbox", http.StatusForbidden)
+		return
+	}
+	// The request names its target already.
+	(&httputil.ReverseProxy{Rewrite: func(*httputil.ProxyRequest) {}}).ServeHTTP(w, r)
+}
+
+func (p *sandboxProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	if !p.allow(r.Host) {
+		http.Error(w, "blocked by the job's sandbox", http.StatusForbidden)
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", r.Host, 30*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	fmt.Fprint(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	// Read through buf, which may hold bytes sent right after the request.
+	go relay(upstream, buf, upstream)
+	relay(conn, upstream, conn)
+}
+
+// hostAllowed reports whether host matches one of patterns. A pattern is a
+// host name, or "*." and a domain to match the domain's subdomains.
+func hostAllowed(host string, patterns []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// relay copies src to dst, then closes closer, the side dst writes to.
+func relay(dst io.Writer, src io.Reader, closer io.Closer) {
+	io.Copy(dst, src)
+	closer.Close()
+}
+
+// SandboxMain runs the network forwarder of a sandboxed run and exits when the
+// process was started as one, and returns otherwise. Call it first thing in
+// main.
+func SandboxMain() {
+	socket := os.Getenv(sandboxProxyEnv)
+	if socket == "" {
+		return
+	}
+	os.Unsetenv(sandboxProxyEnv)
+	os.Exit(forward(socket, os.Args[1:]))
+}
+
+// forward runs command with its HTTP proxy set to a local port whose
+// connections are relayed to the proxy on socket, and returns its exit code.
+func forward(socket string, command []string) int {
+	if len(command) == 0 {
+		fmt.Fprintln(os.Stderr, "sandbox: no command to run")
+		return 2
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 2
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				upstream, err := net.Dial("unix", socket)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				go relay(upstream, conn, upstream)
+				relay(conn, upstream, conn)
+			}()
+		}
+	}()
+
+	proxy := "http://" + ln.Addr().String()
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(),
+		"HTTPS_PROXY="+proxy, "https_proxy="+proxy, "HTTP_PROXY="+proxy, "http_proxy="+proxy,
+		"NO_PROXY=localhost,127.0.0.1", "no_proxy=localhost,127.0.0.1")
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 2
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+	err = cmd.Wait()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return exit.ExitCode()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,249 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/fakeclaude"
+	"claude-schedule/internal/transcript"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBwrap puts a bwrap on PATH that records its arguments, then runs the
+// command after "--" in the directory given by --chdir. It returns a function
+// reading the arguments of the last invocation.
+func fakeBwrap(t *testing.T) func() []string {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("sandboxed runs need Linux")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+printf '%s\n' "$@" > "$(dirname "$0")/args"
+while [ "$1" != "--" ]; do
+	if [ "$1" = "--chdir" ]; then cd "$2"; shift; fi
+	shift
+done
+shift
+exec "$@"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bwrap"), []byte(script), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return func() []string {
+		data, err := os.ReadFile(filepath.Join(dir, "args"))
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+}
+
+// bound reports whether args bind path writable at the same place.
+func bound(args []string, path string) bool {
+	for i := 0; i+2 < len(args); i++ {
+		if args[i] == "--bind" && args[i+1] == path && args[i+2] == path {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSandboxWrap(t *testing.T) {
+	work, extra := t.TempDir(), t.TempDir()
+	addDirs, _ := json.Marshal([]string{extra, filepath.Join(extra, "missing")})
+	sb := &sandbox{job: db.Job{WorkingDir: work, AddDirs: string(addDirs)}, scratch: t.TempDir()}
+
+	name, args, env, err := sb.wrap("/bin/claude", []string{"-p", "hi"}, []string{"A=1"})
+	require.NoError(t, err)
+	require.Equal(t, "bwrap", name)
+	require.Equal(t, []string{"A=1"}, env)
+	require.Equal(t, []string{"--", "/bin/claude", "-p", "hi"}, args[len(args)-4:])
+	require.Contains(t, strings.Join(args, " "), "--ro-bind / /")
+	require.NotContains(t, args, "--unshare-net")
+	require.True(t, bound(args, work))
+	require.True(t, bound(args, extra))
+	require.True(t, bound(args, sb.scratch))
+	require.False(t, bound(args, filepath.Join(extra, "missing")))
+	i := slices.Index(args, "--chdir")
+	require.Equal(t, work, args[i+1])
+
+	// A restricted network runs the CLI behind the forwarder.
+	sb.proxy = &sandboxProxy{socket: filepath.Join(sb.scratch, "proxy.sock")}
+	_, args, env, err = sb.wrap("/bin/claude", nil, nil)
+	require.NoError(t, err)
+	require.Contains(t, args, "--unshare-net")
+	self, err := os.Executable()
+	require.NoError(t, err)
+	require.Equal(t, []string{"--", self, "/bin/claude"}, args[len(args)-3:])
+	require.Equal(t, []string{sandboxProxyEnv + "=" + sb.proxy.socket}, env)
+}
+
+func TestSandboxWrap_KeepsCLIConfigReadOnly(t *testing.T) {
+	config := t.TempDir()
+	for _, dir := range []string{"projects", "todos", "hooks"} {
+		require.NoError(t, os.Mkdir(filepath.Join(config, dir), 0o755))
+	}
+	for _, file := range []string{"settings.json", "CLAUDE.md", ".credentials.json"} {
+		require.NoError(t, os.WriteFile(filepath.Join(config, file), []byte("{}"), 0o600))
+	}
+	state := filepath.Join(config, ".claude.json")
+	require.NoError(t, os.WriteFile(state, []byte(`{"projects":{}}`), 0o600))
+	sb := &sandbox{job: db.Job{WorkingDir: t.TempDir()}, scratch: t.TempDir()}
+
+	_, args, _, err := sb.wrap("/bin/claude", nil, []string{"CLAUDE_CONFIG_DIR=" + config})
+	require.NoError(t, err)
+	require.True(t, bound(args, filepath.Join(config, "projects")))
+	require.True(t, bound(args, filepath.Join(config, "todos")))
+	require.True(t, bound(args, filepath.Join(config, ".credentials.json")))
+	require.False(t, bound(args, config))
+	require.False(t, bound(args, filepath.Join(config, "hooks")))
+	require.False(t, bound(args, filepath.Join(config, "settings.json")))
+	require.False(t, bound(args, filepath.Join(config, "CLAUDE.md")))
+	require.False(t, bound(args, state))
+
+	// The state file is replaced by a copy that goes with the sandbox.
+	copied := filepath.Join(sb.scratch, "claude.json")
+	require.Contains(t, strings.Join(args, "\n"), "--bind\n"+copied+"\n"+state)
+	data, err := os.ReadFile(copied)
+	require.NoError(t, err)
+	require.Equal(t, `{"projects":{}}`, string(data))
+}
+
+func TestWorktreeGitDir(t *testing.T) {
+	repo := t.TempDir()
+	gitDir := filepath.Join(repo, ".git", "worktrees", "run")
+	require.NoError(t, os.MkdirAll(gitDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "commondir"), []byte("../..\n"), 0o644))
+	work := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(work, ".git"), []byte("gitdir: "+gitDir+"\n"), 0o644))
+
+	require.Equal(t, filepath.Join(repo, ".git"), worktreeGitDir(work))
+	require.Empty(t, worktreeGitDir(repo))
+	require.Empty(t, worktreeGitDir(t.TempDir()))
+}
+
+func TestSandboxBlocked(t *testing.T) {
+	events := []transcript.Event{
+		{Kind: transcript.KindText, Text: "Read-only file system is expected"},
+		transcript.ToolResult("t1", "Bash", "touch: cannot touch '/home/u/x': Read-only file system", true),
+		transcript.ToolResult("t2", "Bash", "ok\ntouch: cannot touch '/home/u/x': Read-only file system", true),
+		transcript.ToolResult("t3", "Bash", "curl: (6) Could not resolve host: example.com", true),
+	}
+
+	sb := &sandbox{}
+	require.Equal(t, []string{"Bash: touch: cannot touch '/home/u/x': Read-only file system"}, sb.blocked(events))
+
+	sb.proxy = &sandboxProxy{refusal: []string{"evil.example:443"}}
+	require.Equal(t, []string{
+		"network: evil.example:443 is not an allowed host",
+		"Bash: touch: cannot touch '/home/u/x': Read-only file system",
+		"Bash: curl: (6) Could not resolve host: example.com",
+	}, sb.blocked(events))
+}
+
+func TestHostAllowed(t *testing.T) {
+	patterns := []string{"api.github.com", "*.anthropic.com"}
+	require.True(t, hostAllowed("api.github.com", patterns))
+	require.True(t, hostAllowed("API.GitHub.com.", patterns))
+	require.True(t, hostAllowed("api.anthropic.com", patterns))
+	require.False(t, hostAllowed("anthropic.com", patterns))
+	require.False(t, hostAllowed("github.com", patterns))
+	require.False(t, hostAllowed("evilanthropic.com", patterns))
+}
+
+func TestSandboxProxy(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+	defer plain.Close()
+
+	// Short path: Unix socket paths are limited to about 100 bytes.
+	dir, err := os.MkdirTemp("", "sbx")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	proxy, err := startSandboxProxy(filepath.Join(dir, "proxy.sock"), []string{"127.0.0.1"})
+	require.NoError(t, err)
+	defer proxy.close()
+
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: "sandbox"})
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", proxy.socket)
+	}
+	client := &http.Client{Transport: transport}
+
+	for url, want := range map[string]string{srv.URL: "hello", plain.URL: "plain"} {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		body := bufio.NewScanner(resp.Body)
+		require.True(t, body.Scan())
+		require.Equal(t, want, body.Text())
+		resp.Body.Close()
+	}
+
+	resp, err := client.Get("http://blocked.example/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, err = client.Get("https://blocked.example/")
+	require.Error(t, err)
+	require.Equal(t, []string{"blocked.example", "blocked.example:443"}, proxy.refused())
+}
+
+func TestClaudeExecute_Sandboxed(t *testing.T) {
+	bwrapArgs := fakeBwrap(t)
+	user, _ := json.Marshal(cliEvent{Type: "user", Message: &cliMessage{Role: "user", Content: []cliContentBlock{{
+		Type: "tool_result", ToolUseID: "t1", IsError: true,
+		Content: json.RawMessage(`"touch: cannot touch '/etc/x': Read-only file system"`),
+	}}}})
+	fake := withFake(t, fakeclaude.Step{Lines: []string{
+		systemLine(),
+		assistantLine(cliContentBlock{Type: "tool_use", Name: "Bash", ID: "t1", Input: json.RawMessage(`{"command":"touch /etc/x"}`)}),
+		string(user),
+		resultLine("Could not write the file."),
+	}})
+
+	work := t.TempDir()
+	job := db.Job{Prompt: "write", WorkingDir: work, Sandbox: true, SandboxNetwork: "full"}
+	result, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.NoError(t, err)
+	require.Contains(t, result.Transcript, ">Sandbox blocked</strong>")
+	require.Contains(t, result.Transcript, "<li>Bash: touch: cannot touch '/etc/x': Read-only file system</li>")
+	require.Equal(t, transcript.Render(Rebuild(result.RawLines)), result.Transcript)
+
+	require.Equal(t, work, fake.Calls()[0].Dir)
+	require.True(t, bound(bwrapArgs(), work))
+	require.NotContains(t, bwrapArgs(), "--unshare-net")
+
+	// With a restricted network the CLI runs behind the forwarder.
+	job.SandboxNetwork = "restricted"
+	result, err = ClaudeExecute(context.Background(), job, nil, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "Could not write the file.", result.Summary)
+	require.Contains(t, bwrapArgs(), "--unshare-net")
+	require.Len(t, fake.Calls(), 2)
+}
+
+func TestClaudeExecute_SandboxNeedsBubblewrap(t *testing.T) {
+	withFake(t)
+	t.Setenv("PATH", t.TempDir())
+
+	job := db.Job{Prompt: "x", WorkingDir: t.TempDir(), Sandbox: true}
+	_, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.ErrorContains(t, err, "setting up sandbox")
+}
@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"claude-schedule/internal/db"
+)
+
+// ShellExecutor runs a job's prompt as a script in the system shell (sh on
+// Unix, cmd on Windows), for housekeeping jobs that need no model. The job's
+// environment overrides apply. Combined stdout and stderr become the
+// transcript, shown as a code block.
+type ShellExecutor struct{}
+
+func (ShellExecutor) Execute(ctx context.Context, job db.Job, _ []db.MCPServer, opts Options) (ExecuteResult, error) {
+	jobEnv, err := job.EnvMap()
+	if err != nil {
+		return ExecuteResult{}, err
+	}
+
+	name, args := shellCommand(job.Prompt)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = job.WorkingDir
+	cmd.Env = childEnv(jobEnv)
+	hideWindow(cmd)
+
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return ExecuteResult{}, fmt.Errorf("creating stdout pipe: %w", err)
+	}
+	cmd.Stderr = cmd.Stdout
+
+	start := time.Now()
+	release, err := startProcessTree(cmd)
+	if err != nil {
+		return ExecuteResult{}, fmt.Errorf("starting shell: %w", err)
+	}
+	defer release()
+
+	var lines []string
+	scanner := bufio.NewScanner(stdoutPipe)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if opts.OnProgress != nil {
+			if len(lines) == 0 {
+				opts.OnProgress("```\n")
+			}
+			opts.OnProgress(line + "\n")
+		}
+		lines = append(lines, line)
+	}
+	waitErr := cmd.Wait()
+
+	output := strings.Join(lines, "\n")
+	result := ExecuteResult{
+		Transcript: codeBlock(output),
+		Summary:    lastLine(lines),
+		Usage:      db.Usage{DurationMs: time.Since(start).Milliseconds()},
+	}
+	if waitErr != nil {
+		if output == "" {
+			return result, fmt.Errorf("command failed: %w", waitErr)
+		}
+		return result, fmt.Errorf("command failed: %w\n\n%s", waitErr, output)
+	}
+	return result, nil
+}
+
+func (ShellExecutor) Answer(context.Context, db.Job, []db.MCPServer, string, Options) (ExecuteResult, error) {
+	return ExecuteResult{}, errNoSessions(BackendShell)
+}
+
+func (ShellExecutor) FollowUp(context.Context, db.Job, []db.MCPServer, string, bool, Options) (ExecuteResult, error) {
+	return ExecuteResult{}, errNoSessions(BackendShell)
+}
+
+func (ShellExecutor) Sessions() bool { return false }
+
+// codeBlock wraps s in a markdown code fence longer than any run of backticks
+// inside it. Empty output yields an empty transcript.
+func codeBlock(s string) string {
+	if s == "" {
+		return ""
+	}
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + "\n" + s + "\n" + fence
+}
+
+// lastLine returns the last non-blank line.
+func lastLine(lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package executor
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellExecutor_Execute(t *testing.T) {
+	var progress strings.Builder
+	result, err := ShellExecutor{}.Execute(context.Background(), db.Job{Prompt: "echo hello && echo done"}, nil, Options{
+		OnProgress: func(f string) { progress.WriteString(f) },
+	})
+	require.NoError(t, err)
+	require.Equal(t, "```\nhello\ndone\n```", strings.ReplaceAll(result.Transcript, "\r", ""))
+	require.Equal(t, "done", result.Summary)
+	require.Contains(t, progress.String(), "hello")
+}
+
+func TestShellExecutor_Failure(t *testing.T) {
+	_, err := ShellExecutor{}.Execute(context.Background(), db.Job{Prompt: "echo broken && exit 3"}, nil, Options{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exit status 3")
+	require.Contains(t, err.Error(), "broken")
+}
+
+func TestShellExecutor_WorkingDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses pwd")
+	}
+	dir := t.TempDir()
+	result, err := ShellExecutor{}.Execute(context.Background(), db.Job{Prompt: "pwd", WorkingDir: dir}, nil, Options{})
+	require.NoError(t, err)
+	resolved, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	require.Contains(t, []string{dir, resolved}, result.Summary)
+}
+
+func TestCodeBlock(t *testing.T) {
+	require.Equal(t, "", codeBlock(""))
+	require.Equal(t, "```\nplain\n```", codeBlock("plain"))
+	require.Equal(t, "````\nhas ``` inside\n````", codeBlock("has ``` inside"))
+}
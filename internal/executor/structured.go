@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/structured"
+	"claude-schedule/internal/transcript"
+)
+
+// checkStructured validates the final result of a run of a job with an
+// output schema and records it on result. A result that does not match fails
+// with a *structured.ValidationError. With job.SchemaRetry set, Claude is
+// first shown the problems in the same conversation and asked once for a
+// corrected result. base holds the flags of the run's invocation. Runs left
+// waiting on a question are checked once they are answered.
+func checkStructured(ctx context.Context, job db.Job, base []string, result ExecuteResult, opts Options) (ExecuteResult, error) {
+	if strings.TrimSpace(job.OutputSchema) == "" || DetectQuestion(result.RawLines) != "" {
+		return result, nil
+	}
+	schema, err := structured.Compile(job.OutputSchema)
+	if err != nil {
+		return result, err
+	}
+
+	out, err := schema.Check(result.Summary)
+	var invalid *structured.ValidationError
+	if errors.As(err, &invalid) && job.SchemaRetry && result.SessionID != "" {
+		message := structured.RetryPrompt(invalid)
+		retry := transcript.Event{Kind: transcript.KindFollowUp, Time: time.Now().UTC(), Text: message}
+		if opts.OnProgress != nil {
+			opts.OnProgress(transcript.Render([]transcript.Event{retry}))
+		}
+		args := append([]string{"-p", message}, sessionArgs(result.SessionID, true)...)
+		next, retryErr := runClaude(ctx, job, append(args, base...), opts.OnProgress)
+
+		// The run's stream and transcript cover both invocations.
+		next.RawLines = append(append(result.RawLines, FollowUpLine(message)), next.RawLines...)
+		next.Events = append(append(result.Events, retry), next.Events...)
+		next.Transcript = transcript.Render(next.Events)
+		usage := result.Usage
+		usage.Add(next.Usage)
+		next.Usage = usage
+		result = next
+		if retryErr != nil {
+			return result, retryErr
+		}
+		out, err = schema.Check(result.Summary)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.StructuredOutput = out
+	return result, nil
+}
@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/fakeclaude"
+	"claude-schedule/internal/structured"
+	"claude-schedule/internal/transcript"
+
+	"github.com/stretchr/testify/require"
+)
+
+const changedSchema = `{"type": "object", "properties": {"changed": {"type": "boolean"}}, "required": ["changed"]}`
+
+func TestClaudeExecute_StructuredOutput(t *testing.T) {
+	fake := withFake(t, fakeclaude.Reply("The page is unchanged.\n\n```json\n{\"changed\": false}\n```"))
+
+	job := db.Job{Prompt: "check the pricing page", SessionID: "sess-1", OutputSchema: changedSchema}
+	result, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.NoError(t, err)
+	require.Equal(t, `{"changed":false}`, result.StructuredOutput)
+	require.Contains(t, fake.Calls()[0].Flag("--append-system-prompt"), `"required": ["changed"]`)
+}
+
+func TestClaudeExecute_StructuredOutputMismatch(t *testing.T) {
+	fake := withFake(t, fakeclaude.Reply("I couldn't access the page."))
+
+	job := db.Job{Prompt: "check the pricing page", SessionID: "sess-1", OutputSchema: changedSchema}
+	result, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	var invalid *structured.ValidationError
+	require.True(t, errors.As(err, &invalid), "got %v", err)
+	require.Equal(t, []string{"the result does not end with a JSON value"}, invalid.Problems)
+	require.Empty(t, result.StructuredOutput)
+	require.Len(t, fake.Calls(), 1)
+}
+
+func TestClaudeExecute_StructuredOutputRetry(t *testing.T) {
+	fake := withFake(t,
+		fakeclaude.Reply(`{"changed": "yes"}`),
+		fakeclaude.Reply("```json\n{\"changed\": true}\n```"),
+	)
+
+	job := db.Job{Prompt: "check the pricing page", SessionID: "sess-1", OutputSchema: changedSchema, SchemaRetry: true}
+	var progress []string
+	result, err := ClaudeExecute(context.Background(), job, nil, Options{
+		OnProgress: func(fragment string) { progress = append(progress, fragment) },
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{"changed":true}`, result.StructuredOutput)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 2)
+	require.Equal(t, "sess-1", calls[1].Flag("--resume"))
+	require.Contains(t, calls[1].Prompt(), "at /changed: expected boolean, but got string")
+
+	// Both invocations make up the run.
+	require.EqualValues(t, 20, result.Usage.InputTokens)
+	require.Contains(t, result.RawLines, FollowUpLine(calls[1].Prompt()))
+	var kinds []string
+	for _, e := range result.Events {
+		kinds = append(kinds, e.Kind)
+	}
+	require.Contains(t, kinds, transcript.KindFollowUp)
+	require.Contains(t, result.Transcript, "expected boolean")
+	require.NotEmpty(t, progress)
+}
+
+func TestClaudeExecute_StructuredOutputRetryStillInvalid(t *testing.T) {
+	fake := withFake(t, fakeclaude.Reply("no"), fakeclaude.Reply("still no"))
+
+	job := db.Job{Prompt: "x", SessionID: "sess-1", OutputSchema: changedSchema, SchemaRetry: true}
+	_, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	var invalid *structured.ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, fake.Calls(), 2)
+}
+
+func TestClaudeAnswer_ChecksStructuredOutput(t *testing.T) {
+	withFake(t,
+		fakeclaude.Question("Which page?", "pricing", "docs"),
+		fakeclaude.Reply(`{"changed": true}`),
+	)
+
+	job := db.Job{Prompt: "check a page", SessionID: "sess-1", OutputSchema: changedSchema}
+	result, err := ClaudeExecute(context.Background(), job, nil, Options{})
+	require.NoError(t, err, "a run waiting on a question is checked once answered")
+	require.Empty(t, result.StructuredOutput)
+
+	job.SessionRuns = 1
+	result, err = ClaudeAnswer(context.Background(), job, nil, "pricing", Options{})
+	require.NoError(t, err)
+	require.Equal(t, `{"changed":true}`, result.StructuredOutput)
+}
@@ -0,0 +1,382 @@
+// Package fakeclaude provides a scriptable stand-in for the Claude Code CLI so
+// tests can run the real executor path without the CLI installed.
+//
+// The fake is the test binary itself. A package's TestMain calls Main, which
+// turns the process into the fake CLI when the executor starts it with the
+// settings returned by Fake.Settings:
+//
+//	func TestMain(m *testing.M) {
+//		fakeclaude.Main()
+//		os.Exit(m.Run())
+//	}
+//
+// Each invocation plays the next Step of the script; the last step repeats.
+// Sessions are tracked like the CLI does: --session-id creates one and fails
+// if it exists, --resume fails with "No conversation found" for an unknown
+// one, and --fork-session continues in a new one. Every JSON event printed is
+// stamped with the session in use.
+package fakeclaude
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-schedule/internal/db"
+
+	"github.com/google/uuid"
+)
+
+// dirEnv names the environment variable holding the fake's state directory.
+// Its presence is what makes Main act as the CLI.
+const dirEnv = "FAKE_CLAUDE_DIR"
+
+//go:embed fixtures/*.jsonl
+var fixtures embed.FS
+
+// Step is what the fake does for one invocation.
+type Step struct {
+	Fixture  string        `json:"fixture,omitempty"`  // recorded stream to replay: "simple", "tools" or "question"
+	Lines    []string      `json:"lines,omitempty"`    // stream-json lines printed after the fixture
+	Delay    time.Duration `json:"delay,omitempty"`    // pause before each line
+	Stderr   string        `json:"stderr,omitempty"`   // written to stderr after the output
+	ExitCode int           `json:"exitCode,omitempty"` // process exit code
+}
+
+// Replay returns a step that prints the named recorded fixture.
+func Replay(fixture string) Step {
+	return Step{Fixture: fixture}
+}
+
+// Reply returns a step that answers with a single text message.
+func Reply(text string) Step {
+	return Step{Lines: []string{
+		initLine(),
+		event(map[string]any{
+			"type": "assistant",
+			"message": map[string]any{
+				"role":    "assistant",
+				"content": []any{map[string]any{"type": "text", "text": text}},
+				"usage":   map[string]any{"input_tokens": 10, "output_tokens": 5},
+			},
+		}),
+		resultLine(text, false),
+	}}
+}
+
+// Question returns a step that stops at an AskUserQuestion tool call, like
+// the CLI does in print mode.
+func Question(question string, options ...string) Step {
+	opts := make([]any, 0, len(options))
+	for _, label := range options {
+		opts = append(opts, map[string]any{"label": label, "description": ""})
+	}
+	return Step{Lines: []string{
+		initLine(),
+		event(map[string]any{
+			"type": "assistant",
+			"message": map[string]any{
+				"role": "assistant",
+				"content": []any{map[string]any{
+					"type": "tool_use",
+					"id":   "toolu_question",
+					"name": "AskUserQuestion",
+					"input": map[string]any{"questions": []any{map[string]any{
+						"question": question,
+						"header":   "Question",
+						"options":  opts,
+					}}},
+				}},
+			},
+		}),
+		resultLine("", false),
+	}}
+}
+
+// Fail returns a step that reports message as an error result and exits
+// with status 1.
+func Fail(message string) Step {
+	return Step{Lines: []string{initLine(), resultLine(message, true)}, ExitCode: 1}
+}
+
+// Slow returns a copy of s that pauses for delay before each line.
+func (s Step) Slow(delay time.Duration) Step {
+	s.Delay = delay
+	return s
+}
+
+func initLine() string {
+	return event(map[string]any{"type": "system", "subtype": "init", "model": "claude-sonnet-4-5-20250929"})
+}
+
+func resultLine(text string, isError bool) string {
+	subtype := "success"
+	if isError {
+		subtype = "error_during_execution"
+	}
+	return event(map[string]any{
+		"type":           "result",
+		"subtype":        subtype,
+		"is_error":       isError,
+		"result":         text,
+		"duration_ms":    100,
+		"num_turns":      1,
+		"total_cost_usd": 0.001,
+		"usage":          map[string]any{"input_tokens": 10, "output_tokens": 5},
+	})
+}
+
+func event(v map[string]any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// Call records one invocation of the fake.
+type Call struct {
+	Args      []string `json:"args"`
+	Dir       string   `json:"dir"`
+	SessionID string   `json:"sessionId"`       // session the invocation ran in
+	Error     string   `json:"error,omitempty"` // session error reported instead of running a step
+}
+
+// Prompt returns the -p argument.
+func (c Call) Prompt() string {
+	return c.Flag("-p")
+}
+
+// Flag returns the value following flag, or "" if flag was not passed.
+func (c Call) Flag(flag string) string {
+	for i := 0; i+1 < len(c.Args); i++ {
+		if c.Args[i] == flag {
+			return c.Args[i+1]
+		}
+	}
+	return ""
+}
+
+// Has reports whether flag was passed.
+func (c Call) Has(flag string) bool {
+	for _, arg := range c.Args {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Fake is a scripted CLI backed by a temporary state directory.
+type Fake struct {
+	dir string
+}
+
+// New returns a fake that plays steps in order. Without steps it replays the
+// "simple" fixture for every invocation.
+func New(t testing.TB, steps ...Step) *Fake {
+	t.Helper()
+	if len(steps) == 0 {
+		steps = []Step{Replay("simple")}
+	}
+	f := &Fake{dir: t.TempDir()}
+	data, err := json.Marshal(steps)
+	if err != nil {
+		t.Fatalf("fakeclaude: encoding script: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(f.dir, "script.json"), data, 0o644); err != nil {
+		t.Fatalf("fakeclaude: writing script: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(f.dir, "sessions"), 0o755); err != nil {
+		t.Fatalf("fakeclaude: creating sessions dir: %v", err)
+	}
+	return f
+}
+
+// Settings returns executor settings that run the fake instead of the CLI.
+func (f *Fake) Settings() db.Settings {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	env, _ := json.Marshal(map[string]string{dirEnv: f.dir})
+	return db.Settings{ClaudePath: exe, ClaudeEnv: string(env)}
+}
+
+// Calls returns the invocations so far, oldest first.
+func (f *Fake) Calls() []Call {
+	calls, _ := readCalls(f.dir)
+	return calls
+}
+
+// AddSession makes the fake know a session, as if an earlier run created it.
+func (f *Fake) AddSession(id string) {
+	os.WriteFile(sessionPath(f.dir, id), nil, 0o644)
+}
+
+// ForgetSession removes a session, as if it was deleted from the CLI's
+// history, so resuming it fails.
+func (f *Fake) ForgetSession(id string) {
+	os.Remove(sessionPath(f.dir, id))
+}
+
+// HasSession reports whether the fake knows a session.
+func (f *Fake) HasSession(id string) bool {
+	_, err := os.Stat(sessionPath(f.dir, id))
+	return err == nil
+}
+
+// Main runs the fake CLI and exits when the process was started as one, and
+// returns otherwise.
+func Main() {
+	dir := os.Getenv(dirEnv)
+	if dir == "" {
+		return
+	}
+	os.Exit(run(dir, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run plays one invocation and returns the exit code.
+func run(dir string, args []string, stdout, stderr io.Writer) int {
+	var steps []Step
+	data, err := os.ReadFile(filepath.Join(dir, "script.json"))
+	if err == nil {
+		err = json.Unmarshal(data, &steps)
+	}
+	if err != nil || len(steps) == 0 {
+		fmt.Fprintf(stderr, "fakeclaude: reading script: %v\n", err)
+		return 2
+	}
+	calls, err := readCalls(dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "fakeclaude: reading calls: %v\n", err)
+		return 2
+	}
+	played := 0
+	for _, c := range calls {
+		if c.Error == "" {
+			played++
+		}
+	}
+
+	call := Call{Args: args}
+	call.Dir, _ = os.Getwd()
+	call.SessionID, call.Error = pickSession(dir, call)
+	if err := appendCall(dir, call); err != nil {
+		fmt.Fprintf(stderr, "fakeclaude: recording call: %v\n", err)
+		return 2
+	}
+	if call.Error != "" {
+		fmt.Fprintln(stderr, call.Error)
+		return 1
+	}
+	os.WriteFile(sessionPath(dir, call.SessionID), nil, 0o644)
+
+	step := steps[min(played, len(steps)-1)]
+	lines, err := stepLines(step)
+	if err != nil {
+		fmt.Fprintf(stderr, "fakeclaude: %v\n", err)
+		return 2
+	}
+	for _, line := range lines {
+		time.Sleep(step.Delay)
+		fmt.Fprintln(stdout, stamp(line, call.SessionID, call.Flag("--model")))
+	}
+	if step.Stderr != "" {
+		fmt.Fprintln(stderr, step.Stderr)
+	}
+	return step.ExitCode
+}
+
+// pickSession returns the session an invocation runs in, or the error the
+// CLI would print instead.
+func pickSession(dir string, call Call) (sessionID, errMsg string) {
+	if id := call.Flag("--resume"); id != "" {
+		if _, err := os.Stat(sessionPath(dir, id)); err != nil {
+			return "", "No conversation found with session ID: " + id
+		}
+		if call.Has("--fork-session") {
+			return uuid.New().String(), ""
+		}
+		return id, ""
+	}
+	if id := call.Flag("--session-id"); id != "" {
+		if _, err := os.Stat(sessionPath(dir, id)); err == nil {
+			return "", "Error: Session ID " + id + " is already in use."
+		}
+		return id, ""
+	}
+	return uuid.New().String(), ""
+}
+
+// stepLines returns the fixture lines followed by the step's own.
+func stepLines(step Step) ([]string, error) {
+	var lines []string
+	if step.Fixture != "" {
+		data, err := fixtures.ReadFile("fixtures/" + step.Fixture + ".jsonl")
+		if err != nil {
+			return nil, fmt.Errorf("unknown fixture %q", step.Fixture)
+		}
+		lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+	return append(lines, step.Lines...), nil
+}
+
+// stamp sets the session ID on a JSON event, and the model on the init event
+// when one was requested. Other lines are returned unchanged.
+func stamp(line, sessionID, model string) string {
+	var evt map[string]any
+	if err := json.Unmarshal([]byte(line), &evt); err != nil {
+		return line
+	}
+	evt["session_id"] = sessionID
+	if model != "" && evt["type"] == "system" && evt["subtype"] == "init" {
+		evt["model"] = model
+	}
+	return event(evt)
+}
+
+func sessionPath(dir, id string) string {
+	return filepath.Join(dir, "sessions", filepath.Base(id))
+}
+
+func readCalls(dir string) ([]Call, error) {
+	f, err := os.Open(filepath.Join(dir, "calls.jsonl"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var calls []Call
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var c Call
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return nil, err
+		}
+		calls = append(calls, c)
+	}
+	return calls, scanner.Err()
+}
+
+func appendCall(dir string, call Call) error {
+	data, err := json.Marshal(call)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "calls.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,107 @@
+package fakeclaude
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// play runs one invocation in-process and returns its output lines.
+func play(t *testing.T, f *Fake, args ...string) (lines []string, stderr string, code int) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	code = run(f.dir, args, &out, &errOut)
+	if s := strings.TrimSpace(out.String()); s != "" {
+		lines = strings.Split(s, "\n")
+	}
+	return lines, errOut.String(), code
+}
+
+func sessionOf(t *testing.T, line string) string {
+	t.Helper()
+	var evt struct {
+		SessionID string `json:"session_id"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(line), &evt))
+	return evt.SessionID
+}
+
+func TestRun_PlaysStepsInOrder(t *testing.T) {
+	f := New(t, Replay("tools"), Reply("second"))
+
+	lines, _, code := play(t, f, "-p", "one", "--session-id", "s1")
+	require.Equal(t, 0, code)
+	require.Len(t, lines, 6)
+	for _, line := range lines {
+		require.Equal(t, "s1", sessionOf(t, line))
+	}
+
+	for i := 0; i < 2; i++ {
+		lines, _, code = play(t, f, "-p", "again", "--resume", "s1")
+		require.Equal(t, 0, code)
+		require.Contains(t, lines[len(lines)-1], `"result":"second"`)
+	}
+
+	calls := f.Calls()
+	require.Len(t, calls, 3)
+	require.Equal(t, "one", calls[0].Prompt())
+	require.Equal(t, "s1", calls[2].SessionID)
+}
+
+func TestRun_Sessions(t *testing.T) {
+	f := New(t)
+
+	_, stderr, code := play(t, f, "-p", "x", "--resume", "gone")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "No conversation found with session ID: gone")
+
+	_, _, code = play(t, f, "-p", "x", "--session-id", "s1")
+	require.Equal(t, 0, code)
+	require.True(t, f.HasSession("s1"))
+
+	_, stderr, code = play(t, f, "-p", "x", "--session-id", "s1")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "already in use")
+
+	lines, _, code := play(t, f, "-p", "x", "--resume", "s1", "--fork-session")
+	require.Equal(t, 0, code)
+	forked := sessionOf(t, lines[0])
+	require.NotEqual(t, "s1", forked)
+	require.True(t, f.HasSession(forked))
+
+	f.ForgetSession("s1")
+	_, _, code = play(t, f, "-p", "x", "--resume", "s1")
+	require.Equal(t, 1, code)
+
+	// Rejected invocations do not use up script steps.
+	calls := f.Calls()
+	require.Len(t, calls, 5)
+	require.NotEmpty(t, calls[0].Error)
+}
+
+func TestRun_StampsRequestedModel(t *testing.T) {
+	f := New(t)
+	lines, _, _ := play(t, f, "-p", "x", "--model", "claude-opus-4-1")
+	require.Contains(t, lines[0], `"model":"claude-opus-4-1"`)
+}
+
+func TestRun_FailAndSlow(t *testing.T) {
+	f := New(t, Fail("boom").Slow(20*time.Millisecond))
+
+	start := time.Now()
+	lines, _, code := play(t, f, "-p", "x")
+	require.Equal(t, 1, code)
+	require.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	require.Contains(t, lines[len(lines)-1], `"is_error":true`)
+}
+
+func TestRun_UnknownFixture(t *testing.T) {
+	f := New(t, Replay("nope"))
+	_, stderr, code := play(t, f, "-p", "x")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "unknown fixture")
+}
@@ -0,0 +1,85 @@
+// Package prompt renders job prompts as Go text/template templates so they
+// can refer to runtime values such as the current time or the previous run.
+package prompt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Trigger kinds recorded in Data.Trigger.
+const (
+	TriggerSchedule = "schedule" // the job came due
+	TriggerManual   = "manual"   // the user ran the job now
+	TriggerPreview  = "preview"  // the prompt is being previewed, not run
+)
+
+// Data holds the values a prompt template can refer to, e.g.
+// {{.Now.Format "2006-01-02"}} or {{.Vars.repo}}.
+type Data struct {
+	JobName      string            // name of the job
+	Now          time.Time         // current time in the job's time zone
+	LastRun      time.Time         // start of the previous run; zero if there is none
+	LastStatus   string            // status of the previous run, empty if there is none
+	LastSummary  string            // final result text of the previous run
+	Trigger      string            // what started the run, see the Trigger constants
+	Payload      string            // data supplied with the trigger, if any
+	ArtifactsDir string            // directory for files the run should keep, empty if there is none
+	Vars         map[string]string // custom per-job variables
+}
+
+// parse parses text as a prompt template. Missing variables are errors so a
+// mistyped {{.Vars.name}} fails instead of rendering "<no value>".
+func parse(text string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Render executes the prompt template text with data.
+func Render(text string, data Data) (string, error) {
+	tmpl, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering prompt: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Validate checks that text parses and only refers to known fields and the
+// given variables, by rendering it against placeholder data.
+func Validate(text string, vars map[string]string) error {
+	tmpl, err := parse(text)
+	if err != nil {
+		return err
+	}
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	data := Data{Now: time.Now(), Trigger: TriggerPreview, Vars: vars}
+	if err := tmpl.Execute(io.Discard, data); err != nil {
+		return fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return nil
+}
+
+// LoadLocation returns the time zone with the given IANA name. An empty name
+// is the system's local zone.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone: %s", name)
+	}
+	return loc, nil
+}
@@ -0,0 +1,70 @@
+package prompt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	data := Data{
+		JobName:     "digest",
+		Now:         time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC),
+		LastRun:     time.Date(2026, 3, 3, 9, 30, 0, 0, time.UTC),
+		LastStatus:  "success",
+		LastSummary: "3 new issues",
+		Trigger:     TriggerSchedule,
+		Payload:     `{"ref":"main"}`,
+		Vars:        map[string]string{"repo": "acme/api"},
+	}
+
+	got, err := Render(`{{.JobName}} for {{.Vars.repo}} on {{.Now.Format "2006-01-02"}}; `+
+		`since {{.LastRun.Format "15:04"}} ({{.LastStatus}}: {{.LastSummary}}) via {{.Trigger}} {{.Payload}}`, data)
+	require.NoError(t, err)
+	require.Equal(t, `digest for acme/api on 2026-03-04; since 09:30 (success: 3 new issues) via schedule {"ref":"main"}`, got)
+}
+
+func TestRender_PlainText(t *testing.T) {
+	got, err := Render("Summarise the news", Data{})
+	require.NoError(t, err)
+	require.Equal(t, "Summarise the news", got)
+}
+
+func TestRender_FirstRun(t *testing.T) {
+	got, err := Render(`{{if .LastRun.IsZero}}first run{{else}}again{{end}}`, Data{})
+	require.NoError(t, err)
+	require.Equal(t, "first run", got)
+}
+
+func TestRender_MissingVariable(t *testing.T) {
+	_, err := Render(`{{.Vars.missing}}`, Data{Vars: map[string]string{}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rendering prompt")
+}
+
+func TestValidate(t *testing.T) {
+	vars := map[string]string{"repo": "acme/api"}
+	require.NoError(t, Validate(`Check {{.Vars.repo}} at {{.Now}}`, vars))
+
+	err := Validate(`{{.Vars.repo`, vars)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid prompt template")
+
+	require.Error(t, Validate(`{{.Unknown}}`, vars))
+	require.Error(t, Validate(`{{.Vars.branch}}`, vars))
+	require.Error(t, Validate(`{{.Vars.repo}}`, nil))
+}
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := LoadLocation("")
+	require.NoError(t, err)
+	require.Equal(t, time.Local, loc)
+
+	loc, err = LoadLocation("Europe/London")
+	require.NoError(t, err)
+	require.Equal(t, "Europe/London", loc.String())
+
+	_, err = LoadLocation("Mars/Olympus")
+	require.Error(t, err)
+}
@@ -0,0 +1,99 @@
+// Package resultdiff compares the final result of a run with the previous
+// run's, for jobs that notify only when their result changes.
+package resultdiff
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Changed reports whether cur differs from prev. mode is how they are
+// compared: "exact", "whitespace", which ignores differences in spacing and
+// line breaks, or "similarity", under which results less similar than
+// threshold (0-1) differ.
+func Changed(mode string, threshold float64, prev, cur string) bool {
+	switch mode {
+	case "whitespace":
+		return normalize(prev) != normalize(cur)
+	case "similarity":
+		return Similarity(prev, cur) < threshold
+	default:
+		return prev != cur
+	}
+}
+
+// normalize collapses runs of whitespace to single spaces.
+func normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Similarity returns how alike two results are word by word, from 0 for
+// nothing in common to 1 for the same words in the same order.
+func Similarity(a, b string) float64 {
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa) == 0 && len(wb) == 0 {
+		return 1
+	}
+	return difflib.NewMatcherWithJunk(wa, wb, false, nil).Ratio()
+}
+
+// Unified returns a unified diff of the lines of prev and cur, or "" if they
+// are the same.
+func Unified(prev, cur string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        lines(prev),
+		B:        lines(cur),
+		FromFile: "previous run",
+		ToFile:   "this run",
+		Context:  2,
+	})
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+// lines splits s into lines that each end in a newline, as difflib expects.
+func lines(s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	out := strings.SplitAfter(s, "\n")
+	out[len(out)-1] += "\n"
+	return out
+}
+
+// maxExcerptLine is how much of a changed line Excerpt keeps.
+const maxExcerptLine = 120
+
+// Excerpt returns up to n of the added and removed lines of a unified diff,
+// for a notification body.
+func Excerpt(diff string, n int) string {
+	var lines []string
+	more := 0
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-") {
+			continue
+		}
+		if strings.TrimSpace(line[1:]) == "" {
+			continue
+		}
+		if len(lines) == n {
+			more++
+			continue
+		}
+		if r := []rune(line); len(r) > maxExcerptLine {
+			line = string(r[:maxExcerptLine]) + "…"
+		}
+		lines = append(lines, line)
+	}
+	if more > 0 {
+		lines = append(lines, "…")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,52 @@
+package resultdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanged(t *testing.T) {
+	prev := "Plans:\n- Free\n- Pro  ($20)\n"
+	tests := []struct {
+		name      string
+		mode      string
+		threshold float64
+		cur       string
+		want      bool
+	}{
+		{"exact, same", "exact", 0, prev, false},
+		{"exact, respaced", "exact", 0, "Plans:\n- Free\n- Pro ($20)", true},
+		{"whitespace, respaced", "whitespace", 0, "Plans: - Free\n\n- Pro ($20)", false},
+		{"whitespace, reworded", "whitespace", 0, "Plans:\n- Free\n- Pro ($25)", true},
+		{"similarity, small change", "similarity", 0.7, "Plans:\n- Free\n- Pro ($25)", false},
+		{"similarity, large change", "similarity", 0.7, "The page could not be loaded.", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Changed(tt.mode, tt.threshold, prev, tt.cur))
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	require.Equal(t, 1.0, Similarity("", "  "))
+	require.Equal(t, 1.0, Similarity("a b c", "a\nb  c"))
+	require.Equal(t, 0.0, Similarity("a b", "c d"))
+	require.InDelta(t, 0.75, Similarity("a b c d", "a b x d"), 1e-9)
+}
+
+func TestUnifiedAndExcerpt(t *testing.T) {
+	require.Empty(t, Unified("same\n", "same"))
+
+	diff := Unified("Free\nPro $20\nTeam $50", "Free\nPro $25\nTeam $50\nEnterprise")
+	require.True(t, strings.HasPrefix(diff, "--- previous run\n+++ this run\n"), diff)
+	require.Contains(t, diff, "-Pro $20\n+Pro $25\n")
+
+	require.Equal(t, "-Pro $20\n+Pro $25\n+Enterprise", Excerpt(diff, 5))
+	require.Equal(t, "-Pro $20\n+Pro $25\n…", Excerpt(diff, 2))
+
+	long := Excerpt(Unified("", strings.Repeat("x", 200)), 1)
+	require.Equal(t, "+"+strings.Repeat("x", maxExcerptLine-1)+"…", long)
+}
@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"claude-schedule/internal/db"
+)
+
+// ArtifactsEnv names the environment variable that holds a run's artifacts
+// directory, for commands that cannot see the prompt.
+const ArtifactsEnv = "CLAUDE_SCHEDULE_ARTIFACTS_DIR"
+
+// maxArtifacts caps how many files of a run are indexed.
+const maxArtifacts = 500
+
+// prepareArtifacts creates the artifacts directory of a run and returns it,
+// or "" when runs have no artifacts directory.
+func (s *Scheduler) prepareArtifacts(runID string) string {
+	dir := s.store.ArtifactsDir(runID)
+	if dir == "" {
+		return ""
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("scheduler: failed to create artifacts directory for run %s: %v", runID, err)
+		return ""
+	}
+	return dir
+}
+
+// withArtifacts returns job set up to write to the artifacts directory dir:
+// the CLI may access it and the environment names it.
+func withArtifacts(job db.Job, dir string) (db.Job, error) {
+	if dir == "" {
+		return job, nil
+	}
+	addDirs, err := job.AddDirList()
+	if err != nil {
+		return job, err
+	}
+	env, err := job.EnvMap()
+	if err != nil {
+		return job, err
+	}
+	if env == nil {
+		env = map[string]string{}
+	}
+	env[ArtifactsEnv] = dir
+
+	addDirsJSON, err := json.Marshal(append(addDirs, dir))
+	if err != nil {
+		return job, err
+	}
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return job, err
+	}
+	job.AddDirs = string(addDirsJSON)
+	job.Env = string(envJSON)
+	return job, nil
+}
+
+// indexArtifacts records the files in a run's artifacts directory, replacing
+// what was recorded before. An empty directory is removed.
+func (s *Scheduler) indexArtifacts(runID string) {
+	dir := s.store.ArtifactsDir(runID)
+	if dir == "" {
+		return
+	}
+	artifacts, err := scanArtifacts(runID, dir)
+	if err != nil {
+		log.Printf("scheduler: failed to index artifacts of run %s: %v", runID, err)
+		return
+	}
+	if len(artifacts) == 0 {
+		// Remove only fails if something appeared in the meantime.
+		os.Remove(dir)
+	}
+	if err := s.store.SetRunArtifacts(runID, artifacts); err != nil {
+		log.Printf("scheduler: failed to record artifacts of run %s: %v", runID, err)
+	}
+}
+
+// scanArtifacts describes the regular files under dir. Symbolic links are
+// skipped so a run cannot point the index at files outside its directory.
+func scanArtifacts(runID, dir string) ([]db.RunArtifact, error) {
+	var artifacts []db.RunArtifact
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(artifacts) == maxArtifacts {
+			return filepath.SkipAll
+		}
+		a, err := describeArtifact(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		a.RunID = runID
+		a.Name = filepath.ToSlash(rel)
+		artifacts = append(artifacts, a)
+		return nil
+	})
+	return artifacts, err
+}
+
+// describeArtifact reads the file at path for its size, type and hash.
+func describeArtifact(path string) (db.RunArtifact, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return db.RunArtifact{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return db.RunArtifact{}, err
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		head := make([]byte, 512)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return db.RunArtifact{}, err
+		}
+		mimeType = http.DetectContentType(head[:n])
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return db.RunArtifact{}, err
+		}
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return db.RunArtifact{}, err
+	}
+	return db.RunArtifact{
+		Size:       size,
+		MimeType:   mimeType,
+		SHA256:     hex.EncodeToString(h.Sum(nil)),
+		ModifiedAt: info.ModTime().UTC().Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/executor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerCollectsArtifacts(t *testing.T) {
+	store := tempStore(t)
+	store.SetArtifactsRoot(t.TempDir())
+	job := createJob(t, store, "report", false, 1, "hours", "")
+	job.Prompt = "Write the report to {{.ArtifactsDir}}"
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	var seen db.Job
+	exec := func(_ context.Context, job db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		seen = job
+		env, err := job.EnvMap()
+		require.NoError(t, err)
+		dir := env[ArtifactsEnv]
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "report.md"), []byte("# Report\n"), 0o644))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "data"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "data", "rows"), []byte("%PDF-1.4\n"), 0o644))
+		// Links are not followed out of the directory.
+		os.Symlink("/etc/passwd", filepath.Join(dir, "passwd"))
+		return executor.ExecuteResult{Transcript: "done", SessionID: "s"}, nil
+	}
+	sched := New(store, noopEmit, exec, time.Minute)
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	dir := store.ArtifactsDir(run.ID)
+	require.Equal(t, "Write the report to "+dir, seen.Prompt)
+	addDirs, err := seen.AddDirList()
+	require.NoError(t, err)
+	require.Equal(t, []string{dir}, addDirs)
+
+	artifacts, err := store.GetRunArtifacts(run.ID)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 2)
+	require.Equal(t, "data/rows", artifacts[0].Name)
+	require.Equal(t, "application/pdf", artifacts[0].MimeType)
+	require.Equal(t, "report.md", artifacts[1].Name)
+	require.Equal(t, int64(9), artifacts[1].Size)
+	sum := sha256.Sum256([]byte("# Report\n"))
+	require.Equal(t, hex.EncodeToString(sum[:]), artifacts[1].SHA256)
+
+	// The stored job is not changed by what the run was given.
+	stored, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "[]", stored.AddDirs)
+	require.Equal(t, "{}", stored.Env)
+
+	// Follow-ups write to the same directory.
+	sched.followUpFn = func(_ context.Context, job db.Job, _ []db.MCPServer, _ string, _ bool, _ executor.Options) (executor.ExecuteResult, error) {
+		env, err := job.EnvMap()
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(env[ArtifactsEnv], "notes.txt"), []byte("more"), 0o644))
+		return executor.ExecuteResult{Transcript: "noted"}, nil
+	}
+	require.NoError(t, sched.SendFollowUp(run.ID, "add notes"))
+	sched.wg.Wait()
+	artifacts, err = store.GetRunArtifacts(run.ID)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 3)
+	require.Equal(t, "notes.txt", artifacts[1].Name)
+}
+
+func TestSchedulerRemovesEmptyArtifactsDir(t *testing.T) {
+	store := tempStore(t)
+	store.SetArtifactsRoot(t.TempDir())
+	job := createJob(t, store, "quiet", false, 1, "hours", "")
+	sched := New(store, noopEmit, fastExec(), time.Minute)
+
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.NoDirExists(t, store.ArtifactsDir(run.ID))
+	artifacts, err := store.GetRunArtifacts(run.ID)
+	require.NoError(t, err)
+	require.Empty(t, artifacts)
+}
@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/executor"
+	"claude-schedule/internal/transcript"
+)
+
+// judgePrompt asks a second model whether a run's result meets a criterion.
+const judgePrompt = `You are checking the result of a scheduled job run against a success criterion.
+
+Criterion:
+%s
+
+Final result of the run:
+%s
+
+Does the result meet the criterion? Reply with PASS or FAIL on the first line, then one sentence saying why.`
+
+// checkAssertions checks a finished run of job against the job's assertions
+// and returns why it failed them, if it did. cost is what the run cost so far.
+// Judges are run with the scheduler's executor; the usage they report is
+// returned so it can be counted towards the run.
+func (s *Scheduler) checkAssertions(job db.Job, result executor.ExecuteResult, cost float64) ([]string, db.Usage) {
+	var failures []string
+	var usage db.Usage
+	assertions, err := job.AssertionList()
+	if err != nil {
+		return []string{err.Error()}, usage
+	}
+	for _, a := range assertions {
+		switch a.Kind {
+		case db.AssertMatches, db.AssertNotMatches:
+			re, err := regexp.Compile(a.Value)
+			if err != nil {
+				failures = append(failures, err.Error())
+				continue
+			}
+			matched := re.MatchString(result.Summary)
+			if a.Kind == db.AssertMatches && !matched {
+				failures = append(failures, fmt.Sprintf("the result does not match /%s/", a.Value))
+			} else if a.Kind == db.AssertNotMatches && matched {
+				failures = append(failures, fmt.Sprintf("the result matches /%s/", a.Value))
+			}
+		case db.AssertToolCalled:
+			if !toolCalled(result.Events, a.Value) {
+				failures = append(failures, fmt.Sprintf("%s was not called", a.Value))
+			}
+		case db.AssertMaxCost:
+			limit, _ := strconv.ParseFloat(a.Value, 64)
+			if cost > limit {
+				failures = append(failures, fmt.Sprintf("the run cost $%.2f, more than $%.2f", cost, limit))
+			}
+		case db.AssertJudge:
+			reason, judgeUsage := s.judge(job, a.Value, result.Summary)
+			usage.Add(judgeUsage)
+			if reason != "" {
+				failures = append(failures, reason)
+			}
+		}
+	}
+	return failures, usage
+}
+
+// toolCalled reports whether events hold a call of a tool whose name matches
+// pattern, in which * matches any run of characters.
+func toolCalled(events []transcript.Event, pattern string) bool {
+	for _, e := range events {
+		if e.Kind != transcript.KindToolUse {
+			continue
+		}
+		if ok, _ := path.Match(pattern, e.Tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// judge asks a second model whether a run of job with the final result
+// summary meets criterion. It returns why not, or "" if it does. The summary
+// is untrusted, so the judge has no tools and runs under the job's sandbox,
+// limits and environment.
+func (s *Scheduler) judge(job db.Job, criterion, summary string) (string, db.Usage) {
+	judgeJob := db.Job{
+		Name:                    job.Name + " (judge)",
+		Prompt:                  fmt.Sprintf(judgePrompt, criterion, summary),
+		Executor:                executor.BackendClaude,
+		Model:                   job.Model,
+		MaxTurns:                1,
+		SessionPolicy:           "fresh",
+		SkipDefaultSystemPrompt: true,
+		WorkingDir:              job.WorkingDir,
+		Env:                     job.Env,
+		Sandbox:                 job.Sandbox,
+		SandboxNetwork:          job.SandboxNetwork,
+		SandboxHosts:            job.SandboxHosts,
+		CPULimit:                job.CPULimit,
+		MemoryLimit:             job.MemoryLimit,
+		ProcessLimit:            job.ProcessLimit,
+	}
+	if job.Executor == executor.BackendAPI {
+		judgeJob.Executor = executor.BackendAPI
+	}
+	result, err := s.execFn(s.ctx, judgeJob, nil, executor.Options{NoTools: true})
+	if err != nil {
+		return fmt.Sprintf("the judge of %q failed: %v", criterion, err), result.Usage
+	}
+
+	reply := strings.TrimSpace(result.Summary)
+	if reply == "" {
+		reply = strings.TrimSpace(result.Transcript)
+	}
+	verdict, reason, _ := strings.Cut(reply, "\n")
+	verdict = strings.ToUpper(strings.Trim(verdict, " *#.:"))
+	reason = strings.TrimSpace(reason)
+	switch {
+	case strings.HasPrefix(verdict, "PASS"):
+		return "", result.Usage
+	case strings.HasPrefix(verdict, "FAIL"):
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return fmt.Sprintf("the judge found %q not met: %s", criterion, reason), result.Usage
+	default:
+		return fmt.Sprintf("the judge of %q gave no verdict: %s", criterion, reply), result.Usage
+	}
+}
+
+// encodeFailures returns failures as the JSON stored on a run.
+func encodeFailures(failures []string) string {
+	if len(failures) == 0 {
+		return "[]"
+	}
+	data, err := json.Marshal(failures)
+	if err != nil {
+		return "[]"
+	}
+	return string(data)
+}
@@ -0,0 +1,30 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/resultdiff"
+)
+
+// maxExcerptLines is how many changed lines a notification shows.
+const maxExcerptLines = 5
+
+// compareResult compares summary, the final result of a successful run of
+// job, with the last successful run's. It returns a diff of the two and
+// whether the result changed under the job's NotifyOnChange mode. A job's
+// first result counts as changed.
+func (s *Scheduler) compareResult(job db.Job, summary string) (string, bool) {
+	prev, err := s.store.GetLastSuccessfulRun(job.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", true
+	}
+	if err != nil {
+		log.Printf("scheduler: failed to load the previous result of job %s: %v", job.ID, err)
+		return "", true
+	}
+	return resultdiff.Unified(prev.Summary, summary),
+		resultdiff.Changed(job.NotifyOnChange, job.ChangeThreshold, prev.Summary, summary)
+}
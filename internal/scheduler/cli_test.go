@@ -0,0 +1,208 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/executor"
+	"claude-schedule/internal/fakeclaude"
+	"claude-schedule/internal/transcript"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	fakeclaude.Main()
+	os.Exit(m.Run())
+}
+
+// cliScheduler returns a scheduler that runs jobs through the real executor
+// against a fake CLI playing steps.
+func cliScheduler(t *testing.T, store *db.Store, steps ...fakeclaude.Step) (*Scheduler, *fakeclaude.Fake) {
+	t.Helper()
+	fake := fakeclaude.New(t, steps...)
+	executor.SetSettings(fake.Settings())
+	t.Cleanup(func() { executor.SetSettings(db.Settings{}) })
+
+	sched := New(store, noopEmit, executor.Execute, time.Minute)
+	// RunNow and AnswerQuestion use the context Start would set up.
+	sched.ctx = context.Background()
+	return sched, fake
+}
+
+func TestCLI_QuestionAnswerAndResume(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "deploy", false, 1, "hours", "")
+	sched, fake := cliScheduler(t, store,
+		fakeclaude.Question("Which branch should I deploy?", "main", "develop"),
+		fakeclaude.Reply("Deployed main."),
+		fakeclaude.Replay("simple"),
+	)
+
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	waiting, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "waiting", waiting.Status)
+	require.Contains(t, waiting.PendingQuestion, "Which branch should I deploy?")
+	require.NotEmpty(t, waiting.SessionID)
+
+	require.NoError(t, sched.AnswerQuestion(job.ID, "main"))
+	sched.wg.Wait()
+
+	answered, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", answered.Status)
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", run.Status)
+	require.Contains(t, run.Output, "Which branch should I deploy?")
+	require.Contains(t, run.Output, "Deployed main.")
+	require.Equal(t, waiting.SessionID, run.SessionID)
+	require.Equal(t, int64(2), run.NumTurns)
+
+	// The stored events cover both invocations and reproduce the output.
+	events, err := store.GetRunEvents(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, transcript.KindQuestion, events[0].Kind)
+	require.Equal(t, "Deployed main.", events[len(events)-1].Text)
+	require.Equal(t, transcript.Version, run.RenderVersion)
+	require.Equal(t, run.Output, transcript.Render(events))
+
+	// The next scheduled run continues the same conversation.
+	time.Sleep(time.Second)
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	calls := fake.Calls()
+	require.Len(t, calls, 3)
+	require.Equal(t, waiting.SessionID, calls[0].Flag("--session-id"))
+	require.Equal(t, "main", calls[1].Prompt())
+	require.Equal(t, waiting.SessionID, calls[1].Flag("--resume"))
+	require.Equal(t, waiting.SessionID, calls[2].Flag("--resume"))
+
+	latest, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", latest.Status)
+	require.Equal(t, "All checks passed.", latest.Summary)
+	require.Equal(t, "claude-sonnet-4-5-20250929", latest.Model)
+}
+
+func TestCLI_RecoversFromLostSession(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "nightly", false, 1, "hours", "")
+	sched, fake := cliScheduler(t, store)
+
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+	first, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	fake.ForgetSession(first.SessionID)
+
+	time.Sleep(time.Second)
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	second, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", second.Status)
+	require.Equal(t, first.SessionID, second.SessionID)
+	require.True(t, fake.HasSession(first.SessionID))
+}
+
+func TestCLI_FailedRun(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "broken", false, 1, "hours", "")
+	sched, _ := cliScheduler(t, store, fakeclaude.Fail("Credit balance is too low"))
+
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", run.Status)
+	require.Equal(t, transcript.RenderError("Credit balance is too low"), run.Output)
+	require.NotEmpty(t, run.EndedAt)
+
+	// The stream is kept for debugging, but the error stays the output.
+	raw, err := store.GetRawEvents(run.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, raw)
+	_, err = sched.RerenderRun(run.ID)
+	require.Error(t, err)
+}
+
+func TestCLI_FollowUpExtendsEvents(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "report", false, 1, "hours", "")
+	sched, _ := cliScheduler(t, store, fakeclaude.Replay("tools"), fakeclaude.Reply("Only the README."))
+
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, sched.SendFollowUp(run.ID, "Anything else?"))
+	sched.wg.Wait()
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	events, err := store.GetRunEvents(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, transcript.Version, got.RenderVersion)
+	require.Equal(t, got.Output, transcript.Render(events))
+	require.Contains(t, got.Output, ">Anything else?</div>")
+	require.Contains(t, got.Output, "Only the README.")
+}
+
+func TestCLI_RerenderRunReproducesOutput(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "deploy", false, 1, "hours", "")
+	sched, _ := cliScheduler(t, store,
+		fakeclaude.Question("Which branch should I deploy?", "main", "develop"),
+		fakeclaude.Replay("tools"),
+		fakeclaude.Reply("Only the README."),
+		fakeclaude.Fail("Credit balance is too low"),
+	)
+
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+	require.NoError(t, sched.AnswerQuestion(job.ID, "main"))
+	sched.wg.Wait()
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.NoError(t, sched.SendFollowUp(run.ID, "Anything else?"))
+	sched.wg.Wait()
+	require.NoError(t, sched.SendFollowUp(run.ID, "And now?"))
+	sched.wg.Wait()
+
+	want, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Contains(t, want.Output, ">Follow-up failed</strong>")
+	require.Contains(t, want.Output, "Credit balance is too low")
+
+	// Every invocation is in the stored stream, so rendering it again gives
+	// the same output.
+	raw, err := store.GetRawEvents(run.ID)
+	require.NoError(t, err)
+	require.Contains(t, raw, executor.FollowUpLine("Anything else?"))
+
+	output := want.Output
+	want.Output, want.RenderVersion = "stale", 1
+	require.NoError(t, store.UpdateRun(want))
+	got, err := sched.RerenderRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, transcript.Version, got.RenderVersion)
+	require.Equal(t, output, got.Output)
+
+	stored, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, got.Output, stored.Output)
+	events, err := store.GetRunEvents(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, stored.Output, transcript.Render(events))
+}
@@ -2,36 +2,86 @@ package scheduler
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"claude-schedule/internal/db"
 	"claude-schedule/internal/executor"
+	"claude-schedule/internal/prompt"
+	"claude-schedule/internal/resultdiff"
+	"claude-schedule/internal/structured"
+	"claude-schedule/internal/transcript"
+	"claude-schedule/internal/webhook"
+
+	"github.com/google/uuid"
 )
 
 // EmitFunc is the signature for a Wails-style event emitter.
 type EmitFunc func(eventName string, data ...interface{})
 
-// NotifyFunc is called when a job changes status (e.g. "running", "success", "failed").
-type NotifyFunc func(jobName string, status string)
+// NotifyFunc is called when a job changes status (e.g. "running", "success",
+// "failed", "assertion_failed"). detail is an excerpt of how the result
+// changed, for jobs that compare results, and is otherwise empty.
+type NotifyFunc func(jobName string, status string, detail string)
+
+// WebhookFunc is called with the same job status changes as NotifyFunc, for
+// sending to webhooks.
+type WebhookFunc func(event webhook.Event)
 
-// ExecuteFunc defines how a job is executed. It receives the job and its
-// associated MCP servers, and returns an ExecuteResult and an error.
-type ExecuteFunc func(ctx context.Context, job db.Job, mcpServers []db.MCPServer) (executor.ExecuteResult, error)
+// ExecuteFunc defines how a job is executed. It receives the job, its
+// associated MCP servers and per-run options, and returns an ExecuteResult and
+// an error.
+type ExecuteFunc func(ctx context.Context, job db.Job, mcpServers []db.MCPServer, opts executor.Options) (executor.ExecuteResult, error)
 
 // AnswerFunc defines how a question answer is sent back to Claude.
-type AnswerFunc func(ctx context.Context, job db.Job, mcpServers []db.MCPServer, answer string) (executor.ExecuteResult, error)
+type AnswerFunc func(ctx context.Context, job db.Job, mcpServers []db.MCPServer, answer string, opts executor.Options) (executor.ExecuteResult, error)
+
+// FollowUpFunc defines how a follow-up message is sent to a finished run's
+// conversation. When fork is set the conversation in job.SessionID must be
+// forked rather than continued.
+type FollowUpFunc func(ctx context.Context, job db.Job, mcpServers []db.MCPServer, message string, fork bool, opts executor.Options) (executor.ExecuteResult, error)
+
+// Trigger describes what started a run. It is exposed to prompt templates as
+// {{.Trigger}} and {{.Payload}}.
+type Trigger struct {
+	Kind    string // one of the prompt.Trigger constants
+	Payload string // data supplied with the trigger, if any
+}
+
+// RunProgress is the payload of the "run:progress" event emitted while a run
+// streams output.
+type RunProgress struct {
+	RunID    string `json:"runId"`
+	JobID    string `json:"jobId"`
+	Fragment string `json:"fragment"`
+}
+
+// progressPersistInterval limits how often streamed output is written to the
+// run record; every fragment is still emitted as an event.
+const progressPersistInterval = time.Second
 
 // Scheduler polls the database at a fixed interval and runs due jobs sequentially.
 type Scheduler struct {
-	store    *db.Store
-	emitFn   EmitFunc
-	notifyFn NotifyFunc
-	execFn   ExecuteFunc
-	answerFn AnswerFunc
-	interval time.Duration
+	store      *db.Store
+	emitFn     EmitFunc
+	notifyFn   NotifyFunc
+	webhookFn  WebhookFunc
+	execFn     ExecuteFunc
+	answerFn   AnswerFunc
+	followUpFn FollowUpFunc
+	interval   time.Duration
+
+	worktreeRoot string // see SetWorktreeRoot
+
+	// followUps holds the IDs of runs with a follow-up or a worktree action
+	// in flight.
+	mu        sync.Mutex
+	followUps map[string]bool
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -44,13 +94,18 @@ func New(store *db.Store, emitFn EmitFunc, execFn ExecuteFunc, interval time.Dur
 	if execFn == nil {
 		execFn = mockExecute
 	}
-	return &Scheduler{
-		store:    store,
-		emitFn:   emitFn,
-		execFn:   execFn,
-		answerFn: executor.ClaudeAnswer,
-		interval: interval,
-	}
+	s := &Scheduler{
+		store:      store,
+		emitFn:     emitFn,
+		execFn:     execFn,
+		answerFn:   executor.Answer,
+		followUpFn: executor.FollowUp,
+		interval:   interval,
+		followUps:  make(map[string]bool),
+		ctx:        context.Background(), // replaced by Start
+	}
+	store.SetWorktreeRemover(s.removeWorktree)
+	return s
 }
 
 // SetNotifyFunc sets an optional callback for job status change notifications.
@@ -58,6 +113,12 @@ func (s *Scheduler) SetNotifyFunc(fn NotifyFunc) {
 	s.notifyFn = fn
 }
 
+// SetWebhookFunc sets an optional callback that sends job status changes to
+// webhooks.
+func (s *Scheduler) SetWebhookFunc(fn WebhookFunc) {
+	s.webhookFn = fn
+}
+
 // Start begins the background tick loop. It is safe to call only once.
 // It resets any jobs left in "running" state from a previous crash.
 func (s *Scheduler) Start(parent context.Context) {
@@ -117,7 +178,7 @@ func (s *Scheduler) tick() {
 		}
 
 		if isDue(jobs[i], now) {
-			s.executeJob(&jobs[i], now)
+			s.executeJob(&jobs[i], now, Trigger{Kind: prompt.TriggerSchedule})
 		}
 	}
 }
@@ -154,12 +215,61 @@ func isDue(job db.Job, now time.Time) bool {
 	return !refTime.Add(interval).After(now)
 }
 
+// startSession prepares a job's session for a new run. The current session is
+// dropped when the job's policy says it should not be continued, and a new
+// session ID is assigned when there is none.
+func startSession(job *db.Job) {
+	if sessionExpired(*job) {
+		job.SessionID = ""
+		job.SessionRuns = 0
+		job.SessionTokens = 0
+	}
+	if job.SessionID == "" {
+		job.SessionID = uuid.New().String()
+	}
+}
+
+// sessionExpired reports whether a job's current session should be replaced
+// before its next run.
+func sessionExpired(job db.Job) bool {
+	// The CLI keeps conversations per directory and each run of a worktree
+	// job works in a new one.
+	if job.Worktree {
+		return true
+	}
+	switch job.SessionPolicy {
+	case "fresh":
+		return true
+	case "rotate":
+		if job.SessionRotateRuns > 0 && job.SessionRuns >= job.SessionRotateRuns {
+			return true
+		}
+		if job.SessionMaxTokens > 0 && job.SessionTokens >= job.SessionMaxTokens {
+			return true
+		}
+	}
+	return false
+}
+
 // finishExecution processes the result of a CLI invocation, detecting questions
 // and updating job/run state accordingly.
 func (s *Scheduler) finishExecution(job *db.Job, run *db.JobRun, result executor.ExecuteResult, execErr error) {
+	// Track the conversation the CLI actually used.
+	if result.SessionID != "" {
+		job.SessionID = result.SessionID
+	}
+	if result.ContextTokens > 0 {
+		job.SessionTokens = result.ContextTokens
+	}
+
+	// Why the run failed the job's assertions, and what judging it cost.
+	var failures []string
+	var judgeUsage db.Usage
+	outcome := ""
+	resultDiff := ""
 	if execErr != nil {
 		job.Status = "failed"
-		job.Output = execErr.Error()
+		job.Output = transcript.RenderError(execErr.Error())
 		job.PendingQuestion = ""
 	} else {
 		// Check for a pending question in the raw output.
@@ -172,6 +282,29 @@ func (s *Scheduler) finishExecution(job *db.Job, run *db.JobRun, result executor
 			job.Status = "success"
 			job.Output = result.Transcript
 			job.PendingQuestion = ""
+			cost := result.Usage.CostUSD
+			if run != nil {
+				cost += run.Usage.CostUSD
+			}
+			failures, judgeUsage = s.checkAssertions(*job, result, cost)
+			if len(failures) > 0 {
+				job.Status = "assertion_failed"
+			} else if job.ReportOutcome {
+				// Only jobs asked to report an outcome are held to one; any
+				// other result may mention an outcome in passing.
+				outcome = executor.DetectOutcome(result.Summary)
+			}
+			if job.Status == "success" && job.NotifyOnChange != "" {
+				var changed bool
+				resultDiff, changed = s.compareResult(*job, result.Summary)
+				// An outcome the run reported outranks the comparison.
+				if outcome == "" {
+					outcome = db.OutcomeUnchanged
+					if changed {
+						outcome = db.OutcomeChanged
+					}
+				}
+			}
 		}
 	}
 
@@ -184,22 +317,60 @@ func (s *Scheduler) finishExecution(job *db.Job, run *db.JobRun, result executor
 		run.Status = job.Status
 		run.Output = job.Output
 		run.PendingQuestion = job.PendingQuestion
+		if result.Model != "" {
+			run.Model = result.Model
+		}
+		if result.SessionID != "" {
+			run.SessionID = result.SessionID
+		}
+		if result.Summary != "" {
+			run.Summary = result.Summary
+		}
+		run.StructuredOutput = result.StructuredOutput
+		run.AssertionFailures = encodeFailures(failures)
+		run.Outcome = outcome
+		run.ResultDiff = resultDiff
+		run.FailureReason = ""
+		var limitErr *executor.LimitError
+		var invalid *structured.ValidationError
+		switch {
+		case errors.As(execErr, &limitErr):
+			run.FailureReason = limitErr.Reason
+		case errors.As(execErr, &invalid):
+			run.FailureReason = db.FailureOutputSchema
+		}
+		s.recordWorktree(run)
+		// The output can be rendered again from the events only when it was
+		// rendered from them in the first place.
+		run.Events = transcript.Encode(result.Events)
+		run.RenderVersion = 0
+		if execErr == nil && len(result.Events) > 0 {
+			run.RenderVersion = transcript.Version
+		}
+		// Answers resume the same run, so usage accumulates across invocations.
+		run.Usage.Add(result.Usage)
+		run.Usage.Add(judgeUsage)
 		if job.Status != "waiting" {
 			run.EndedAt = time.Now().UTC().Format(time.RFC3339)
 		}
 		if err := s.store.UpdateRun(*run); err != nil {
 			log.Printf("scheduler: failed to update run %s: %v", run.ID, err)
 		}
+		s.saveRawEvents(run.ID, result.RawLines)
+		s.indexArtifacts(run.ID)
 		if err := s.store.PruneRuns(job.ID); err != nil {
 			log.Printf("scheduler: failed to prune runs for job %s: %v", job.ID, err)
 		}
 	}
 
 	s.emit()
-	s.notify(job.Name, job.Status)
+	// Runs that report nothing changed are not worth interrupting anyone for.
+	if outcome != db.OutcomeUnchanged && outcome != db.OutcomeNoOp {
+		s.notify(job, run, job.Status, resultdiff.Excerpt(resultDiff, maxExcerptLines))
+	}
 }
 
-func (s *Scheduler) executeJob(job *db.Job, now time.Time) {
+func (s *Scheduler) executeJob(job *db.Job, now time.Time, trigger Trigger) {
 	// Mark as running.
 	job.Status = "running"
 	job.Output = ""
@@ -209,13 +380,37 @@ func (s *Scheduler) executeJob(job *db.Job, now time.Time) {
 		return
 	}
 	s.emit()
-	s.notify(job.Name, "running")
+	// Jobs that report an outcome only notify once it is known.
+	if !job.ReportOutcome && job.NotifyOnChange == "" {
+		s.notify(job, nil, "running", "")
+	}
+
+	// Pick the conversation this run continues, for backends that keep one.
+	var sessionID string
+	if executor.UsesSessions(job.Executor) {
+		startSession(job)
+		sessionID = job.SessionID
+	}
+
+	// The run's ID names its artifacts directory, which the prompt may refer
+	// to, so it is picked before the run is recorded.
+	runID := uuid.New().String()
+	artifactsDir := s.prepareArtifacts(runID)
+
+	// Render the prompt before the new run replaces the previous one as the
+	// latest. The stored job keeps the template.
+	runJob, renderErr := s.renderPrompt(*job, trigger, now, artifactsDir)
+	if renderErr == nil {
+		runJob, renderErr = withArtifacts(runJob, artifactsDir)
+	}
 
 	// Create a run record.
 	run, err := s.store.CreateRun(db.JobRun{
+		ID:        runID,
 		JobID:     job.ID,
 		StartedAt: now.Format(time.RFC3339),
 		Status:    "running",
+		SessionID: sessionID,
 	})
 	if err != nil {
 		log.Printf("scheduler: failed to create run for job %s: %v", job.ID, err)
@@ -227,8 +422,31 @@ func (s *Scheduler) executeJob(job *db.Job, now time.Time) {
 		log.Printf("scheduler: failed to load MCP servers for job %s: %v", job.ID, err)
 	}
 
+	// Runs of worktree jobs work on a branch of their own.
+	execErr := renderErr
+	if execErr == nil && job.Worktree {
+		if err := s.startWorktree(*job, &run); err != nil {
+			execErr = fmt.Errorf("creating worktree: %w", err)
+		} else {
+			runJob.WorkingDir = worktreeWorkingDir(*job, run.Worktree)
+			if err := s.store.UpdateRun(run); err != nil {
+				log.Printf("scheduler: failed to update run %s: %v", run.ID, err)
+			}
+		}
+	}
+
 	// Execute.
-	result, execErr := s.execFn(s.ctx, *job, mcpServers)
+	var result executor.ExecuteResult
+	if execErr == nil {
+		result, execErr = s.execFn(s.ctx, runJob, mcpServers, executor.Options{
+			OnProgress: s.progressFunc(run),
+		})
+	}
+
+	// A session counts as used once the CLI has reported it.
+	if result.SessionID != "" {
+		job.SessionRuns++
+	}
 
 	// Update timing fields.
 	job.LastRun = now.Format(time.RFC3339)
@@ -238,21 +456,118 @@ func (s *Scheduler) executeJob(job *db.Job, now time.Time) {
 	s.finishExecution(job, &run, result, execErr)
 }
 
+// renderPrompt returns job with its prompt template rendered for a run
+// started now by trigger that keeps its files in artifactsDir.
+func (s *Scheduler) renderPrompt(job db.Job, trigger Trigger, now time.Time, artifactsDir string) (db.Job, error) {
+	loc, err := prompt.LoadLocation(job.Timezone)
+	if err != nil {
+		return job, err
+	}
+	vars, err := job.VariableMap()
+	if err != nil {
+		return job, err
+	}
+	data := prompt.Data{
+		JobName:      job.Name,
+		Now:          now.In(loc),
+		Trigger:      trigger.Kind,
+		Payload:      trigger.Payload,
+		ArtifactsDir: artifactsDir,
+		Vars:         vars,
+	}
+
+	if job.ID != "" {
+		prev, err := s.store.GetLatestRun(job.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("scheduler: failed to load previous run for job %s: %v", job.ID, err)
+		}
+		if t, err := parseTime(prev.StartedAt); err == nil {
+			data.LastRun = t.In(loc)
+			data.LastStatus = prev.Status
+			data.LastSummary = prev.Summary
+		}
+	}
+
+	job.Prompt, err = prompt.Render(job.Prompt, data)
+	return job, err
+}
+
+// PreviewPrompt renders job's prompt as a run started now would see it.
+func (s *Scheduler) PreviewPrompt(job db.Job) (string, error) {
+	rendered, err := s.renderPrompt(job, Trigger{Kind: prompt.TriggerPreview}, time.Now().UTC(), s.store.ArtifactsDir("<run-id>"))
+	if err != nil {
+		return "", err
+	}
+	return rendered.Prompt, nil
+}
+
+// progressFunc returns a callback that appends streamed transcript fragments
+// to the run's output, persists it at most every progressPersistInterval, and
+// emits a "run:progress" event per fragment. Output already on the run (e.g.
+// before an answered question) is kept as a prefix.
+func (s *Scheduler) progressFunc(run db.JobRun) func(string) {
+	if run.ID == "" {
+		return nil
+	}
+	var lastPersist time.Time
+	separated := run.Output == ""
+	return func(fragment string) {
+		if !separated {
+			run.Output += "\n\n"
+			separated = true
+		}
+		run.Output += fragment
+
+		if time.Since(lastPersist) >= progressPersistInterval {
+			if err := s.store.UpdateRun(run); err != nil {
+				log.Printf("scheduler: failed to persist progress for run %s: %v", run.ID, err)
+			}
+			lastPersist = time.Now()
+		}
+
+		if s.emitFn != nil {
+			s.emitFn("run:progress", RunProgress{RunID: run.ID, JobID: run.JobID, Fragment: fragment})
+		}
+	}
+}
+
 func (s *Scheduler) emit() {
 	if s.emitFn != nil {
 		s.emitFn("jobs:updated")
 	}
 }
 
-func (s *Scheduler) notify(jobName string, status string) {
+// notify reports that job, on run if it has started one, reached status.
+func (s *Scheduler) notify(job *db.Job, run *db.JobRun, status string, detail string) {
 	if s.notifyFn != nil {
-		s.notifyFn(jobName, status)
+		s.notifyFn(job.Name, status, detail)
+	}
+	if s.webhookFn != nil {
+		event := webhook.Event{
+			Job:     job.Name,
+			JobID:   job.ID,
+			Status:  status,
+			Changes: detail,
+			Time:    time.Now().UTC(),
+		}
+		if run != nil {
+			event.RunID = run.ID
+			event.Outcome = run.Outcome
+			event.Summary = run.Summary
+		}
+		s.webhookFn(event)
 	}
 }
 
 // RunNow triggers immediate execution of the given job in the background.
 // Returns an error if the job is already running.
 func (s *Scheduler) RunNow(jobID string) error {
+	return s.RunNowWithPayload(jobID, "")
+}
+
+// RunNowWithPayload is like RunNow but makes payload available to the job's
+// prompt template as {{.Payload}}.
+func (s *Scheduler) RunNowWithPayload(jobID string, payload string) error {
 	job, err := s.store.GetJob(jobID)
 	if err != nil {
 		return err
@@ -264,7 +579,7 @@ func (s *Scheduler) RunNow(jobID string) error {
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
-		s.executeJob(&job, time.Now().UTC())
+		s.executeJob(&job, time.Now().UTC(), Trigger{Kind: prompt.TriggerManual, Payload: payload})
 	}()
 
 	return nil
@@ -308,17 +623,39 @@ func (s *Scheduler) AnswerQuestion(jobID string, answer string) error {
 		log.Printf("scheduler: failed to load MCP servers for job %s: %v", jobID, err)
 	}
 
+	// The resumed conversation keeps writing to the run's artifacts.
+	answerJob, err := withArtifacts(job, s.prepareArtifacts(run.ID))
+	if err != nil {
+		log.Printf("scheduler: failed to pass artifacts directory to job %s: %v", jobID, err)
+		answerJob = job
+	}
+	if run.WorktreeState == db.WorktreeActive {
+		answerJob.WorkingDir = worktreeWorkingDir(job, run.Worktree)
+	}
+
 	// Resume the conversation with the answer.
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
 
-		result, execErr := s.answerFn(s.ctx, job, mcpServers, answer)
+		result, execErr := s.answerFn(s.ctx, answerJob, mcpServers, answer, executor.Options{
+			OnProgress: s.progressFunc(run),
+		})
 
 		// Append new output to the existing run output.
 		if run.ID != "" && execErr == nil {
 			result.Transcript = run.Output + "\n\n" + result.Transcript
 		}
+		// Likewise the events, unless the output so far did not come from
+		// them and the combined events would not reproduce it.
+		if run.ID != "" {
+			prev, err := transcript.Decode(run.Events)
+			if err == nil && (run.RenderVersion > 0 || execErr != nil) {
+				result.Events = append(prev, result.Events...)
+			} else {
+				result.Events = nil
+			}
+		}
 
 		s.finishExecution(&job, &run, result, execErr)
 	}()
@@ -326,6 +663,183 @@ func (s *Scheduler) AnswerQuestion(jobID string, answer string) error {
 	return nil
 }
 
+// SendFollowUp sends a message to a finished run's conversation in the
+// background and appends the exchange to the run's output, emitting
+// "run:followup" with the run ID once the reply is recorded. The first
+// follow-up forks the run's session and later ones continue that fork, so
+// the job's own session is never affected.
+func (s *Scheduler) SendFollowUp(runID string, message string) error {
+	if strings.TrimSpace(message) == "" {
+		return fmt.Errorf("follow-up message is required")
+	}
+	run, err := s.store.GetRun(runID)
+	if err != nil {
+		return fmt.Errorf("loading run: %w", err)
+	}
+	if run.Status == "running" || run.Status == "waiting" {
+		return fmt.Errorf("run is still in progress")
+	}
+	if run.SessionID == "" {
+		return fmt.Errorf("run has no conversation to follow up")
+	}
+	if run.Worktree != "" && run.WorktreeState != db.WorktreeActive {
+		return fmt.Errorf("the run's worktree has been removed")
+	}
+	job, err := s.store.GetJob(run.JobID)
+	if err != nil {
+		return fmt.Errorf("loading job: %w", err)
+	}
+
+	s.mu.Lock()
+	if s.followUps[runID] {
+		s.mu.Unlock()
+		return fmt.Errorf("a follow-up is already in progress for this run")
+	}
+	s.followUps[runID] = true
+	s.mu.Unlock()
+
+	mcpServers, err := s.store.GetMCPServersForJob(job.ID)
+	if err != nil {
+		log.Printf("scheduler: failed to load MCP servers for job %s: %v", job.ID, err)
+	}
+
+	// Continue the run's fork if it has one, otherwise fork the run's session.
+	fork := run.FollowUpSessionID == ""
+	job.SessionID = run.SessionID
+	if !fork {
+		job.SessionID = run.FollowUpSessionID
+	}
+	if run.Worktree != "" {
+		job.WorkingDir = worktreeWorkingDir(job, run.Worktree)
+	}
+	if withDir, err := withArtifacts(job, s.prepareArtifacts(run.ID)); err != nil {
+		log.Printf("scheduler: failed to pass artifacts directory to job %s: %v", job.ID, err)
+	} else {
+		job = withDir
+	}
+
+	// Record the message before the reply streams in.
+	appendEvents(&run, transcript.Event{Kind: transcript.KindFollowUp, Time: time.Now().UTC(), Text: message})
+	if err := s.store.UpdateRun(run); err != nil {
+		log.Printf("scheduler: failed to update run %s: %v", run.ID, err)
+	}
+	s.emit()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.followUps, runID)
+			s.mu.Unlock()
+		}()
+
+		result, execErr := s.followUpFn(s.ctx, job, mcpServers, message, fork, executor.Options{
+			OnProgress: s.progressFunc(run),
+		})
+
+		if fork && result.SessionID != "" {
+			run.FollowUpSessionID = result.SessionID
+		}
+		run.Usage.Add(result.Usage)
+		switch {
+		case execErr != nil:
+			appendEvents(&run, transcript.Event{Kind: transcript.KindFollowUpError, Time: time.Now().UTC(), Text: execErr.Error()})
+		case len(result.Events) > 0:
+			appendEvents(&run, result.Events...)
+		default:
+			// Output the events cannot reproduce.
+			run.Output = appendSection(run.Output, result.Transcript)
+			run.RenderVersion = 0
+		}
+		s.recordWorktree(&run)
+		if err := s.store.UpdateRun(run); err != nil {
+			log.Printf("scheduler: failed to update run %s: %v", run.ID, err)
+		}
+		raw := append([]string{executor.FollowUpLine(message)}, result.RawLines...)
+		if execErr != nil {
+			raw = append(raw, executor.FollowUpErrorLine(execErr))
+		}
+		s.saveRawEvents(run.ID, raw)
+		s.indexArtifacts(run.ID)
+		s.emit()
+		if s.emitFn != nil {
+			s.emitFn("run:followup", run.ID)
+		}
+	}()
+
+	return nil
+}
+
+// saveRawEvents adds an invocation's output to the stream stored with a run.
+func (s *Scheduler) saveRawEvents(runID string, lines []string) {
+	if err := s.store.AppendRawEvents(runID, lines); err != nil {
+		log.Printf("scheduler: failed to store raw events of run %s: %v", runID, err)
+	}
+}
+
+// RerenderRun parses the stream stored with a finished run again and renders
+// its output with the current renderer, so runs recorded before a change to
+// either pick it up. Failed runs keep their error as output.
+func (s *Scheduler) RerenderRun(runID string) (db.JobRun, error) {
+	run, err := s.store.GetRun(runID)
+	if err != nil {
+		return db.JobRun{}, fmt.Errorf("loading run: %w", err)
+	}
+	switch run.Status {
+	case "running", "waiting":
+		return db.JobRun{}, fmt.Errorf("run is still in progress")
+	case "failed":
+		return db.JobRun{}, fmt.Errorf("run failed; its output is the error")
+	}
+	s.mu.Lock()
+	inFollowUp := s.followUps[runID]
+	s.mu.Unlock()
+	if inFollowUp {
+		return db.JobRun{}, fmt.Errorf("a follow-up is in progress for this run")
+	}
+
+	lines, err := s.store.GetRawEvents(runID)
+	if err != nil {
+		return db.JobRun{}, fmt.Errorf("loading raw events: %w", err)
+	}
+	events := executor.Rebuild(lines)
+	if len(events) == 0 {
+		return db.JobRun{}, fmt.Errorf("run has no stored events to render")
+	}
+
+	run.Events = transcript.Encode(events)
+	run.Output = transcript.Render(events)
+	run.RenderVersion = transcript.Version
+	if err := s.store.UpdateRun(run); err != nil {
+		return db.JobRun{}, err
+	}
+	s.emit()
+	return run, nil
+}
+
+// appendEvents adds events to a run's transcript and appends their rendering
+// to its output.
+func appendEvents(run *db.JobRun, events ...transcript.Event) {
+	if section := transcript.Render(events); section != "" {
+		run.Output = appendSection(run.Output, section)
+	}
+	prev, err := transcript.Decode(run.Events)
+	if err != nil {
+		log.Printf("scheduler: run %s has invalid events: %v", run.ID, err)
+		return
+	}
+	run.Events = transcript.Encode(append(prev, events...))
+}
+
+// appendSection appends section to output, separated by a blank line.
+func appendSection(output, section string) string {
+	if output == "" {
+		return section
+	}
+	return output + "\n\n" + section
+}
+
 // intervalDuration converts the stored interval value+unit to a time.Duration.
 func intervalDuration(value int, unit string) time.Duration {
 	switch unit {
@@ -352,7 +866,7 @@ func parseTime(s string) (time.Time, error) {
 }
 
 // mockExecute is the default executor: sleeps for 30 seconds.
-func mockExecute(ctx context.Context, _ db.Job, _ []db.MCPServer) (executor.ExecuteResult, error) {
+func mockExecute(ctx context.Context, _ db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
 	select {
 	case <-time.After(30 * time.Second):
 		return executor.ExecuteResult{Transcript: "Mock execution completed successfully."}, nil
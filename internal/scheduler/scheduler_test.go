@@ -2,13 +2,19 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"claude-schedule/internal/db"
 	"claude-schedule/internal/executor"
+	"claude-schedule/internal/structured"
+	"claude-schedule/internal/webhook"
 
 	"github.com/stretchr/testify/require"
 )
@@ -48,7 +54,7 @@ func createJob(t *testing.T, store *db.Store, name string, active bool, interval
 
 // fastExec returns an executor that completes instantly.
 func fastExec() ExecuteFunc {
-	return func(_ context.Context, _ db.Job, _ []db.MCPServer) (executor.ExecuteResult, error) {
+	return func(_ context.Context, _ db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
 		return executor.ExecuteResult{Transcript: "done"}, nil
 	}
 }
@@ -254,3 +260,520 @@ func TestParseTime(t *testing.T) {
 	_, err = parseTime("not-a-date")
 	require.Error(t, err)
 }
+
+func TestSchedulerRecordsRunModel(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "model-job", true, 1, "minutes", pastTime(10*time.Minute))
+
+	exec := func(_ context.Context, _ db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		return executor.ExecuteResult{Transcript: "done", Model: "claude-haiku-4-5"}, nil
+	}
+
+	sched := New(store, noopEmit, exec, 50*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	sched.Start(ctx)
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	sched.Stop()
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", run.Status)
+	require.Equal(t, "claude-haiku-4-5", run.Model)
+}
+
+func TestSchedulerStreamsRunProgress(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "stream-job", true, 1, "minutes", pastTime(10*time.Minute))
+
+	var mu sync.Mutex
+	var fragments []string
+	emit := func(name string, data ...interface{}) {
+		if name != "run:progress" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		p := data[0].(RunProgress)
+		require.Equal(t, job.ID, p.JobID)
+		require.NotEmpty(t, p.RunID)
+		fragments = append(fragments, p.Fragment)
+	}
+
+	var midRunOutput string
+	exec := func(_ context.Context, j db.Job, _ []db.MCPServer, opts executor.Options) (executor.ExecuteResult, error) {
+		opts.OnProgress("first ")
+		run, err := store.GetLatestRun(j.ID)
+		require.NoError(t, err)
+		midRunOutput = run.Output
+		opts.OnProgress("second")
+		return executor.ExecuteResult{Transcript: "first second"}, nil
+	}
+
+	sched := New(store, emit, exec, 50*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	sched.Start(ctx)
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	sched.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"first ", "second"}, fragments)
+	// The first fragment is persisted while the run is still executing.
+	require.Equal(t, "first ", midRunOutput)
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "first second", run.Output)
+}
+
+func TestSessionExpired(t *testing.T) {
+	tests := []struct {
+		name string
+		job  db.Job
+		want bool
+	}{
+		{"resume", db.Job{SessionPolicy: "resume", SessionRuns: 100}, false},
+		{"fresh", db.Job{SessionPolicy: "fresh"}, true},
+		{"rotate below limits", db.Job{SessionPolicy: "rotate", SessionRotateRuns: 3, SessionRuns: 2}, false},
+		{"rotate run limit", db.Job{SessionPolicy: "rotate", SessionRotateRuns: 3, SessionRuns: 3}, true},
+		{"rotate token limit", db.Job{SessionPolicy: "rotate", SessionMaxTokens: 1000, SessionTokens: 1200}, true},
+		{"worktree", db.Job{SessionPolicy: "resume", Worktree: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, sessionExpired(tt.job))
+		})
+	}
+}
+
+// runSessions runs job n times and returns the session each run was given.
+func runSessions(t *testing.T, store *db.Store, jobID string, n int) []db.Job {
+	t.Helper()
+	var seen []db.Job
+	exec := func(_ context.Context, job db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		seen = append(seen, job)
+		return executor.ExecuteResult{Transcript: "done", SessionID: job.SessionID, ContextTokens: 100}, nil
+	}
+
+	sched := New(store, noopEmit, exec, time.Minute)
+	for i := 0; i < n; i++ {
+		require.NoError(t, sched.RunNow(jobID))
+		sched.wg.Wait()
+	}
+	return seen
+}
+
+func TestSchedulerResumesSession(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "resume-job", false, 1, "hours", "")
+
+	seen := runSessions(t, store, job.ID, 3)
+	require.Len(t, seen, 3)
+	require.NotEmpty(t, seen[0].SessionID)
+	for i, j := range seen {
+		require.Equal(t, seen[0].SessionID, j.SessionID)
+		require.Equal(t, i, j.SessionRuns)
+	}
+
+	updated, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, updated.SessionRuns)
+	require.Equal(t, int64(100), updated.SessionTokens)
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, seen[0].SessionID, run.SessionID)
+}
+
+func TestSchedulerFreshSessionEachRun(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "fresh-job", false, 1, "hours", "")
+	job.SessionPolicy = "fresh"
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	seen := runSessions(t, store, job.ID, 2)
+	require.Len(t, seen, 2)
+	require.NotEqual(t, seen[0].SessionID, seen[1].SessionID)
+	require.Zero(t, seen[1].SessionRuns)
+}
+
+func TestSchedulerRotatesSession(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "rotate-job", false, 1, "hours", "")
+	job.SessionPolicy = "rotate"
+	job.SessionRotateRuns = 2
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	seen := runSessions(t, store, job.ID, 3)
+	require.Len(t, seen, 3)
+	require.Equal(t, seen[0].SessionID, seen[1].SessionID)
+	require.NotEqual(t, seen[1].SessionID, seen[2].SessionID)
+	require.Zero(t, seen[2].SessionRuns)
+}
+
+// finishedRun creates a completed run for job that used sessionID.
+func finishedRun(t *testing.T, store *db.Store, job db.Job, sessionID string) db.JobRun {
+	t.Helper()
+	run, err := store.CreateRun(db.JobRun{
+		JobID:     job.ID,
+		StartedAt: pastTime(time.Minute),
+		EndedAt:   pastTime(time.Second),
+		Status:    "success",
+		Output:    "first answer",
+		SessionID: sessionID,
+	})
+	require.NoError(t, err)
+	return run
+}
+
+func TestSendFollowUpForksRunSession(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "follow-up-job", false, 1, "hours", "")
+	job.SessionID = "job-session"
+	job.SessionRuns = 4
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+	run := finishedRun(t, store, job, "run-session")
+
+	type call struct {
+		sessionID string
+		fork      bool
+	}
+	var calls []call
+	sched := New(store, noopEmit, fastExec(), time.Minute)
+	sched.followUpFn = func(_ context.Context, job db.Job, _ []db.MCPServer, message string, fork bool, _ executor.Options) (executor.ExecuteResult, error) {
+		calls = append(calls, call{job.SessionID, fork})
+		return executor.ExecuteResult{Transcript: "reply to " + message, SessionID: "fork-session"}, nil
+	}
+
+	require.NoError(t, sched.SendFollowUp(run.ID, "dig deeper"))
+	sched.wg.Wait()
+	require.NoError(t, sched.SendFollowUp(run.ID, "and again"))
+	sched.wg.Wait()
+
+	require.Equal(t, []call{{"run-session", true}, {"fork-session", false}}, calls)
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", got.Status)
+	require.Equal(t, "run-session", got.SessionID)
+	require.Equal(t, "fork-session", got.FollowUpSessionID)
+	require.True(t, strings.HasPrefix(got.Output, "first answer\n\n"))
+	require.Contains(t, got.Output, ">dig deeper</div></div>\n\nreply to dig deeper")
+	require.Contains(t, got.Output, ">and again</div></div>\n\nreply to and again")
+
+	// The job's own session is untouched.
+	updated, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "job-session", updated.SessionID)
+	require.Equal(t, 4, updated.SessionRuns)
+}
+
+func TestSendFollowUpRecordsFailure(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "follow-up-fail", false, 1, "hours", "")
+	run := finishedRun(t, store, job, "run-session")
+
+	sched := New(store, noopEmit, fastExec(), time.Minute)
+	sched.followUpFn = func(_ context.Context, _ db.Job, _ []db.MCPServer, _ string, _ bool, _ executor.Options) (executor.ExecuteResult, error) {
+		return executor.ExecuteResult{}, fmt.Errorf("claude exited")
+	}
+
+	require.NoError(t, sched.SendFollowUp(run.ID, "why?"))
+	sched.wg.Wait()
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", got.Status)
+	require.Empty(t, got.FollowUpSessionID)
+	require.Contains(t, got.Output, ">Follow-up failed</strong>")
+	require.Contains(t, got.Output, "claude exited")
+}
+
+func TestSendFollowUpRejectsUnfinishedRun(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "follow-up-busy", false, 1, "hours", "")
+	sched := New(store, noopEmit, fastExec(), time.Minute)
+
+	running, err := store.CreateRun(db.JobRun{JobID: job.ID, StartedAt: pastTime(time.Minute), Status: "running", SessionID: "s"})
+	require.NoError(t, err)
+	require.Error(t, sched.SendFollowUp(running.ID, "hello"))
+
+	noSession := finishedRun(t, store, job, "")
+	require.Error(t, sched.SendFollowUp(noSession.ID, "hello"))
+
+	done := finishedRun(t, store, job, "s")
+	require.Error(t, sched.SendFollowUp(done.ID, "   "))
+}
+
+func TestSchedulerRendersPromptTemplate(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "template-job", false, 1, "hours", "")
+	job.Prompt = `{{.JobName}} {{.Vars.repo}} {{.Trigger}} {{.Payload}} [{{.LastStatus}}: {{.LastSummary}}]`
+	job.Variables = `{"repo":"acme/api"}`
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	var prompts []string
+	exec := func(_ context.Context, job db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		prompts = append(prompts, job.Prompt)
+		return executor.ExecuteResult{Transcript: "done", Summary: "all good"}, nil
+	}
+	sched := New(store, noopEmit, exec, time.Minute)
+
+	require.NoError(t, sched.RunNowWithPayload(job.ID, "v1.2"))
+	sched.wg.Wait()
+	// Keep the two runs' start times distinct so the first is the latest.
+	time.Sleep(time.Second)
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	require.Equal(t, []string{
+		"template-job acme/api manual v1.2 [: ]",
+		"template-job acme/api manual  [success: all good]",
+	}, prompts)
+
+	// The stored job keeps its template.
+	updated, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, job.Prompt, updated.Prompt)
+}
+
+func TestSchedulerFailsRunOnTemplateError(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "bad-template", false, 1, "hours", "")
+	job.Prompt = `{{.Vars.missing}}`
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	called := false
+	exec := func(_ context.Context, _ db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		called = true
+		return executor.ExecuteResult{Transcript: "done"}, nil
+	}
+	sched := New(store, noopEmit, exec, time.Minute)
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	require.False(t, called)
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", run.Status)
+	require.Contains(t, run.Output, "rendering prompt")
+}
+
+func TestSchedulerRecordsLimitHit(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "hungry", false, 1, "hours", "")
+
+	exec := func(_ context.Context, _ db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		return executor.ExecuteResult{}, &executor.LimitError{
+			Reason: db.FailureMemoryLimit,
+			Limit:  512,
+			Err:    errors.New("claude: signal: killed"),
+		}
+	}
+	sched := New(store, noopEmit, exec, time.Minute)
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", run.Status)
+	require.Equal(t, db.FailureMemoryLimit, run.FailureReason)
+	require.Contains(t, run.Output, "ran out of memory at its limit of 512 MB")
+}
+
+func TestSchedulerRecordsStructuredOutput(t *testing.T) {
+	store := tempStore(t)
+	valid := createJob(t, store, "valid", false, 1, "hours", "")
+	invalid := createJob(t, store, "invalid", false, 1, "hours", "")
+
+	exec := func(_ context.Context, job db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		if job.ID == invalid.ID {
+			return executor.ExecuteResult{Summary: "no JSON"}, &structured.ValidationError{
+				Problems: []string{"the result does not end with a JSON value"},
+			}
+		}
+		return executor.ExecuteResult{Transcript: "done", StructuredOutput: `{"changed":true}`}, nil
+	}
+	sched := New(store, noopEmit, exec, time.Minute)
+	require.NoError(t, sched.RunNow(valid.ID))
+	sched.wg.Wait()
+	require.NoError(t, sched.RunNow(invalid.ID))
+	sched.wg.Wait()
+
+	run, err := store.GetLatestRun(valid.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", run.Status)
+	require.Equal(t, `{"changed":true}`, run.StructuredOutput)
+	require.Empty(t, run.FailureReason)
+
+	run, err = store.GetLatestRun(invalid.ID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", run.Status)
+	require.Equal(t, db.FailureOutputSchema, run.FailureReason)
+	require.Empty(t, run.StructuredOutput)
+	require.Contains(t, run.Output, "does not match the output schema")
+}
+
+func TestPreviewPrompt(t *testing.T) {
+	store := tempStore(t)
+	sched := New(store, noopEmit, fastExec(), time.Minute)
+
+	got, err := sched.PreviewPrompt(db.Job{
+		Name:     "unsaved",
+		Prompt:   `{{.JobName}} in {{.Now.Location}} via {{.Trigger}}`,
+		Timezone: "Asia/Tokyo",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "unsaved in Asia/Tokyo via preview", got)
+
+	_, err = sched.PreviewPrompt(db.Job{Prompt: `{{.Nope}}`})
+	require.Error(t, err)
+}
+
+func TestSchedulerSkipsSessionsForSessionlessBackends(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "shell-job", false, 1, "hours", "")
+	job.Executor = executor.BackendShell
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	var seen db.Job
+	exec := func(_ context.Context, job db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		seen = job
+		return executor.ExecuteResult{Transcript: "done"}, nil
+	}
+	sched := New(store, noopEmit, exec, time.Minute)
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	require.Empty(t, seen.SessionID)
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", run.Status)
+	require.Empty(t, run.SessionID)
+}
+
+func TestSchedulerNotifiesOnlyOnChange(t *testing.T) {
+	store := tempStore(t)
+	changed := createJob(t, store, "changed", false, 1, "hours", "")
+	unchanged := createJob(t, store, "unchanged", false, 1, "hours", "")
+	quoting := createJob(t, store, "quoting", false, 1, "hours", "")
+	for _, job := range []db.Job{changed, unchanged} {
+		job.ReportOutcome = true
+		_, err := store.UpdateJob(job)
+		require.NoError(t, err)
+	}
+
+	exec := func(_ context.Context, job db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		switch job.ID {
+		case unchanged.ID:
+			return executor.ExecuteResult{Transcript: "done", Summary: "Same three plans.\n\nOUTCOME: unchanged"}, nil
+		case quoting.ID:
+			// Not asked to report an outcome, so this is just text.
+			return executor.ExecuteResult{Transcript: "done", Summary: "The log reads:\nOutcome: unchanged"}, nil
+		}
+		return executor.ExecuteResult{Transcript: "done", Summary: "A fourth plan.\n\nOUTCOME: changed"}, nil
+	}
+	var notified []string
+	sched := New(store, noopEmit, exec, time.Minute)
+	sched.SetNotifyFunc(func(name string, status string, _ string) { notified = append(notified, name+" "+status) })
+	for _, job := range []db.Job{changed, unchanged, quoting} {
+		require.NoError(t, sched.RunNow(job.ID))
+		sched.wg.Wait()
+	}
+
+	run, err := store.GetLatestRun(changed.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", run.Status)
+	require.Equal(t, db.OutcomeChanged, run.Outcome)
+
+	run, err = store.GetLatestRun(unchanged.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", run.Status)
+	require.Equal(t, db.OutcomeUnchanged, run.Outcome)
+
+	run, err = store.GetLatestRun(quoting.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", run.Status)
+	require.Empty(t, run.Outcome)
+
+	require.Equal(t, []string{"changed success", "quoting running", "quoting success"}, notified)
+}
+
+func TestSchedulerNotifiesOnResultChange(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "pricing", false, 1, "hours", "")
+	job.NotifyOnChange = "whitespace"
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	results := []string{"Free\nPro $20", "Free\n\nPro  $20", "Free\nPro $25"}
+	var calls int
+	exec := func(_ context.Context, _ db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		summary := results[calls]
+		calls++
+		return executor.ExecuteResult{Transcript: summary, Summary: summary}, nil
+	}
+	type notification struct{ status, detail string }
+	var notified []notification
+	sched := New(store, noopEmit, exec, time.Minute)
+	sched.SetNotifyFunc(func(_ string, status string, detail string) {
+		notified = append(notified, notification{status, detail})
+	})
+
+	var runs []db.JobRun
+	for range results {
+		require.NoError(t, sched.RunNow(job.ID))
+		sched.wg.Wait()
+		run, err := store.GetLatestRun(job.ID)
+		require.NoError(t, err)
+		runs = append(runs, run)
+		// Runs are ordered by their start time, which has one-second resolution.
+		time.Sleep(time.Second)
+	}
+
+	require.Equal(t, db.OutcomeChanged, runs[0].Outcome, "the first result counts as a change")
+	require.Empty(t, runs[0].ResultDiff)
+	require.Equal(t, db.OutcomeUnchanged, runs[1].Outcome)
+	require.Contains(t, runs[1].ResultDiff, "+Pro  $20", "the diff shows any difference")
+	require.Equal(t, db.OutcomeChanged, runs[2].Outcome)
+	require.Contains(t, runs[2].ResultDiff, "-Pro  $20\n")
+	require.Contains(t, runs[2].ResultDiff, "+Pro $25\n")
+
+	require.Len(t, notified, 2)
+	require.Equal(t, notification{"success", ""}, notified[0])
+	require.Equal(t, "success", notified[1].status)
+	require.Contains(t, notified[1].detail, "+Pro $25")
+}
+
+func TestSchedulerSendsWebhookEvents(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "digest", false, 1, "hours", "")
+
+	var events []webhook.Event
+	sched := New(store, noopEmit, fastExec(), time.Minute)
+	sched.SetWebhookFunc(func(e webhook.Event) { events = append(events, e) })
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "running", events[0].Status)
+	require.Empty(t, events[0].RunID)
+	require.Equal(t, "success", events[1].Status)
+	require.Equal(t, job.ID, events[1].JobID)
+	require.Equal(t, "digest", events[1].Job)
+	require.Equal(t, run.ID, events[1].RunID)
+	require.Equal(t, run.Summary, events[1].Summary)
+	require.False(t, events[1].Time.IsZero())
+}
@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/worktree"
+)
+
+// SetWorktreeRoot sets the directory that holds the worktrees of runs of jobs
+// with Worktree set. Such runs fail until it is set.
+func (s *Scheduler) SetWorktreeRoot(dir string) {
+	s.worktreeRoot = dir
+}
+
+// removeWorktree removes the worktree of a run that is being deleted and
+// deletes its branch. What is left of them is logged, not returned, so the
+// run can still be deleted.
+func (s *Scheduler) removeWorktree(run db.JobRun) {
+	ctx := context.Background()
+	repo, err := worktree.Repo(ctx, run.Worktree)
+	if err != nil {
+		log.Printf("scheduler: failed to find the repository of run %s: %v", run.ID, err)
+		return
+	}
+	if err := worktree.Remove(ctx, repo, run.Worktree); err != nil {
+		log.Printf("scheduler: failed to remove worktree of run %s: %v", run.ID, err)
+	}
+	if err := worktree.DeleteBranch(ctx, repo, run.Branch); err != nil {
+		log.Printf("scheduler: failed to delete branch %s of run %s: %v", run.Branch, run.ID, err)
+	}
+}
+
+// startWorktree creates the worktree a new run of job works in, on a branch
+// named after the run, and records it on run.
+func (s *Scheduler) startWorktree(job db.Job, run *db.JobRun) error {
+	if s.worktreeRoot == "" {
+		return fmt.Errorf("no directory for worktrees is configured")
+	}
+	dir := filepath.Join(s.worktreeRoot, run.ID)
+	branch := "claude-schedule/run-" + run.ID[:8]
+	base, err := worktree.Create(s.ctx, job.WorkingDir, dir, branch)
+	if err != nil {
+		return err
+	}
+	// A directory nothing is committed in is not in the worktree yet.
+	if err := os.MkdirAll(worktreeWorkingDir(job, dir), 0o755); err != nil {
+		return err
+	}
+	run.Worktree = dir
+	run.Branch = branch
+	run.BaseCommit = base
+	run.WorktreeState = db.WorktreeActive
+	return nil
+}
+
+// worktreeWorkingDir returns the directory a run of job works in within the
+// worktree at dir: the same directory below the top of the repository as the
+// job's working directory.
+func worktreeWorkingDir(job db.Job, dir string) string {
+	sub, err := worktree.Subdir(context.Background(), job.WorkingDir)
+	if err != nil {
+		return dir
+	}
+	return filepath.Join(dir, sub)
+}
+
+// recordWorktree commits what was left uncommitted in a run's worktree and
+// records the changes on its branch on run. It runs even while the scheduler
+// shuts down, so a cancelled run's work is still accounted for.
+func (s *Scheduler) recordWorktree(run *db.JobRun) {
+	if run.WorktreeState != db.WorktreeActive {
+		return
+	}
+	ctx := context.Background()
+	if err := worktree.Snapshot(ctx, run.Worktree, "Uncommitted changes of run "+run.ID); err != nil {
+		log.Printf("scheduler: failed to commit changes in worktree of run %s: %v", run.ID, err)
+		return
+	}
+	diff, commits, err := worktree.Changes(ctx, run.Worktree, run.BaseCommit)
+	if err != nil {
+		log.Printf("scheduler: failed to read changes in worktree of run %s: %v", run.ID, err)
+		return
+	}
+	if commits == nil {
+		commits = []worktree.Commit{}
+	}
+	data, err := json.Marshal(commits)
+	if err != nil {
+		return
+	}
+	run.Diff = diff
+	run.Commits = string(data)
+}
+
+// KeepWorktree removes a finished run's worktree but keeps its branch in the
+// repository.
+func (s *Scheduler) KeepWorktree(runID string) error {
+	return s.resolveWorktree(runID, db.WorktreeKept)
+}
+
+// DiscardWorktree removes a finished run's worktree and deletes its branch.
+func (s *Scheduler) DiscardWorktree(runID string) error {
+	return s.resolveWorktree(runID, db.WorktreeDiscarded)
+}
+
+// MergeWorktree merges a finished run's branch into the branch checked out in
+// the job's repository, then removes the worktree and the branch. A merge that
+// does not go through cleanly changes nothing.
+func (s *Scheduler) MergeWorktree(runID string) error {
+	return s.resolveWorktree(runID, db.WorktreeMerged)
+}
+
+// resolveWorktree takes the action that moves a run's worktree to state.
+func (s *Scheduler) resolveWorktree(runID, state string) error {
+	run, err := s.store.GetRun(runID)
+	if err != nil {
+		return fmt.Errorf("loading run: %w", err)
+	}
+	if run.WorktreeState != db.WorktreeActive {
+		return fmt.Errorf("run has no worktree awaiting a decision")
+	}
+	if run.Status == "running" || run.Status == "waiting" {
+		return fmt.Errorf("run is still in progress")
+	}
+
+	// Follow-ups work in the worktree, so none may start meanwhile.
+	s.mu.Lock()
+	if s.followUps[runID] {
+		s.mu.Unlock()
+		return fmt.Errorf("a follow-up is in progress for this run")
+	}
+	s.followUps[runID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.followUps, runID)
+		s.mu.Unlock()
+	}()
+
+	// Pick up anything changed in the worktree since the run finished.
+	if state != db.WorktreeDiscarded {
+		s.recordWorktree(&run)
+	}
+
+	ctx := context.Background()
+	repo, err := worktree.Repo(ctx, run.Worktree)
+	if err != nil {
+		return err
+	}
+	if state == db.WorktreeMerged {
+		if err := worktree.Merge(ctx, repo, run.Branch); err != nil {
+			return err
+		}
+	}
+	if err := worktree.Remove(ctx, repo, run.Worktree); err != nil {
+		return err
+	}
+	if state != db.WorktreeKept {
+		if err := worktree.DeleteBranch(ctx, repo, run.Branch); err != nil {
+			log.Printf("scheduler: failed to delete branch %s of run %s: %v", run.Branch, run.ID, err)
+		}
+	}
+
+	run.WorktreeState = state
+	if err := s.store.UpdateRun(run); err != nil {
+		return err
+	}
+	s.emit()
+	return nil
+}
@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claude-schedule/internal/db"
+	"claude-schedule/internal/executor"
+	"claude-schedule/internal/worktree"
+
+	"github.com/stretchr/testify/require"
+)
+
+// gitRepo returns a repository with one commit of README.md.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.name", "Test"},
+		{"config", "user.email", "test@example.com"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0o644))
+	for _, args := range [][]string{{"add", "README.md"}, {"commit", "-q", "-m", "Initial commit"}} {
+		out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return repo
+}
+
+// worktreeRun runs a worktree job on repo whose run writes NOTES.md and
+// returns the finished run.
+func worktreeRun(t *testing.T, store *db.Store, repo string) (*Scheduler, db.JobRun) {
+	t.Helper()
+	job := createJob(t, store, "maintenance", false, 1, "hours", "")
+	job.WorkingDir = repo
+	job.Worktree = true
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	exec := func(_ context.Context, job db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		require.NotEqual(t, repo, job.WorkingDir)
+		require.NoError(t, os.WriteFile(filepath.Join(job.WorkingDir, "NOTES.md"), []byte("notes\n"), 0o644))
+		return executor.ExecuteResult{Transcript: "done", SessionID: "s"}, nil
+	}
+	sched := New(store, noopEmit, exec, time.Minute)
+	sched.SetWorktreeRoot(t.TempDir())
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", run.Status)
+	return sched, run
+}
+
+func TestSchedulerRunsJobInWorktree(t *testing.T) {
+	store := tempStore(t)
+	repo := gitRepo(t)
+	_, run := worktreeRun(t, store, repo)
+
+	require.Equal(t, db.WorktreeActive, run.WorktreeState)
+	require.Equal(t, "claude-schedule/run-"+run.ID[:8], run.Branch)
+	require.Len(t, run.BaseCommit, 40)
+	require.Contains(t, run.Diff, "+notes")
+	var commits []worktree.Commit
+	require.NoError(t, json.Unmarshal([]byte(run.Commits), &commits))
+	require.Len(t, commits, 1)
+
+	// The shared checkout is untouched.
+	require.NoFileExists(t, filepath.Join(repo, "NOTES.md"))
+	require.FileExists(t, filepath.Join(run.Worktree, "NOTES.md"))
+}
+
+func TestMergeWorktree(t *testing.T) {
+	store := tempStore(t)
+	repo := gitRepo(t)
+	sched, run := worktreeRun(t, store, repo)
+
+	require.NoError(t, sched.MergeWorktree(run.ID))
+	require.FileExists(t, filepath.Join(repo, "NOTES.md"))
+	require.NoDirExists(t, run.Worktree)
+
+	got, err := store.GetRun(run.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.WorktreeMerged, got.WorktreeState)
+	require.Error(t, sched.MergeWorktree(run.ID))
+	require.ErrorContains(t, sched.SendFollowUp(run.ID, "more"), "worktree has been removed")
+}
+
+func TestDiscardAndKeepWorktree(t *testing.T) {
+	store := tempStore(t)
+	repo := gitRepo(t)
+	branches := func() string {
+		out, err := exec.Command("git", "-C", repo, "branch", "--list", "claude-schedule/*").Output()
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	sched, discarded := worktreeRun(t, store, repo)
+	require.NoError(t, sched.DiscardWorktree(discarded.ID))
+	require.NoDirExists(t, discarded.Worktree)
+	require.NotContains(t, branches(), discarded.Branch)
+
+	sched, kept := worktreeRun(t, store, repo)
+	require.NoError(t, sched.KeepWorktree(kept.ID))
+	require.NoDirExists(t, kept.Worktree)
+	require.Contains(t, branches(), kept.Branch)
+	require.NoFileExists(t, filepath.Join(repo, "NOTES.md"))
+
+	got, err := store.GetRun(kept.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.WorktreeKept, got.WorktreeState)
+}
+
+func TestSchedulerRunsJobInWorktreeSubdirectory(t *testing.T) {
+	store := tempStore(t)
+	repo := gitRepo(t)
+	sub := filepath.Join(repo, "services", "api")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+	job := createJob(t, store, "api", false, 1, "hours", "")
+	job.WorkingDir = sub
+	job.Worktree = true
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	var dir string
+	exec := func(_ context.Context, job db.Job, _ []db.MCPServer, _ executor.Options) (executor.ExecuteResult, error) {
+		dir = job.WorkingDir
+		return executor.ExecuteResult{Transcript: "done"}, nil
+	}
+	sched := New(store, noopEmit, exec, time.Minute)
+	sched.SetWorktreeRoot(t.TempDir())
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "success", run.Status)
+	require.Equal(t, filepath.Join(run.Worktree, "services", "api"), dir)
+	require.DirExists(t, dir)
+}
+
+func TestDeletingJobRemovesWorktrees(t *testing.T) {
+	store := tempStore(t)
+	repo := gitRepo(t)
+	branches := func() string {
+		out, err := exec.Command("git", "-C", repo, "branch", "--list", "claude-schedule/*").Output()
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	_, run := worktreeRun(t, store, repo)
+	require.NoError(t, store.DeleteRunsForJob(run.JobID))
+	require.NoDirExists(t, run.Worktree)
+	require.NotContains(t, branches(), run.Branch)
+
+	_, run = worktreeRun(t, store, repo)
+	require.NoError(t, store.DeleteJob(run.JobID))
+	require.NoDirExists(t, run.Worktree)
+	require.NotContains(t, branches(), run.Branch)
+}
+
+func TestSchedulerFailsWorktreeRunOutsideRepository(t *testing.T) {
+	store := tempStore(t)
+	job := createJob(t, store, "not-a-repo", false, 1, "hours", "")
+	job.WorkingDir = t.TempDir()
+	job.Worktree = true
+	_, err := store.UpdateJob(job)
+	require.NoError(t, err)
+
+	sched := New(store, noopEmit, fastExec(), time.Minute)
+	sched.SetWorktreeRoot(t.TempDir())
+	require.NoError(t, sched.RunNow(job.ID))
+	sched.wg.Wait()
+
+	run, err := store.GetLatestRun(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", run.Status)
+	require.Contains(t, run.Output, "creating worktree")
+	require.Empty(t, run.WorktreeState)
+}
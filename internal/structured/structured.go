@@ -0,0 +1,152 @@
+// Package structured checks the final results of runs of jobs with an output
+// schema: it finds the JSON value a result ends with and validates it against
+// the job's JSON Schema.
+package structured
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaURL names the schema document inside the compiler. It is never
+// fetched.
+const schemaURL = "schema.json"
+
+// Schema is a compiled output schema.
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Compile parses a JSON Schema document. Schemas must be self-contained:
+// references to other documents are not followed.
+func Compile(doc string) (*Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("schemas cannot refer to other documents (%s)", url)
+	}
+	if err := c.AddResource(schemaURL, strings.NewReader(doc)); err != nil {
+		return nil, fmt.Errorf("invalid output schema: %w", err)
+	}
+	s, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output schema: %w", err)
+	}
+	return &Schema{schema: s}, nil
+}
+
+// Prompt returns the instruction that asks Claude for a final result
+// conforming to the schema doc.
+func Prompt(doc string) string {
+	return "When you are done, end your final reply with the result as a single JSON value " +
+		"that conforms to the JSON Schema below, in a ```json code block with nothing after it.\n\n" +
+		strings.TrimSpace(doc)
+}
+
+// RetryPrompt returns the message that asks Claude to correct a result that
+// failed to conform with the problems in err.
+func RetryPrompt(err *ValidationError) string {
+	return "Your final result does not match the required JSON Schema:\n- " +
+		strings.Join(err.Problems, "\n- ") +
+		"\n\nReply with the corrected result as a single JSON value in a ```json code block with nothing after it."
+}
+
+// ValidationError lists how a result failed to conform to a schema.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "the result does not match the output schema:\n" + strings.Join(e.Problems, "\n")
+}
+
+// Check finds the JSON value text ends with and validates it against the
+// schema. It returns the value as compact JSON, or a *ValidationError when
+// there is no JSON value or it does not conform.
+func (s *Schema) Check(text string) (string, error) {
+	raw, ok := Extract(text)
+	if !ok {
+		return "", &ValidationError{Problems: []string{"the result does not end with a JSON value"}}
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", &ValidationError{Problems: []string{err.Error()}}
+	}
+	if err := s.schema.Validate(v); err != nil {
+		ve, ok := err.(*jsonschema.ValidationError)
+		if !ok {
+			return "", err
+		}
+		return "", &ValidationError{Problems: problems(ve)}
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return "", err
+	}
+	return compact.String(), nil
+}
+
+// problems flattens a validation error into one line per failed keyword,
+// naming where in the value it failed.
+func problems(ve *jsonschema.ValidationError) []string {
+	var out []string
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			at := e.InstanceLocation
+			if at == "" {
+				at = "/"
+			}
+			out = append(out, fmt.Sprintf("at %s: %s", at, e.Message))
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(ve)
+	sort.Strings(out)
+	return out
+}
+
+// Extract returns the JSON value text ends with: the whole text if it is
+// JSON, else the last fenced code block if that is, else the text from the
+// last line that starts a JSON object or array.
+func Extract(text string) (json.RawMessage, bool) {
+	text = strings.TrimSpace(text)
+	if json.Valid([]byte(text)) {
+		return json.RawMessage(text), true
+	}
+
+	if body, ok := strings.CutSuffix(text, "```"); ok {
+		if start := strings.LastIndex(body, "```"); start >= 0 {
+			block := body[start+3:]
+			// Drop the info string, e.g. "json".
+			if nl := strings.IndexByte(block, '\n'); nl >= 0 {
+				block = block[nl+1:]
+			}
+			block = strings.TrimSpace(block)
+			if json.Valid([]byte(block)) {
+				return json.RawMessage(block), true
+			}
+		}
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "{") && !strings.HasPrefix(line, "[") {
+			continue
+		}
+		candidate := strings.TrimSpace(strings.Join(lines[i:], "\n"))
+		if json.Valid([]byte(candidate)) {
+			return json.RawMessage(candidate), true
+		}
+	}
+	return nil, false
+}
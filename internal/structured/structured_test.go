@@ -0,0 +1,83 @@
+package structured
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"changed": {"type": "boolean"},
+		"items": {"type": "array", "items": {"type": "string"}}
+	},
+	"required": ["changed"],
+	"additionalProperties": false
+}`
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"whole text", `  {"a": 1}  `, `{"a": 1}`},
+		{"fenced block", "Done.\n\n```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"fence without info string", "Done.\n```\n[1, 2]\n```\n", `[1, 2]`},
+		{"trailing object", "Here is the result:\n{\n  \"a\": 1\n}", "{\n  \"a\": 1\n}"},
+		{"last of several", "First {\"a\": 1}\nthen\n{\"a\": 2}", `{"a": 2}`},
+		{"none", "No JSON here.", ""},
+		{"text after the value", "{\"a\": 1}\nThanks!", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Extract(tt.text)
+			require.Equal(t, tt.want != "", ok)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	_, err := Compile(testSchema)
+	require.NoError(t, err)
+
+	_, err = Compile(`{"type": `)
+	require.ErrorContains(t, err, "invalid output schema")
+	_, err = Compile(`{"type": "nope"}`)
+	require.ErrorContains(t, err, "invalid output schema")
+	_, err = Compile(`{"$ref": "https://example.com/other.json"}`)
+	require.ErrorContains(t, err, "cannot refer to other documents")
+}
+
+func TestCheck(t *testing.T) {
+	s, err := Compile(testSchema)
+	require.NoError(t, err)
+
+	got, err := s.Check("All good.\n```json\n{\n  \"changed\": true,\n  \"items\": [\"a\"]\n}\n```")
+	require.NoError(t, err)
+	require.Equal(t, `{"changed":true,"items":["a"]}`, got)
+
+	_, err = s.Check(`{"changed": "yes", "items": [1], "extra": 0}`)
+	var ve *ValidationError
+	require.True(t, errors.As(err, &ve))
+	require.Len(t, ve.Problems, 3)
+	require.Contains(t, ve.Problems[0], "at /")
+	require.Contains(t, err.Error(), "at /changed")
+	require.Contains(t, err.Error(), "at /items/0")
+
+	_, err = s.Check("I could not finish.")
+	require.True(t, errors.As(err, &ve))
+	require.Equal(t, []string{"the result does not end with a JSON value"}, ve.Problems)
+}
+
+func TestPrompts(t *testing.T) {
+	require.Contains(t, Prompt("\n"+testSchema+"\n"), "conforms to the JSON Schema below")
+	require.True(t, strings.HasSuffix(Prompt(testSchema), "\"additionalProperties\": false\n}"))
+
+	retry := RetryPrompt(&ValidationError{Problems: []string{"at /: missing properties: 'changed'", "at /items/0: expected string"}})
+	require.Contains(t, retry, "\n- at /: missing properties: 'changed'\n- at /items/0: expected string\n")
+}
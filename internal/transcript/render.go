@@ -0,0 +1,233 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Version identifies the output of Render. Bump it whenever the rendering
+// changes so stored runs rendered by an older version are rendered again.
+const Version = 4
+
+// Render returns the HTML and markdown shown for events. Each event becomes
+// one block and blocks are separated by a blank line. A tool result is shown
+// under the call with the same ToolID and results of questions are left out;
+// a result whose call is not in events gets a block of its own. So rendering
+// two lists and joining them with a blank line equals rendering them together
+// as long as no result is split from its call.
+func Render(events []Event) string {
+	results := make(map[string]*Event)
+	calls := make(map[string]bool)
+	for i, e := range events {
+		switch e.Kind {
+		case KindToolUse, KindQuestion:
+			if e.ToolID != "" {
+				calls[e.ToolID] = true
+			}
+		case KindToolResult:
+			if calls[e.ToolID] {
+				results[e.ToolID] = &events[i]
+			}
+		}
+	}
+
+	var blocks []string
+	for _, e := range events {
+		switch {
+		case e.Kind == KindToolUse && results[e.ToolID] != nil:
+			blocks = append(blocks, singleLine(renderToolUse(e.Tool, e.Input, results[e.ToolID])))
+		case e.Kind == KindToolResult && results[e.ToolID] != nil:
+			// Shown with its call, or answered by the user.
+		default:
+			blocks = append(blocks, renderEvent(e)...)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// renderEvent returns the blocks for one event on its own; unknown kinds and
+// empty events render nothing. Untrusted text is escaped or sanitized, and
+// HTML blocks are kept on a single line.
+func renderEvent(e Event) []string {
+	switch e.Kind {
+	case KindText:
+		if text := strings.TrimSpace(e.Text); text != "" {
+			return []string{singleLine(renderText(text))}
+		}
+	case KindToolUse:
+		return []string{singleLine(renderToolUse(e.Tool, e.Input, nil))}
+	case KindToolResult:
+		if e.Text != "" || e.IsError {
+			return []string{singleLine(renderToolResult(e))}
+		}
+	case KindQuestion:
+		blocks := make([]string, 0, len(e.Questions))
+		for _, q := range e.Questions {
+			blocks = append(blocks, singleLine(renderQuestion(q)))
+		}
+		return blocks
+	case KindSummary:
+		if text := strings.TrimSpace(e.Text); text != "" {
+			return []string{singleLine(renderSummary(text))}
+		}
+	case KindFollowUp:
+		return []string{singleLine(renderFollowUp(e.Text))}
+	case KindFollowUpError:
+		return []string{singleLine(renderFollowUpError(e.Text))}
+	case KindSandbox:
+		if text := strings.TrimSpace(e.Text); text != "" {
+			return []string{singleLine(renderSandbox(strings.Split(text, "\n")))}
+		}
+	}
+	return nil
+}
+
+// RenderError renders a failed run's error message, which may quote model or
+// command output, as an escaped preformatted block.
+func RenderError(message string) string {
+	return singleLine(`<pre style="margin:8px 0;padding:8px 12px;border-left:3px solid #f87171;background:#0f172a;border-radius:4px;color:#fca5a5;font-size:12px;white-space:pre-wrap">` +
+		escape(message) + `</pre>`)
+}
+
+// RenderOutput renders output a command printed that could not be parsed into
+// events as an escaped preformatted block.
+func RenderOutput(output string) string {
+	return singleLine(`<pre style="margin:8px 0;padding:8px 12px;border-left:3px solid #6b7280;background:#0f172a;border-radius:4px;color:#e2e8f0;font-size:12px;white-space:pre-wrap">` +
+		escape(output) + `</pre>`)
+}
+
+func renderText(text string) string {
+	return `<div style="margin:8px 0;padding:8px 12px;border-left:3px solid #22d3ee;background:#0f172a;border-radius:4px;color:#e2e8f0;font-size:13px;line-height:1.5">` +
+		Markdown(text) + `</div>`
+}
+
+// renderToolUse renders a tool call and, when it is known, what it returned.
+func renderToolUse(name string, rawInput json.RawMessage, result *Event) string {
+	var b strings.Builder
+	b.WriteString(`<details style="margin:8px 0;border:1px solid #374151;border-radius:6px;overflow:hidden">`)
+	b.WriteString(`<summary style="cursor:pointer;padding:6px 10px;background:#1e293b;color:#60a5fa;font-size:13px;font-weight:600">`)
+	b.WriteString(`Tool: ` + escape(name))
+	if result != nil && result.IsError {
+		b.WriteString(errorBadge)
+	}
+	b.WriteString(`</summary>`)
+
+	if len(rawInput) > 0 {
+		input := string(rawInput)
+		var parsed interface{}
+		if err := json.Unmarshal(rawInput, &parsed); err == nil {
+			if pretty, err := json.MarshalIndent(parsed, "", "  "); err == nil {
+				input = string(pretty)
+			}
+		}
+		b.WriteString(`<pre style="margin:0;padding:8px 10px;background:#0f172a;color:#94a3b8;font-size:12px;overflow-x:auto">`)
+		b.WriteString(escape(input))
+		b.WriteString(`</pre>`)
+	}
+	if result != nil {
+		b.WriteString(`<div style="padding:4px 10px;background:#1e293b;color:#a78bfa;font-size:11px;font-weight:600;text-transform:uppercase;letter-spacing:0.05em">Result</div>`)
+		writeResult(&b, *result)
+	}
+
+	b.WriteString("</details>")
+	return b.String()
+}
+
+// errorBadge marks a tool call or result that failed, so the failure shows
+// while the details are collapsed.
+const errorBadge = ` <span style="margin-left:6px;padding:1px 6px;border-radius:4px;background:#7f1d1d;color:#fca5a5;font-size:11px">error</span>`
+
+// writeResult writes a tool result's output, noting how much was left out.
+func writeResult(b *strings.Builder, e Event) {
+	color := "#94a3b8"
+	if e.IsError {
+		color = "#fca5a5"
+	}
+	b.WriteString(`<pre style="margin:0;padding:8px 10px;background:#0f172a;color:` + color + `;font-size:12px;overflow-x:auto;white-space:pre-wrap">`)
+	b.WriteString(escape(e.Text))
+	b.WriteString(`</pre>`)
+	if e.Truncated > 0 {
+		fmt.Fprintf(b, `<div style="padding:4px 10px;background:#0f172a;color:#64748b;font-size:11px;font-style:italic">… %d more bytes not shown</div>`, e.Truncated)
+	}
+}
+
+func renderQuestion(q Question) string {
+	var b strings.Builder
+	b.WriteString(`<div style="margin:8px 0;padding:12px 16px;border:1px solid #f59e0b;border-radius:6px;background:#1c1917;color:#e2e8f0;font-size:13px;line-height:1.5">`)
+	b.WriteString(`<div style="color:#f59e0b;font-weight:700;font-size:11px;text-transform:uppercase;letter-spacing:0.05em;margin-bottom:6px">`)
+	if q.Header != "" {
+		b.WriteString(escape(q.Header))
+	} else {
+		b.WriteString("Question")
+	}
+	b.WriteString(`</div>`)
+	b.WriteString(`<div style="margin-bottom:10px;font-size:14px">` + escape(q.Question) + `</div>`)
+	for _, opt := range q.Options {
+		b.WriteString(`<div style="margin:4px 0;padding:6px 10px;border:1px solid #374151;border-radius:4px;background:#0f172a">`)
+		b.WriteString(`<span style="color:#fbbf24;font-weight:600">` + escape(opt.Label) + `</span>`)
+		if opt.Description != "" {
+			b.WriteString(` <span style="color:#94a3b8;font-size:12px">— ` + escape(opt.Description) + `</span>`)
+		}
+		b.WriteString(`</div>`)
+	}
+	b.WriteString(`</div>`)
+	return b.String()
+}
+
+// renderToolResult renders a result whose call is not among the events being
+// rendered, as happens for the live fragment of a user event.
+func renderToolResult(e Event) string {
+	var b strings.Builder
+	b.WriteString(`<details style="margin:8px 0;border:1px solid #374151;border-radius:6px;overflow:hidden">`)
+	b.WriteString(`<summary style="cursor:pointer;padding:6px 10px;background:#1e293b;color:#a78bfa;font-size:13px;font-weight:600">`)
+	if e.Tool != "" {
+		b.WriteString(`Result: ` + escape(e.Tool))
+	} else {
+		b.WriteString(`Result`)
+	}
+	if e.IsError {
+		b.WriteString(errorBadge)
+	}
+	b.WriteString(`</summary>`)
+	writeResult(&b, e)
+	b.WriteString("</details>")
+	return b.String()
+}
+
+func renderSummary(text string) string {
+	return `<hr style="border-color:#374151;margin:16px 0">` +
+		`<div style="margin:8px 0;padding:10px 12px;border-left:3px solid #34d399;background:#0f172a;border-radius:4px;color:#e2e8f0;font-size:13px;line-height:1.5">` +
+		`<strong style="color:#34d399">Summary</strong><br>` +
+		Markdown(text) + `</div>`
+}
+
+// renderSandbox lists what a job's sandbox blocked.
+func renderSandbox(blocked []string) string {
+	var b strings.Builder
+	b.WriteString(`<div style="margin:8px 0;padding:8px 12px;border-left:3px solid #fbbf24;background:#0f172a;border-radius:4px;color:#e2e8f0;font-size:13px;line-height:1.5">`)
+	b.WriteString(`<strong style="color:#fbbf24">Sandbox blocked</strong><ul style="margin:4px 0 0 16px;list-style:disc">`)
+	for _, item := range blocked {
+		b.WriteString(`<li>` + escape(item) + `</li>`)
+	}
+	b.WriteString(`</ul></div>`)
+	return b.String()
+}
+
+// renderFollowUp formats a follow-up message as a block of its own so it
+// stands apart from Claude's reply. The message is shown as written, not as
+// markdown.
+func renderFollowUp(message string) string {
+	return `<hr style="border-color:#374151;margin:16px 0">` +
+		`<div style="margin:8px 0;padding:8px 12px;border-left:3px solid #a78bfa;background:#0f172a;border-radius:4px;color:#e2e8f0;font-size:13px;line-height:1.5">` +
+		`<strong style="color:#a78bfa">Follow-up</strong>` +
+		`<div style="white-space:pre-wrap">` + escape(strings.TrimSpace(message)) + `</div></div>`
+}
+
+// renderFollowUpError formats why a follow-up failed, which may quote model
+// or command output.
+func renderFollowUpError(message string) string {
+	return `<div style="margin:8px 0;padding:8px 12px;border-left:3px solid #f87171;background:#0f172a;border-radius:4px;color:#fca5a5;font-size:13px;line-height:1.5">` +
+		`<strong style="color:#f87171">Follow-up failed</strong>` +
+		`<div style="white-space:pre-wrap">` + escape(message) + `</div></div>`
+}
@@ -0,0 +1,72 @@
+package transcript
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Model text, tool output and question labels are untrusted: a fetched web
+// page can carry markup that would otherwise run in the app's webview, which
+// has access to the app's bindings. Markdown is rendered to HTML and passed
+// through an allowlist; everything else is escaped.
+
+// markdown renders markdown without raw HTML, which goldmark omits unless told
+// otherwise.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// policy allows the markup goldmark produces for GFM and nothing else.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "strong", "em", "del", "code", "pre", "blockquote",
+		"ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6",
+		"table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+
+	// Task list checkboxes.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+
+	// Links to the web only; images are left out so rendering a transcript
+	// never fetches anything.
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Markdown renders untrusted markdown to sanitized HTML.
+func Markdown(text string) string {
+	var b bytes.Buffer
+	if err := markdown.Convert([]byte(text), &b); err != nil {
+		return "<p>" + escape(text) + "</p>"
+	}
+	return strings.TrimSpace(policy.Sanitize(b.String()))
+}
+
+// textEscaper escapes the characters that start markup. Untrusted text is
+// only ever placed between tags, never in attributes, so quotes stay as they
+// are and JSON tool input remains readable.
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escape makes untrusted text safe to place between HTML tags.
+func escape(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// singleLine encodes the newlines of an HTML block as character references.
+// The app renders run output as markdown, and a blank line would end the HTML
+// block and have the rest of it parsed as markdown.
+func singleLine(block string) string {
+	return strings.ReplaceAll(block, "\n", "&#10;")
+}
@@ -0,0 +1,157 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	goldhtml "github.com/yuin/goldmark/renderer/html"
+	"golang.org/x/net/html"
+)
+
+// hostile are payloads a fetched page or a prompt-injected model could emit.
+var hostile = []string{
+	`<script>window.go.main.App.DeleteJob("x")</script>`,
+	`<img src=x onerror="alert(1)">`,
+	`<svg onload=alert(1)>`,
+	`<iframe src="https://evil.example"></iframe>`,
+	`<a href="javascript:alert(1)">click</a>`,
+	`[click](javascript:alert(1))`,
+	`![pixel](https://evil.example/track.png)`,
+	`<div style="background:url(https://evil.example)">x</div>`,
+	`</pre></details><script>alert(1)</script>`,
+	"```\n</code></pre><script>alert(1)</script>\n```",
+}
+
+// safeTags are the elements the renderer and the sanitizer may emit.
+var safeTags = map[string]bool{
+	"div": true, "span": true, "details": true, "summary": true, "pre": true, "hr": true, "br": true,
+	"p": true, "strong": true, "em": true, "del": true, "code": true, "blockquote": true, "a": true,
+	"ul": true, "ol": true, "li": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"table": true, "thead": true, "tbody": true, "tr": true, "th": true, "td": true, "input": true,
+}
+
+// requireSafe fails if s contains an element or attribute that could run code
+// or load a resource.
+func requireSafe(t *testing.T, s string) {
+	t.Helper()
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			require.True(t, safeTags[tok.Data], "unexpected <%s> in %s", tok.Data, s)
+			for _, attr := range tok.Attr {
+				require.False(t, strings.HasPrefix(attr.Key, "on"), "event handler %s in %s", attr.Key, s)
+				require.NotContains(t, strings.ToLower(attr.Val), "javascript:")
+				require.NotContains(t, strings.ToLower(attr.Val), "url(")
+				if attr.Key == "src" {
+					t.Fatalf("resource loaded in %s", s)
+				}
+			}
+		}
+	}
+}
+
+func TestMarkdown_StripsHostileMarkup(t *testing.T) {
+	for _, payload := range hostile {
+		requireSafe(t, Markdown(payload))
+	}
+}
+
+func TestMarkdown_KeepsFormatting(t *testing.T) {
+	got := Markdown("**bold** and `code`\n\n- item\n\n[docs](https://example.com)\n\n```go\nfmt.Println(1 < 2)\n```")
+	require.Contains(t, got, "<strong>bold</strong>")
+	require.Contains(t, got, "<code>code</code>")
+	require.Contains(t, got, "<li>item</li>")
+	require.Contains(t, got, `href="https://example.com"`)
+	require.Contains(t, got, `rel="nofollow noopener"`)
+	require.Contains(t, got, `<code class="language-go">`)
+	require.Contains(t, got, "1 &lt; 2")
+}
+
+func TestRender_EscapesEveryUntrustedField(t *testing.T) {
+	for _, payload := range hostile {
+		input, err := json.Marshal(map[string]string{"url": payload})
+		require.NoError(t, err)
+
+		got := Render([]Event{
+			{Kind: KindText, Text: payload},
+			{Kind: KindToolUse, Tool: payload, Input: input},
+			{Kind: KindToolUse, Tool: "Bash", Input: json.RawMessage(payload)},
+			{Kind: KindToolResult, Tool: payload, Text: payload, IsError: true},
+			{Kind: KindToolUse, Tool: "Read", ToolID: "toolu_1"},
+			{Kind: KindToolResult, ToolID: "toolu_1", Text: payload},
+			{Kind: KindQuestion, Questions: []Question{{
+				Question: payload,
+				Header:   payload,
+				Options:  []Option{{Label: payload, Description: payload}},
+			}}},
+			{Kind: KindSummary, Text: payload},
+			{Kind: KindFollowUp, Text: payload},
+			{Kind: KindFollowUpError, Text: payload},
+		})
+		requireSafe(t, got)
+		requireSafe(t, RenderError(payload))
+		requireSafe(t, RenderOutput(payload))
+	}
+}
+
+// viewer renders output the way the app does: as markdown that passes raw
+// HTML through.
+var viewer = goldmark.New(goldmark.WithRendererOptions(goldhtml.WithUnsafe()))
+
+// requireSafeAsMarkdown fails if s, shown as markdown, yields an element or
+// attribute that could run code or load a resource.
+func requireSafeAsMarkdown(t *testing.T, s string) {
+	t.Helper()
+	var b bytes.Buffer
+	require.NoError(t, viewer.Convert([]byte(s), &b))
+	requireSafe(t, b.String())
+}
+
+func TestRender_FollowUpsAreNotMarkdown(t *testing.T) {
+	payloads := append([]string{
+		"why?\n\n[x](javascript:alert(1))",
+		"</div>\n\n![i](http://evil.example/p.png)",
+	}, hostile...)
+	for _, payload := range payloads {
+		got := Render([]Event{
+			{Kind: KindFollowUp, Text: payload},
+			{Kind: KindFollowUpError, Text: payload},
+		})
+		requireSafe(t, got)
+		requireSafeAsMarkdown(t, got)
+	}
+}
+
+func TestRender_IsSafeAsMarkdown(t *testing.T) {
+	for _, payload := range hostile {
+		got := Render([]Event{
+			{Kind: KindText, Text: "> ```\n> a\n>\n> b\n> ```\n\n" + payload},
+			{Kind: KindToolResult, Text: payload + "\n\n" + payload},
+			{Kind: KindSummary, Text: payload},
+		})
+		requireSafeAsMarkdown(t, got)
+		requireSafeAsMarkdown(t, RenderError(payload+"\n\n"+payload))
+		requireSafeAsMarkdown(t, RenderOutput(payload+"\n\n"+payload))
+	}
+}
+
+func TestRender_KeepsHTMLBlocksOnOneLine(t *testing.T) {
+	got := Render([]Event{
+		{Kind: KindText, Text: "first\n\nsecond"},
+		{Kind: KindToolResult, Text: "a\n\n    indented"},
+	})
+	blocks := strings.Split(got, "\n\n")
+	require.Len(t, blocks, 2)
+	for _, block := range blocks {
+		require.NotContains(t, block, "\n")
+	}
+	require.Contains(t, blocks[1], "a&#10;&#10;    indented")
+}
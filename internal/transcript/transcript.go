@@ -0,0 +1,94 @@
+// Package transcript holds the structured record of a run: what Claude said,
+// which tools it called, the questions it asked and the follow-ups it was
+// sent. Runs store the events as JSON; Render turns them into the HTML shown
+// in the app, so the presentation can change without re-running jobs.
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Event kinds.
+const (
+	KindText          = "text"            // assistant text
+	KindToolUse       = "tool_use"        // a tool call and its input
+	KindToolResult    = "tool_result"     // output returned by a tool, paired with its call by ToolID
+	KindQuestion      = "question"        // an AskUserQuestion call awaiting an answer
+	KindSummary       = "summary"         // final result that the text did not already show
+	KindFollowUp      = "follow_up"       // a follow-up message sent by the user
+	KindFollowUpError = "follow_up_error" // a follow-up that failed
+	KindSandbox       = "sandbox"         // what a job's sandbox blocked, one entry per line
+)
+
+// Event is one entry of a run's transcript.
+type Event struct {
+	Kind      string          `json:"kind"`
+	Time      time.Time       `json:"time"`                // when the event was received
+	Text      string          `json:"text,omitempty"`      // text, tool_result, summary, follow-up and sandbox kinds
+	Tool      string          `json:"tool,omitempty"`      // tool_use and tool_result: name of the tool
+	ToolID    string          `json:"toolId,omitempty"`    // tool_use, tool_result and question: ID of the call
+	Input     json.RawMessage `json:"input,omitempty"`     // tool_use: the tool's input
+	Questions []Question      `json:"questions,omitempty"` // question: the questions asked
+	IsError   bool            `json:"isError,omitempty"`   // tool_result: the tool failed
+	Truncated int             `json:"truncated,omitempty"` // tool_result: bytes of Text left out, see ToolResult
+}
+
+// MaxToolResultBytes is how much of a tool's output a tool_result event keeps.
+// Tools can return whole files or web pages, which would bloat the stored
+// events and bury the rest of the transcript.
+const MaxToolResultBytes = 16 * 1024
+
+// ToolResult returns a tool_result event for the call toolID, keeping at most
+// MaxToolResultBytes of text.
+func ToolResult(toolID, tool, text string, isError bool) Event {
+	e := Event{Kind: KindToolResult, ToolID: toolID, Tool: tool, Text: text, IsError: isError}
+	if len(text) > MaxToolResultBytes {
+		cut := MaxToolResultBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		e.Text = text[:cut]
+		e.Truncated = len(text) - cut
+	}
+	return e
+}
+
+// Question is one question of an AskUserQuestion call.
+type Question struct {
+	Question string   `json:"question"`
+	Header   string   `json:"header"`
+	Options  []Option `json:"options"`
+}
+
+// Option is a suggested answer to a Question.
+type Option struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+}
+
+// Encode returns events as the JSON stored with a run.
+func Encode(events []Event) string {
+	if len(events) == 0 {
+		return "[]"
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		return "[]"
+	}
+	return string(data)
+}
+
+// Decode parses events stored with Encode. An empty string has no events.
+func Decode(s string) ([]Event, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var events []Event
+	if err := json.Unmarshal([]byte(s), &events); err != nil {
+		return nil, fmt.Errorf("invalid transcript events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,123 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sampleEvents() []Event {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	return []Event{
+		{Kind: KindText, Time: at, Text: "Let me check."},
+		{Kind: KindToolUse, Time: at, Tool: "Bash", ToolID: "toolu_1", Input: json.RawMessage(`{"command":"ls"}`)},
+		{Kind: KindToolResult, Time: at, Text: "main.go"},
+		{Kind: KindQuestion, Time: at, Questions: []Question{{
+			Question: "Deploy?",
+			Options:  []Option{{Label: "Yes", Description: "Ship it"}, {Label: "No"}},
+		}}},
+		{Kind: KindSummary, Time: at, Text: "Done."},
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	events := sampleEvents()
+	got, err := Decode(Encode(events))
+	require.NoError(t, err)
+	require.Equal(t, events, got)
+
+	require.Equal(t, "[]", Encode(nil))
+	got, err = Decode("")
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = Decode("{")
+	require.Error(t, err)
+}
+
+func TestRender(t *testing.T) {
+	got := Render(sampleEvents())
+	require.Contains(t, got, "<p>Let me check.</p></div>")
+	require.Contains(t, got, "Tool: Bash")
+	require.Contains(t, got, `"command": "ls"`)
+	require.Contains(t, got, ">Result</summary>")
+	require.Contains(t, got, ">Question</div>")
+	require.Contains(t, got, "— Ship it")
+	require.Contains(t, got, ">Summary</strong><br><p>Done.</p></div>")
+}
+
+func TestRender_SkipsEmptyEvents(t *testing.T) {
+	require.Equal(t, "", Render([]Event{
+		{Kind: KindText, Text: "  "},
+		{Kind: KindToolResult},
+		{Kind: "unknown", Text: "x"},
+	}))
+}
+
+func TestRender_FollowUps(t *testing.T) {
+	got := Render([]Event{
+		{Kind: KindFollowUp, Text: "Why?\nExplain."},
+		{Kind: KindFollowUpError, Text: "claude exited"},
+	})
+	blocks := strings.Split(got, "\n\n")
+	require.Len(t, blocks, 2)
+	require.Contains(t, blocks[0], ">Follow-up</strong>")
+	require.Contains(t, blocks[0], "Why?&#10;Explain.")
+	require.Contains(t, blocks[1], ">Follow-up failed</strong>")
+	require.Contains(t, blocks[1], "claude exited")
+}
+
+func TestRender_Sandbox(t *testing.T) {
+	got := Render([]Event{{Kind: KindSandbox, Text: "write: touch: /home/u/x: Read-only file system\nnetwork: evil.example:443"}})
+	require.Contains(t, got, ">Sandbox blocked</strong>")
+	require.Contains(t, got, "<li>write: touch: /home/u/x: Read-only file system</li><li>network: evil.example:443</li>")
+	require.Empty(t, Render([]Event{{Kind: KindSandbox}}))
+}
+
+func TestRender_PairsResultsWithCalls(t *testing.T) {
+	got := Render([]Event{
+		{Kind: KindToolUse, Tool: "Bash", ToolID: "toolu_1", Input: json.RawMessage(`{"command":"ls"}`)},
+		{Kind: KindToolUse, Tool: "Read", ToolID: "toolu_2"},
+		{Kind: KindToolResult, Tool: "Read", ToolID: "toolu_2", Text: "no such file", IsError: true},
+		{Kind: KindToolResult, Tool: "Bash", ToolID: "toolu_1", Text: "main.go"},
+		{Kind: KindQuestion, ToolID: "toolu_3", Questions: []Question{{Question: "Deploy?"}}},
+		{Kind: KindToolResult, Tool: "AskUserQuestion", ToolID: "toolu_3", Text: "Answer questions?"},
+	})
+
+	blocks := strings.Split(got, "\n\n")
+	require.Len(t, blocks, 3)
+	require.Contains(t, blocks[0], "Tool: Bash</summary>")
+	require.Contains(t, blocks[0], "main.go</pre>")
+	require.Contains(t, blocks[1], "Tool: Read")
+	require.Contains(t, blocks[1], ">error</span></summary>")
+	require.Contains(t, blocks[1], "no such file</pre>")
+	require.NotContains(t, got, "Answer questions?")
+
+	// A result without its call, like a live fragment, stands alone.
+	alone := Render([]Event{{Kind: KindToolResult, Tool: "Bash", ToolID: "toolu_1", Text: "main.go", IsError: true}})
+	require.Contains(t, alone, ">Result: Bash <span")
+	require.Contains(t, alone, "main.go</pre>")
+}
+
+func TestToolResult_Truncates(t *testing.T) {
+	e := ToolResult("toolu_1", "Read", "short", false)
+	require.Equal(t, Event{Kind: KindToolResult, ToolID: "toolu_1", Tool: "Read", Text: "short"}, e)
+
+	// The cut never splits a multi-byte character.
+	long := "a" + strings.Repeat("é", MaxToolResultBytes)
+	e = ToolResult("toolu_1", "Read", long, false)
+	require.True(t, utf8.ValidString(e.Text))
+	require.LessOrEqual(t, len(e.Text), MaxToolResultBytes)
+	require.Equal(t, len(long), len(e.Text)+e.Truncated)
+	require.Contains(t, Render([]Event{e}), fmt.Sprintf("… %d more bytes not shown", e.Truncated))
+}
+
+func TestRender_IsAdditive(t *testing.T) {
+	events := sampleEvents()
+	require.Equal(t, Render(events), Render(events[:2])+"\n\n"+Render(events[2:]))
+}
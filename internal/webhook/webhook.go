@@ -0,0 +1,279 @@
+// Package webhook posts job events to webhook destinations: any endpoint
+// taking generic JSON, Slack incoming webhooks and Discord webhooks. Failed
+// deliveries are retried with backoff, and every delivery is logged in the
+// store.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-schedule/internal/db"
+)
+
+// Event is something that happened to a job, as sent to webhooks.
+type Event struct {
+	Job     string    `json:"job"`               // name of the job
+	JobID   string    `json:"jobId"`             // ID of the job
+	RunID   string    `json:"runId,omitempty"`   // run the event is about, if it has started
+	Status  string    `json:"status"`            // status the job reached, see db.WebhookEvents
+	Outcome string    `json:"outcome,omitempty"` // outcome the run reported, if any
+	Summary string    `json:"summary,omitempty"` // final result of the run
+	Changes string    `json:"changes,omitempty"` // excerpt of how the result changed, for jobs that compare results
+	Time    time.Time `json:"time"`
+}
+
+// Message describes e in a sentence.
+func Message(e Event) string {
+	switch e.Status {
+	case "running":
+		return e.Job + " is now running"
+	case "success":
+		return e.Job + " finished successfully"
+	case "failed":
+		return e.Job + " failed"
+	case "assertion_failed":
+		return e.Job + " finished but failed its success criteria"
+	case "waiting":
+		return e.Job + " is waiting for your answer"
+	default:
+		return e.Job + ": " + e.Status
+	}
+}
+
+// Limits of the destinations on message text, with room to spare.
+const (
+	maxSlackText      = 3000
+	maxDiscordContent = 4000
+)
+
+// statusColors are the Discord embed colours of each status.
+var statusColors = map[string]int{
+	"running":          0xfacc15,
+	"success":          0x4ade80,
+	"failed":           0xf87171,
+	"assertion_failed": 0xfb923c,
+	"waiting":          0xfbbf24,
+}
+
+// Payload returns the request body that sends e to a webhook of format.
+func Payload(format string, e Event) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.Marshal(e)
+	case "slack":
+		text := "*" + slackEscape(Message(e)) + "*"
+		if e.Summary != "" {
+			text += "\n" + slackEscape(e.Summary)
+		}
+		if e.Changes != "" {
+			text += "\n```" + slackEscape(e.Changes) + "```"
+		}
+		return json.Marshal(map[string]any{"text": truncate(text, maxSlackText)})
+	case "discord":
+		var description string
+		if e.Summary != "" {
+			description = e.Summary
+		}
+		if e.Changes != "" {
+			description = strings.TrimSpace(description + "\n```diff\n" + e.Changes + "\n```")
+		}
+		return json.Marshal(map[string]any{
+			"embeds": []map[string]any{{
+				"title":       truncate(Message(e), 256),
+				"description": truncate(description, maxDiscordContent),
+				"color":       statusColors[e.Status],
+				"timestamp":   e.Time.Format(time.RFC3339),
+			}},
+			// Results are untrusted text; never let them ping anyone.
+			"allowed_mentions": map[string]any{"parse": []string{}},
+		})
+	default:
+		return nil, fmt.Errorf("unknown webhook format: %s", format)
+	}
+}
+
+// slackEscape escapes the characters Slack treats as markup.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// truncate shortens s to at most n runes, marking the cut.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// retryDelays are the waits before each retry of a failed delivery.
+var retryDelays = []time.Duration{5 * time.Second, 30 * time.Second, 2 * time.Minute}
+
+// requestTimeout bounds each delivery attempt.
+const requestTimeout = 10 * time.Second
+
+// Dispatcher sends events to the webhooks routed to their jobs.
+type Dispatcher struct {
+	store  *db.Store
+	client *http.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher returns a Dispatcher that finds webhooks and logs deliveries
+// in store.
+func NewDispatcher(store *db.Store) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		store:  store,
+		client: &http.Client{Timeout: requestTimeout},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Dispatch sends e in the background to every webhook routed to its job that
+// wants its status.
+func (d *Dispatcher) Dispatch(e Event) {
+	webhooks, err := d.store.GetWebhooksForJob(e.JobID)
+	if err != nil {
+		log.Printf("webhook: failed to load webhooks of job %s: %v", e.JobID, err)
+		return
+	}
+	for _, w := range webhooks {
+		if !w.Wants(e.Status) {
+			continue
+		}
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliver(w, e, retryDelays)
+		}()
+	}
+}
+
+// Test sends a sample event to a webhook once, without retrying, and returns
+// the delivery.
+func (d *Dispatcher) Test(w db.Webhook) db.WebhookDelivery {
+	return d.deliver(w, Event{
+		Job:     "Test",
+		Status:  "success",
+		Summary: "This is a test notification from Claude Scheduler.",
+		Time:    time.Now().UTC(),
+	}, nil)
+}
+
+// Close stops retrying and waits for deliveries in flight.
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// deliver sends e to w, retrying after each of delays while attempts fail in
+// a way that may pass, and logs the delivery.
+func (d *Dispatcher) deliver(w db.Webhook, e Event, delays []time.Duration) db.WebhookDelivery {
+	rec, err := d.store.CreateWebhookDelivery(db.WebhookDelivery{
+		WebhookID: w.ID,
+		JobName:   e.Job,
+		RunID:     e.RunID,
+		Event:     e.Status,
+	})
+	if err != nil {
+		log.Printf("webhook: failed to log delivery to %s: %v", w.Name, err)
+	}
+
+	body, err := Payload(w.Format, e)
+	if err == nil {
+		err = d.attempt(w, body, delays, &rec)
+	}
+
+	rec.State = db.DeliveryDelivered
+	rec.Error = ""
+	if err != nil {
+		rec.State = db.DeliveryFailed
+		rec.Error = err.Error()
+		log.Printf("webhook: delivery to %s failed: %v", w.Name, err)
+	}
+	d.record(rec)
+	return rec
+}
+
+// attempt posts body to w until it is accepted, an attempt fails in a way
+// that will not pass, or delays run out, updating rec as it goes.
+func (d *Dispatcher) attempt(w db.Webhook, body []byte, delays []time.Duration, rec *db.WebhookDelivery) error {
+	for i := 0; ; i++ {
+		rec.Attempts++
+		code, err := d.post(w, body)
+		rec.StatusCode = code
+		if err == nil || !retryable(code) || i >= len(delays) {
+			return err
+		}
+		rec.Error = err.Error()
+		d.record(*rec)
+		select {
+		case <-time.After(delays[i]):
+		case <-d.ctx.Done():
+			return fmt.Errorf("%w; gave up as the app closed", err)
+		}
+	}
+}
+
+// record saves the progress of a delivery.
+func (d *Dispatcher) record(rec db.WebhookDelivery) {
+	if rec.ID == "" {
+		return
+	}
+	if err := d.store.UpdateWebhookDelivery(rec); err != nil {
+		log.Printf("webhook: failed to log delivery %s: %v", rec.ID, err)
+	}
+}
+
+// post makes one delivery attempt. It returns the response's status code, or
+// 0 if there was none.
+func (d *Dispatcher) post(w db.Webhook, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claude-schedule")
+	headers, err := w.HeaderMap()
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	msg := fmt.Sprintf("%s returned %s", w.Name, resp.Status)
+	if text := strings.TrimSpace(string(detail)); text != "" {
+		msg += ": " + text
+	}
+	return resp.StatusCode, fmt.Errorf("%s", msg)
+}
+
+// retryable reports whether an attempt that got status code, 0 for no
+// response, may succeed if tried again.
+func retryable(code int) bool {
+	return code == 0 || code == http.StatusTooManyRequests || code >= 500
+}
@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"claude-schedule/internal/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testStore(t *testing.T) *db.Store {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// standIn is a local webhook endpoint that answers with the given status
+// codes in turn, then with 200, and records what it was sent.
+type standIn struct {
+	*httptest.Server
+	mu       sync.Mutex
+	codes    []int
+	bodies   []string
+	requests []*http.Request
+}
+
+func newStandIn(t *testing.T, codes ...int) *standIn {
+	s := &standIn{codes: codes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.bodies = append(s.bodies, string(body))
+		s.requests = append(s.requests, r)
+		code := http.StatusOK
+		if len(s.codes) > 0 {
+			code, s.codes = s.codes[0], s.codes[1:]
+		}
+		w.WriteHeader(code)
+		if code >= 400 {
+			w.Write([]byte("nope"))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *standIn) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func fastRetries(t *testing.T) {
+	old := retryDelays
+	retryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	t.Cleanup(func() { retryDelays = old })
+}
+
+var event = Event{
+	Job:     "Pricing watch",
+	JobID:   "job-1",
+	RunID:   "run-1",
+	Status:  "success",
+	Outcome: "changed",
+	Summary: "Pro now costs <$25> & more",
+	Changes: "-Pro $20\n+Pro $25",
+	Time:    time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC),
+}
+
+func TestPayload(t *testing.T) {
+	data, err := Payload("json", event)
+	require.NoError(t, err)
+	var generic Event
+	require.NoError(t, json.Unmarshal(data, &generic))
+	require.Equal(t, event, generic)
+
+	data, err = Payload("slack", event)
+	require.NoError(t, err)
+	var slack struct{ Text string }
+	require.NoError(t, json.Unmarshal(data, &slack))
+	require.Equal(t, "*Pricing watch finished successfully*\nPro now costs &lt;$25&gt; &amp; more\n```-Pro $20\n+Pro $25```", slack.Text)
+
+	data, err = Payload("discord", event)
+	require.NoError(t, err)
+	var discord struct {
+		Embeds []struct {
+			Title       string
+			Description string
+			Color       int
+		}
+		AllowedMentions struct{ Parse []string } `json:"allowed_mentions"`
+	}
+	require.NoError(t, json.Unmarshal(data, &discord))
+	require.Len(t, discord.Embeds, 1)
+	require.Equal(t, "Pricing watch finished successfully", discord.Embeds[0].Title)
+	require.Equal(t, "Pro now costs <$25> & more\n```diff\n-Pro $20\n+Pro $25\n```", discord.Embeds[0].Description)
+	require.Equal(t, 0x4ade80, discord.Embeds[0].Color)
+	require.NotNil(t, discord.AllowedMentions.Parse)
+	require.Empty(t, discord.AllowedMentions.Parse)
+
+	_, err = Payload("teams", event)
+	require.ErrorContains(t, err, "unknown webhook format")
+}
+
+func TestDispatchRoutesAndFilters(t *testing.T) {
+	store := testStore(t)
+	job, err := store.CreateJob(db.Job{Name: "Pricing watch", StartDate: "2026-01-01T00:00", IntervalValue: 1, IntervalUnit: "days"})
+	require.NoError(t, err)
+
+	routed := newStandIn(t)
+	failuresOnly := newStandIn(t)
+	unrouted := newStandIn(t)
+	w, err := store.CreateWebhook(db.Webhook{Name: "routed", URL: routed.URL, Headers: `{"Authorization": "Bearer s3cret"}`})
+	require.NoError(t, err)
+	_, err = store.CreateWebhook(db.Webhook{Name: "failures", URL: failuresOnly.URL, Events: `["failed"]`, AllJobs: true})
+	require.NoError(t, err)
+	_, err = store.CreateWebhook(db.Webhook{Name: "unrouted", URL: unrouted.URL})
+	require.NoError(t, err)
+	require.NoError(t, store.SetJobWebhooks(job.ID, []string{w.ID}))
+
+	d := NewDispatcher(store)
+	e := event
+	e.JobID = job.ID
+	d.Dispatch(e)
+	d.Close()
+
+	require.Len(t, routed.received(), 1)
+	require.Contains(t, routed.received()[0], `"status":"success"`)
+	require.Equal(t, "Bearer s3cret", routed.requests[0].Header.Get("Authorization"))
+	require.Equal(t, "application/json", routed.requests[0].Header.Get("Content-Type"))
+	require.Empty(t, failuresOnly.received(), "the webhook only wants failures")
+	require.Empty(t, unrouted.received(), "the job is not routed to the webhook")
+
+	deliveries, err := store.GetWebhookDeliveries(w.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, db.DeliveryDelivered, deliveries[0].State)
+	require.Equal(t, 1, deliveries[0].Attempts)
+	require.Equal(t, 200, deliveries[0].StatusCode)
+	require.Equal(t, "run-1", deliveries[0].RunID)
+}
+
+func TestDeliverRetries(t *testing.T) {
+	fastRetries(t)
+	store := testStore(t)
+	d := NewDispatcher(store)
+	t.Cleanup(d.Close)
+
+	flaky := newStandIn(t, http.StatusBadGateway, http.StatusTooManyRequests)
+	w, err := store.CreateWebhook(db.Webhook{Name: "flaky", URL: flaky.URL, Format: "slack"})
+	require.NoError(t, err)
+	rec := d.deliver(w, event, retryDelays)
+	require.Equal(t, db.DeliveryDelivered, rec.State)
+	require.Equal(t, 3, rec.Attempts)
+	require.Empty(t, rec.Error)
+	require.Len(t, flaky.received(), 3)
+
+	down := newStandIn(t, 500, 500, 500)
+	w, err = store.CreateWebhook(db.Webhook{Name: "down", URL: down.URL})
+	require.NoError(t, err)
+	rec = d.deliver(w, event, retryDelays)
+	require.Equal(t, db.DeliveryFailed, rec.State)
+	require.Equal(t, 3, rec.Attempts)
+	require.Equal(t, 500, rec.StatusCode)
+	require.Equal(t, "down returned 500 Internal Server Error: nope", rec.Error)
+
+	rejected := newStandIn(t, http.StatusNotFound)
+	w, err = store.CreateWebhook(db.Webhook{Name: "gone", URL: rejected.URL})
+	require.NoError(t, err)
+	rec = d.deliver(w, event, retryDelays)
+	require.Equal(t, db.DeliveryFailed, rec.State)
+	require.Equal(t, 1, rec.Attempts, "client errors are not retried")
+
+	deliveries, err := store.GetWebhookDeliveries(w.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, rec.ID, deliveries[0].ID)
+	require.Equal(t, "Pricing watch", deliveries[0].JobName)
+	require.Equal(t, db.DeliveryFailed, deliveries[0].State)
+	require.Equal(t, 404, deliveries[0].StatusCode)
+	require.Equal(t, "gone returned 404 Not Found: nope", deliveries[0].Error)
+}
+
+func TestCloseStopsRetrying(t *testing.T) {
+	store := testStore(t)
+	down := newStandIn(t, 503, 503, 503, 503)
+	w, err := store.CreateWebhook(db.Webhook{Name: "down", URL: down.URL, AllJobs: true})
+	require.NoError(t, err)
+
+	d := NewDispatcher(store)
+	d.Dispatch(Event{Job: "x", JobID: "job-1", Status: "failed"})
+	require.Eventually(t, func() bool { return len(down.received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	start := time.Now()
+	d.Close()
+	require.Less(t, time.Since(start), time.Second, "Close does not wait out the backoff")
+
+	deliveries, err := store.GetWebhookDeliveries(w.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.DeliveryFailed, deliveries[0].State)
+	require.Contains(t, deliveries[0].Error, "gave up as the app closed")
+}
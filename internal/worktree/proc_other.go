@@ -0,0 +1,8 @@
+//go:build !windows
+
+package worktree
+
+import "os/exec"
+
+// hideWindow is a no-op on non-Windows platforms.
+func hideWindow(_ *exec.Cmd) {}
@@ -0,0 +1,13 @@
+package worktree
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// hideWindow configures the command to run without a visible console window.
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+	}
+}
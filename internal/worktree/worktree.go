@@ -0,0 +1,150 @@
+// Package worktree gives a run its own git worktree of a job's repository, so
+// runs never edit the checkout people work in. Each worktree has a branch of
+// its own; its changes are later merged into the repository or thrown away.
+package worktree
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// maxCommits caps how many commits Changes reports.
+const maxCommits = 100
+
+// Commit is a commit made on a worktree's branch.
+type Commit struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+	Author  string `json:"author"`
+	Date    string `json:"date"` // strict ISO 8601
+}
+
+// snapshotIdentity is the author of the commits Snapshot makes, which record
+// changes a run left uncommitted.
+var snapshotIdentity = []string{"-c", "user.name=Claude Scheduler", "-c", "user.email=claude-schedule@localhost"}
+
+// git runs git in dir and returns its trimmed standard output. Errors carry
+// what git printed.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	hideWindow(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Create adds a worktree of the repository at repo in dir, on a new branch
+// starting at the repository's current commit, and returns that commit.
+func Create(ctx context.Context, repo, dir, branch string) (string, error) {
+	base, err := git(ctx, repo, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("%s has no commit to start from: %w", repo, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return "", err
+	}
+	if _, err := git(ctx, repo, "worktree", "add", "-b", branch, dir, base); err != nil {
+		return "", err
+	}
+	return base, nil
+}
+
+// Subdir returns the path of dir relative to the top of its repository, or
+// "" at the top.
+func Subdir(ctx context.Context, dir string) (string, error) {
+	prefix, err := git(ctx, dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(strings.TrimSuffix(prefix, "/")), nil
+}
+
+// Snapshot commits the changes left uncommitted in the worktree at dir, if
+// there are any, so the branch holds everything the run did.
+func Snapshot(ctx context.Context, dir, message string) error {
+	status, err := git(ctx, dir, "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		return nil
+	}
+	if _, err := git(ctx, dir, "add", "-A"); err != nil {
+		return err
+	}
+	_, err = git(ctx, dir, append(snapshotIdentity, "commit", "--no-verify", "-q", "-m", message)...)
+	return err
+}
+
+// Changes returns the diff of the worktree at dir against base and the
+// commits made since, newest first.
+func Changes(ctx context.Context, dir, base string) (string, []Commit, error) {
+	diff, err := git(ctx, dir, "diff", base, "HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+	log, err := git(ctx, dir, "log", fmt.Sprintf("--max-count=%d", maxCommits),
+		"--format=%H%x1f%s%x1f%an%x1f%aI", base+"..HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+	var commits []Commit
+	for _, line := range strings.Split(log, "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		commits = append(commits, Commit{Hash: fields[0], Subject: fields[1], Author: fields[2], Date: fields[3]})
+	}
+	return diff, commits, nil
+}
+
+// Repo returns the main checkout of the repository the worktree at dir
+// belongs to.
+func Repo(ctx context.Context, dir string) (string, error) {
+	common, err := git(ctx, dir, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(common), nil
+}
+
+// Remove deletes the worktree at dir from the repository at repo, discarding
+// anything in it that is not committed. The branch is kept.
+func Remove(ctx context.Context, repo, dir string) error {
+	_, err := git(ctx, repo, "worktree", "remove", "--force", dir)
+	return err
+}
+
+// DeleteBranch deletes a branch of the repository at repo, merged or not.
+func DeleteBranch(ctx context.Context, repo, branch string) error {
+	_, err := git(ctx, repo, "branch", "-D", branch)
+	return err
+}
+
+// Merge merges branch into the branch checked out at repo. A merge that fails,
+// for example on a conflict, is aborted so the checkout is left as it was.
+func Merge(ctx context.Context, repo, branch string) error {
+	if _, err := git(ctx, repo, "merge", "--no-edit", branch); err != nil {
+		git(ctx, repo, "merge", "--abort")
+		return err
+	}
+	return nil
+}
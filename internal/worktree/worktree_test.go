@@ -0,0 +1,120 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testRepo returns a repository with one commit of README.md.
+func testRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	run("init", "-q", "-b", "main")
+	run("config", "user.name", "Test")
+	run("config", "user.email", "test@example.com")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0o644))
+	run("add", "README.md")
+	run("commit", "-q", "-m", "Initial commit")
+	return repo
+}
+
+func TestWorktreeLifecycle(t *testing.T) {
+	ctx := context.Background()
+	repo := testRepo(t)
+	dir := filepath.Join(t.TempDir(), "wt")
+
+	base, err := Create(ctx, repo, dir, "claude-schedule/run-1")
+	require.NoError(t, err)
+	require.Len(t, base, 40)
+	require.FileExists(t, filepath.Join(dir, "README.md"))
+	got, err := Repo(ctx, dir)
+	require.NoError(t, err)
+	wantRepo, err := filepath.EvalSymlinks(repo)
+	require.NoError(t, err)
+	require.Equal(t, wantRepo, got)
+
+	// Nothing to commit yet.
+	require.NoError(t, Snapshot(ctx, dir, "snapshot"))
+	diff, commits, err := Changes(ctx, dir, base)
+	require.NoError(t, err)
+	require.Empty(t, diff)
+	require.Empty(t, commits)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "NOTES.md"), []byte("notes\n"), 0o644))
+	require.NoError(t, Snapshot(ctx, dir, "Uncommitted changes"))
+	diff, commits, err = Changes(ctx, dir, base)
+	require.NoError(t, err)
+	require.Contains(t, diff, "+notes")
+	require.Len(t, commits, 1)
+	require.Equal(t, "Uncommitted changes", commits[0].Subject)
+	require.Equal(t, "Claude Scheduler", commits[0].Author)
+
+	// The main checkout is untouched until the branch is merged.
+	require.NoFileExists(t, filepath.Join(repo, "NOTES.md"))
+	require.NoError(t, Merge(ctx, repo, "claude-schedule/run-1"))
+	require.FileExists(t, filepath.Join(repo, "NOTES.md"))
+
+	require.NoError(t, Remove(ctx, repo, dir))
+	require.NoDirExists(t, dir)
+	require.NoError(t, DeleteBranch(ctx, repo, "claude-schedule/run-1"))
+	require.Error(t, DeleteBranch(ctx, repo, "claude-schedule/run-1"))
+}
+
+func TestMergeConflictLeavesCheckoutAlone(t *testing.T) {
+	ctx := context.Background()
+	repo := testRepo(t)
+	dir := filepath.Join(t.TempDir(), "wt")
+	_, err := Create(ctx, repo, dir, "claude-schedule/run-2")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("from the run\n"), 0o644))
+	require.NoError(t, Snapshot(ctx, dir, "run edit"))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "README.md"), []byte("from a human\n"), 0o644))
+	_, err = git(ctx, repo, "commit", "-qam", "human edit")
+	require.NoError(t, err)
+
+	require.Error(t, Merge(ctx, repo, "claude-schedule/run-2"))
+	status, err := git(ctx, repo, "status", "--porcelain")
+	require.NoError(t, err)
+	require.Empty(t, status)
+	data, err := os.ReadFile(filepath.Join(repo, "README.md"))
+	require.NoError(t, err)
+	require.Equal(t, "from a human\n", string(data))
+}
+
+func TestCreateRequiresACommit(t *testing.T) {
+	repo := t.TempDir()
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = repo
+	require.NoError(t, cmd.Run())
+
+	_, err := Create(context.Background(), repo, filepath.Join(t.TempDir(), "wt"), "b")
+	require.ErrorContains(t, err, "no commit to start from")
+}
+
+func TestSubdir(t *testing.T) {
+	ctx := context.Background()
+	repo := testRepo(t)
+	sub := filepath.Join(repo, "a", "b")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+
+	got, err := Subdir(ctx, sub)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("a", "b"), got)
+	got, err = Subdir(ctx, repo)
+	require.NoError(t, err)
+	require.Empty(t, got)
+	_, err = Subdir(ctx, t.TempDir())
+	require.Error(t, err)
+}
@@ -7,6 +7,10 @@ import (
 	"path/filepath"
 	"runtime"
 
+	// Embed the time zone database so per-job prompt time zones resolve on
+	// systems without one, such as Windows.
+	_ "time/tzdata"
+
 	"claude-schedule/internal/db"
 	"claude-schedule/internal/executor"
 
@@ -20,20 +24,24 @@ import (
 var assets embed.FS
 
 func main() {
+	// Sandboxed runs start the app again as their network forwarder.
+	executor.SandboxMain()
+
 	configDir, err := os.UserConfigDir()
 	if err != nil {
 		log.Fatalf("cannot find config directory: %v", err)
 	}
-	dbPath := filepath.Join(configDir, "claude-schedule", "claude-schedule.db")
-	executor.DebugDir = filepath.Join(configDir, "claude-schedule", "debug")
+	dataDir := filepath.Join(configDir, "claude-schedule")
+	dbPath := filepath.Join(dataDir, "claude-schedule.db")
 
 	store, err := db.Open(dbPath)
 	if err != nil {
 		log.Fatalf("cannot open database: %v", err)
 	}
+	store.SetArtifactsRoot(filepath.Join(dataDir, "artifacts"))
 
 	notifier := notifications.New()
-	appService := NewApp(store, notifier)
+	appService := NewApp(store, notifier, dataDir)
 
 	app := application.New(application.Options{
 		Name:        "Claude Scheduler",